@@ -0,0 +1,168 @@
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * chunk append opt face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - append data to the end of record in place
+ * - only raw data record, compressed or encrypted data can't be appended
+ * - header written at last, so torn append keep the old data
+ * - prepare callback run before data written, used for write ahead log
+ */
+
+//check record can be appended in place or not
+func (f *Chunk) IsAppendable(offset int64) bool {
+	header := make([]byte, f.getHeaderLen())
+	err := f.readAt(header, offset)
+	if err != nil {
+		return false
+	}
+	msg, err := f.unpackHeader(header)
+	if err != nil || !IsValidHeader(msg) {
+		return false
+	}
+	return msg.GetCodec() == define.CodecOfNone && msg.GetFlags() & define.HeaderFlagOfEncrypt == 0
+}
+
+//append data to record in place
+//spaceSize is whole record space owned by caller, include header
+//if record exceed space, only the record at chunk tail can be extended
+//prepare called with new blocks after space ready, before any data written
+//return ChunkWriteResp, define.ErrSpaceNotEnough if no space
+func (f *Chunk) AppendRecord(
+		offset int64,
+		data []byte,
+		spaceSize int64,
+		prepare WritePrepare,
+	) *WriteResp {
+	var (
+		resp WriteResp
+	)
+	//check
+	if offset < 0 || len(data) <= 0 {
+		resp.Err = errors.New("invalid parameter")
+		return &resp
+	}
+	if !f.IsOpened() || f.file == nil {
+		resp.Err = errors.New("file not opened yet")
+		return &resp
+	}
+
+	//read and check header
+	headerLen := f.getHeaderLen()
+	header := make([]byte, headerLen)
+	err := f.readAt(header, offset)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	msg, err := f.unpackHeader(header)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	if !IsValidHeader(msg) {
+		resp.Err = fmt.Errorf("invalid header at offset %v", offset)
+		return &resp
+	}
+	if msg.GetCodec() != define.CodecOfNone || msg.GetFlags() & define.HeaderFlagOfEncrypt != 0 {
+		resp.Err = errors.New("compressed or encrypted data can't be appended")
+		return &resp
+	}
+
+	//calculate new blocks
+	oldLen := msg.GetLen()
+	oldBlocks := msg.GetBlocks()
+	newLen := oldLen + int64(len(data))
+	newBlocks := f.calRealBlockSize(newLen)
+	if newBlocks < oldBlocks {
+		newBlocks = oldBlocks
+	}
+
+	//check and extend record space
+	err = f.extendRecord(offset, headerLen + oldBlocks, headerLen + newBlocks, spaceSize)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+
+	//run prepare before data written
+	if prepare != nil {
+		err = prepare(newBlocks)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+	}
+
+	//write new data after old data
+	err = f.writeAt(data, offset + headerLen + oldLen)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+
+	//write header at last
+	msg.SetLen(newLen)
+	msg.SetRawLen(newLen)
+	msg.SetBlocks(newBlocks)
+	if msg.GetCrc() > 0 {
+		msg.SetCrc(crc32.Update(msg.GetCrc(), crcTable, data))
+	}
+	header, err = f.packHeader(msg)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	resp.NewOffSet = offset
+	resp.BlockSize = newBlocks
+	resp.Md5 = msg.GetMd5()
+	resp.Codec = msg.GetCodec()
+	resp.Err = f.writeAt(header, offset)
+	return &resp
+}
+
+/////////////////
+//private func
+/////////////////
+
+//check and extend record space
+//record at chunk tail extended by chunk size
+func (f *Chunk) extendRecord(
+		offset, oldSpace, newSpace, spaceSize int64,
+	) error {
+	//check space
+	if newSpace <= oldSpace || newSpace <= spaceSize {
+		if !f.cfg.UseMemoryMap {
+			return nil
+		}
+		f.fileLocker.Lock()
+		defer f.fileLocker.Unlock()
+		return f.expandMemoryMap(offset + newSpace)
+	}
+
+	//opt with locker
+	f.fileLocker.Lock()
+	defer f.fileLocker.Unlock()
+	if offset + oldSpace != f.chunkObj.Size || offset + newSpace > f.chunkObj.MaxSize {
+		return define.ErrSpaceNotEnough
+	}
+	if f.cfg.UseMemoryMap {
+		err := f.expandMemoryMap(offset + newSpace)
+		if err != nil {
+			return err
+		}
+	}
+
+	//update chunk obj
+	f.chunkObj.Size = offset + newSpace
+	return f.updateMetaFile(true)
+}
@@ -125,6 +125,19 @@ func (f *Chunk) SetChunkMaxSize(size int64) error {
 	return nil
 }
 
+//fix chunk active size
+//only grow size, used for recover chunk meta
+func (f *Chunk) FixChunkSize(size int64) bool {
+	f.fileLocker.Lock()
+	defer f.fileLocker.Unlock()
+	if size <= f.chunkObj.Size {
+		return false
+	}
+	f.chunkObj.Size = size
+	f.updateMetaFile(true)
+	return true
+}
+
 //get file id
 func (f *Chunk) GetFileId() int64 {
 	return f.chunkObj.Id
@@ -0,0 +1,95 @@
+package chunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+ * data compress codec face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - all codecs use stream format
+ * - keep raw data if compressed data not smaller
+ */
+
+//get codec by compress name
+//empty name means none
+func GetCodec(name string) (uint8, error) {
+	switch name {
+	case "", define.CompressOfNone:
+		return define.CodecOfNone, nil
+	case define.CompressOfZstd:
+		return define.CodecOfZstd, nil
+	case define.CompressOfGzip:
+		return define.CodecOfGzip, nil
+	case define.CompressOfSnappy:
+		return define.CodecOfSnappy, nil
+	default:
+		return define.CodecOfNone, fmt.Errorf("unsupported compress %v", name)
+	}
+}
+
+//new compress writer
+func newCompressWriter(codec uint8, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case define.CodecOfZstd:
+		return zstd.NewWriter(w)
+	case define.CodecOfGzip:
+		return gzip.NewWriter(w), nil
+	case define.CodecOfSnappy:
+		return snappy.NewBufferedWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %v", codec)
+	}
+}
+
+//new decompress reader
+func newDecompressReader(codec uint8, r io.Reader) (io.ReadCloser, error) {
+	switch codec {
+	case define.CodecOfZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case define.CodecOfGzip:
+		return gzip.NewReader(r)
+	case define.CodecOfSnappy:
+		return io.NopCloser(snappy.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %v", codec)
+	}
+}
+
+//compress data
+//return payload data and real codec
+func compressData(codec uint8, data []byte) ([]byte, uint8, error) {
+	if codec == define.CodecOfNone {
+		return data, codec, nil
+	}
+	buff := bytes.NewBuffer(nil)
+	writer, err := newCompressWriter(codec, buff)
+	if err != nil {
+		return nil, codec, err
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return nil, codec, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, codec, err
+	}
+	if buff.Len() >= len(data) {
+		//compressed data not smaller, keep raw data
+		return data, define.CodecOfNone, nil
+	}
+	return buff.Bytes(), codec, nil
+}
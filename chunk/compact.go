@@ -0,0 +1,110 @@
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * chunk compact opt face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - copy record from other chunk as raw bytes
+ * - no decode, compressed or encrypted data kept as it is
+ * - header written at last, so torn copy has empty header
+ */
+
+//copy one record of source chunk to the tail of this chunk
+//return ChunkWriteResp
+func (f *Chunk) CopyRecord(src *Chunk, offset int64) *WriteResp {
+	var (
+		resp WriteResp
+	)
+	//check
+	if src == nil || offset < 0 {
+		resp.Err = errors.New("invalid parameter")
+		return &resp
+	}
+	if !f.IsOpened() || f.file == nil {
+		resp.Err = errors.New("file not opened yet")
+		return &resp
+	}
+
+	//read and check source header
+	headerLen := f.getHeaderLen()
+	header := make([]byte, headerLen)
+	err := src.readAt(header, offset)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	msg, err := src.unpackHeader(header)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	if !IsValidHeader(msg) {
+		resp.Err = fmt.Errorf("invalid header at offset %v", offset)
+		return &resp
+	}
+	blocks := msg.GetBlocks()
+
+	//reserve chunk space
+	newOffset, err := f.reserveSpace(headerLen + blocks, -1)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	resp.NewOffSet = newOffset
+	resp.BlockSize = blocks
+	resp.Md5 = msg.GetMd5()
+	resp.Codec = msg.GetCodec()
+
+	//copy block data piece by piece
+	buff := make([]byte, define.DefaultStreamBuffSize)
+	copied := int64(0)
+	for copied < blocks {
+		pieceSize := blocks - copied
+		if pieceSize > int64(len(buff)) {
+			pieceSize = int64(len(buff))
+		}
+		piece := buff[:pieceSize]
+		err = src.readAt(piece, offset + headerLen + copied)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		err = f.writeAt(piece, newOffset + headerLen + copied)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		copied += pieceSize
+	}
+
+	//write header at last
+	resp.Err = f.writeAt(header, newOffset)
+	return &resp
+}
+
+//remove chunk data and meta file
+//chunk can't be used any more
+func (f *Chunk) Remove() error {
+	//quit and close file
+	f.Quit()
+
+	//remove data and meta file
+	err := os.Remove(f.dataFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	metaFile := fmt.Sprintf("%v/%v/%v", f.cfg.DataPath, define.SubDirOfFile, f.metaFilePath)
+	err = os.Remove(metaFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
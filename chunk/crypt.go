@@ -0,0 +1,239 @@
+package chunk
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * data encrypt face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - aes-gcm, key from config key provider
+ * - format: nonce + sealed segments, each segment with own tag
+ * - segment nonce is base nonce xor segment index
+ * - segment can be decrypted alone, support range read
+ */
+
+//encrypt reader
+//read plain stream, output encrypted stream
+type encryptReader struct {
+	aead    cipher.AEAD
+	nonce   []byte
+	reader  io.Reader
+	left    int64 //left plain data size
+	index   uint64
+	plain   []byte
+	sealed  []byte
+	pending []byte //encrypted data not read yet
+}
+
+//decrypt reader at
+//read plain data at assigned position
+type decryptReaderAt struct {
+	aead      cipher.AEAD
+	source    io.ReaderAt //encrypted data
+	plainLen  int64
+	nonce     []byte
+	cacheIdx  int64
+	cacheData []byte
+}
+
+//get encrypted size by plain size
+func encryptedSize(plainLen int64) int64 {
+	segments := (plainLen + define.EncryptSegmentSize - 1) / define.EncryptSegmentSize
+	return define.EncryptNonceSize + plainLen + segments * define.EncryptTagSize
+}
+
+//get plain size by encrypted size
+func plainSize(encLen int64) int64 {
+	segmentLen := int64(define.EncryptSegmentSize + define.EncryptTagSize)
+	segments := (encLen - define.EncryptNonceSize + segmentLen - 1) / segmentLen
+	return encLen - define.EncryptNonceSize - segments * define.EncryptTagSize
+}
+
+//gen aes-gcm cipher
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//gen segment nonce
+func segmentNonce(nonce []byte, index uint64) []byte {
+	segNonce := make([]byte, len(nonce))
+	copy(segNonce, nonce)
+	pos := len(segNonce) - 8
+	binary.BigEndian.PutUint64(segNonce[pos:], binary.BigEndian.Uint64(segNonce[pos:]) ^ index)
+	return segNonce
+}
+
+//check encrypt enabled or not
+func (f *Chunk) isEncryptEnabled() bool {
+	return f.cfg.KeyProvider != nil
+}
+
+//get current encrypt cipher
+//return key id, cipher, error
+func (f *Chunk) getEncryptCipher() (uint32, cipher.AEAD, error) {
+	keyId, key, err := f.cfg.KeyProvider.CurrentKey()
+	if err != nil {
+		return 0, nil, err
+	}
+	aead, err := newAEAD(key)
+	return keyId, aead, err
+}
+
+//get decrypt cipher by key id
+func (f *Chunk) getDecryptCipher(keyId uint32) (cipher.AEAD, error) {
+	if f.cfg.KeyProvider == nil {
+		return nil, errors.New("no key provider for encrypted data")
+	}
+	key, err := f.cfg.KeyProvider.GetKey(keyId)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+//new encrypt reader
+func newEncryptReader(
+		aead cipher.AEAD,
+		reader io.Reader,
+		plainLen int64,
+	) (*encryptReader, error) {
+	nonce := make([]byte, define.EncryptNonceSize)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	this := &encryptReader{
+		aead: aead,
+		nonce: nonce,
+		reader: reader,
+		left: plainLen,
+		plain: make([]byte, define.EncryptSegmentSize),
+		pending: append([]byte{}, nonce...),
+	}
+	return this, nil
+}
+
+//read encrypted data
+func (r *encryptReader) Read(p []byte) (int, error) {
+	if len(r.pending) <= 0 {
+		if r.left <= 0 {
+			return 0, io.EOF
+		}
+
+		//read and seal next segment
+		segLen := int64(len(r.plain))
+		if segLen > r.left {
+			segLen = r.left
+		}
+		_, err := io.ReadFull(r.reader, r.plain[:segLen])
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		r.sealed = r.aead.Seal(r.sealed[:0], segmentNonce(r.nonce, r.index), r.plain[:segLen], nil)
+		r.pending = r.sealed
+		r.index++
+		r.left -= segLen
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+//encrypt whole data with current key
+//return key id, encrypted data, error
+func (f *Chunk) encryptData(data []byte) (uint32, []byte, error) {
+	keyId, aead, err := f.getEncryptCipher()
+	if err != nil {
+		return 0, nil, err
+	}
+	reader, err := newEncryptReader(aead, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, nil, err
+	}
+	encData := make([]byte, encryptedSize(int64(len(data))))
+	_, err = io.ReadFull(reader, encData)
+	return keyId, encData, err
+}
+
+//new decrypt reader at
+func newDecryptReaderAt(
+		aead cipher.AEAD,
+		source io.ReaderAt,
+		encLen int64,
+	) (*decryptReaderAt, error) {
+	nonce := make([]byte, define.EncryptNonceSize)
+	_, err := source.ReadAt(nonce, 0)
+	if err != nil {
+		return nil, err
+	}
+	this := &decryptReaderAt{
+		aead: aead,
+		source: source,
+		plainLen: plainSize(encLen),
+		nonce: nonce,
+		cacheIdx: -1,
+	}
+	return this, nil
+}
+
+//read plain data at assigned position
+func (r *decryptReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	var (
+		read int
+	)
+	for read < len(p) {
+		pos := off + int64(read)
+		if pos >= r.plainLen {
+			return read, io.EOF
+		}
+		segIdx := pos / define.EncryptSegmentSize
+		segData, err := r.openSegment(segIdx)
+		if err != nil {
+			return read, err
+		}
+		read += copy(p[read:], segData[pos - segIdx * define.EncryptSegmentSize:])
+	}
+	return read, nil
+}
+
+//open one segment, last segment cached
+func (r *decryptReaderAt) openSegment(segIdx int64) ([]byte, error) {
+	if r.cacheIdx == segIdx {
+		return r.cacheData, nil
+	}
+	segPlainLen := r.plainLen - segIdx * define.EncryptSegmentSize
+	if segPlainLen > define.EncryptSegmentSize {
+		segPlainLen = define.EncryptSegmentSize
+	}
+	segOffset := define.EncryptNonceSize + segIdx * (define.EncryptSegmentSize + define.EncryptTagSize)
+	sealed := make([]byte, segPlainLen + define.EncryptTagSize)
+	_, err := r.source.ReadAt(sealed, segOffset)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := r.aead.Open(sealed[:0], segmentNonce(r.nonce, uint64(segIdx)), sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w, segment %v decrypt failed", define.ErrDataCorrupted, segIdx)
+	}
+	r.cacheIdx = segIdx
+	r.cacheData = plain
+	return plain, nil
+}
@@ -1,6 +1,7 @@
 package chunk
 
 import (
+	"crypto/md5"
 	"fmt"
 	"log"
 	"os"
@@ -83,8 +84,9 @@ func (f *Chunk) openDataFile() error {
 		//init empty file
 		if fileSize <= 0 {
 			//write empty data header
-			md5Str := fmt.Sprintf("%v", time.Now().Unix())
-			headerData := f.genRealHeaderData(md5Str, []byte(md5Str), 0)
+			stubData := []byte(fmt.Sprintf("%v", time.Now().Unix()))
+			md5Str := fmt.Sprintf("%x", md5.Sum(stubData))
+			headerData := f.genRealHeaderData(md5Str, stubData, 0)
 			_, err = file.WriteAt(headerData, 0)
 			if err != nil {
 				return err
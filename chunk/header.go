@@ -2,6 +2,7 @@ package chunk
 
 import (
 	"errors"
+	"hash/crc32"
 
 	"github.com/andyzhou/pond/face"
 )
@@ -10,8 +11,13 @@ import (
  * packet header data opt
  * @author <AndyZhou>
  * @mail <diudiu8848@163.com>
+ * - detect header version when unpack
+ * - pack header with config version, default the latest
  */
 
+//inter crc32c table
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
 //unpack header
 func (f *Chunk) unpackHeader(data []byte) (face.IMessage, error) {
 	//check
@@ -19,27 +25,54 @@ func (f *Chunk) unpackHeader(data []byte) (face.IMessage, error) {
 		return nil, errors.New("invalid parameter")
 	}
 
-	//un-pack header
-	pack := face.NewPacket()
+	//un-pack header by detected version
+	pack := face.DetectPacket(data)
 	msg, err := pack.UnPack(data)
 	return msg, err
 }
 
 //pack header
-func (f *Chunk) packHeader(md5 string, blocks, size int64) ([]byte, error) {
+func (f *Chunk) packHeader(msg face.IMessage) ([]byte, error) {
 	//check
-	if md5 == "" || size <= 0 {
+	if msg == nil || msg.GetMd5() == "" || msg.GetLen() <= 0 {
 		return nil, errors.New("invalid parameter")
 	}
 
-	//init new message
+	//pack header by config version
+	pack, err := face.GetPacket(f.cfg.HeaderVersion)
+	if err != nil {
+		return nil, err
+	}
+	if pack.GetVersion() < face.PacketVersionTwo &&
+		(msg.GetCodec() > 0 || msg.GetFlags() > 0 || msg.GetKeyId() > 0) {
+		return nil, errors.New("legacy header can't record codec or flags")
+	}
+	data, err := pack.Pack(msg)
+	return data, err
+}
+
+//gen header message
+func (f *Chunk) genHeaderMessage(
+		md5 string,
+		blocks, size int64,
+		crc uint32,
+	) face.IMessage {
 	msg := face.NewMessage()
 	msg.SetMd5(md5)
 	msg.SetBlocks(blocks)
 	msg.SetLen(size)
+	msg.SetRawLen(size)
+	msg.SetCrc(crc)
+	return msg
+}
 
-	//pack header
-	pack := face.NewPacket()
-	data, err := pack.Pack(msg)
-	return data, err
-}
\ No newline at end of file
+//get header length
+//all header versions keep the same size
+func (f *Chunk) getHeaderLen() int64 {
+	return face.PacketHeadSize
+}
+
+//get crc32c checksum of data
+func (f *Chunk) crc32Sum(data []byte) uint32 {
+	return crc32.Checksum(data, crcTable)
+}
@@ -2,9 +2,10 @@ package chunk
 
 import (
 	"errors"
+	"io"
 	"time"
 
-	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/define"
 )
 
 /*
@@ -60,6 +61,54 @@ func (f *Chunk) ReadFile(
 	return respObj.Data, nil
 }
 
+//read logical data range of one file
+//offset is header offset, start and length are logical position
+//encrypted or compressed data decoded by reader
+func (f *Chunk) ReadRange(
+		offset, start, length int64,
+	) ([]byte, error) {
+	//check
+	if offset < 0 || start < 0 || length <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//read and unpack header
+	header := make([]byte, f.getHeaderLen())
+	err := f.readAt(header, offset)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := f.unpackHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	rawLen := msg.GetRawLen()
+	if start >= rawLen {
+		return nil, errors.New("offset exceed data size")
+	}
+	if length > rawLen - start {
+		length = rawLen - start
+	}
+
+	//read raw data directly
+	if msg.GetCodec() == define.CodecOfNone && msg.GetFlags() & define.HeaderFlagOfEncrypt == 0 {
+		return f.ReadFile(offset + start, offset + start + length, true)
+	}
+
+	//read by decode reader
+	reader, err := f.openReader(offset, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data := make([]byte, length)
+	_, err = reader.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
 /////////////////
 //private func
 /////////////////
@@ -118,9 +167,8 @@ func (f *Chunk) directReadData(
 		return nil, errors.New("data file not opened yet")
 	}
 
-	//get header message
-	pack := face.NewPacket()
-	headerLen := pack.GetHeadLen()
+	//get header length
+	headerLen := f.getHeaderLen()
 
 	//create block buffer
 	size := end - offset
@@ -138,7 +186,7 @@ func (f *Chunk) directReadData(
 			if offset > dataLen || (offset + headerLen) > dataLen {
 				return nil, errors.New("offset or header len exceed file data size")
 			}
-			copy(header, f.data[offset:headerLen])
+			copy(header, f.data[offset:offset+headerLen])
 		}else{
 			//read origin file
 			_, err = f.file.ReadAt(header, offset)
@@ -146,7 +194,7 @@ func (f *Chunk) directReadData(
 		if err != nil || header == nil {
 			return nil, err
 		}
-		msg, subErr := pack.UnPack(header)
+		msg, subErr := f.unpackHeader(header)
 		if subErr != nil {
 			return nil, err
 		}
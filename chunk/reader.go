@@ -0,0 +1,263 @@
+package chunk
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+)
+
+/*
+ * chunk data reader face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - application `io.ReadSeekCloser` and `io.ReaderAt`
+ * - read assigned data of chunk without whole data buffer
+ * - encrypted data decrypted by segment
+ * - compressed data decoded by stream, position is logical offset
+ */
+
+//face info
+type Reader struct {
+	md5       string
+	version   int           //header version
+	crc       uint32        //crc32c of stored data, zero for legacy header
+	codec     uint8         //compress codec
+	source    io.ReaderAt   //decrypted stored data
+	sourceLen int64         //decrypted stored data size
+	size      int64         //logical data size
+	pos       int64         //current read position
+	decoder   io.ReadCloser //decompress stream
+	decodePos int64         //current decoder logical position
+	closed    bool
+	sync.Mutex
+}
+
+//chunk data reader at
+type chunkReaderAt struct {
+	chunk *Chunk
+}
+
+//open data reader by header offset
+func (f *Chunk) OpenReader(offset int64) (*Reader, error) {
+	//check
+	if offset < 0 {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//read and unpack header
+	headerLen := f.getHeaderLen()
+	header := make([]byte, headerLen)
+	err := f.readAt(header, offset)
+	if err != nil {
+		return nil, err
+	}
+	msg, subErr := f.unpackHeader(header)
+	if subErr != nil {
+		return nil, subErr
+	}
+	return f.openReader(offset, msg)
+}
+
+//get data md5 of header
+func (r *Reader) Md5() string {
+	return r.md5
+}
+
+//get header version
+func (r *Reader) Version() int {
+	return r.version
+}
+
+//get compress codec of data
+func (r *Reader) Codec() uint8 {
+	return r.codec
+}
+
+//get stored data crc32c of header
+//legacy header return zero
+func (r *Reader) Crc32() uint32 {
+	return r.crc
+}
+
+//get logical data size
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+//read data
+func (r *Reader) Read(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	n, err := r.readAt(p, r.pos)
+	r.pos += int64(n)
+	return n, err
+}
+
+//read data at assigned position
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.readAt(p, off)
+}
+
+//seek read position
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	var (
+		pos int64
+	)
+	r.Lock()
+	defer r.Unlock()
+	if r.closed {
+		return 0, errors.New("reader had closed")
+	}
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("invalid whence parameter")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+//close reader
+func (r *Reader) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	r.closed = true
+	if r.decoder != nil {
+		r.decoder.Close()
+		r.decoder = nil
+	}
+	return nil
+}
+
+/////////////////
+//private func
+/////////////////
+
+//open data reader by header offset and message
+func (f *Chunk) openReader(
+		offset int64,
+		msg face.IMessage,
+	) (*Reader, error) {
+	//check
+	if msg.GetLen() <= 0 || msg.GetLen() > msg.GetBlocks() {
+		return nil, errors.New("invalid data header")
+	}
+
+	//init stored data source
+	var source io.ReaderAt = io.NewSectionReader(&chunkReaderAt{chunk: f},
+		offset + f.getHeaderLen(), msg.GetLen())
+	sourceLen := msg.GetLen()
+	if msg.GetFlags() & define.HeaderFlagOfEncrypt > 0 {
+		aead, err := f.getDecryptCipher(msg.GetKeyId())
+		if err != nil {
+			return nil, err
+		}
+		decryptReader, err := newDecryptReaderAt(aead, source, sourceLen)
+		if err != nil {
+			return nil, err
+		}
+		source = decryptReader
+		sourceLen = decryptReader.plainLen
+	}
+
+	//init reader
+	reader := &Reader{
+		md5: msg.GetMd5(),
+		version: msg.GetVersion(),
+		crc: msg.GetCrc(),
+		codec: msg.GetCodec(),
+		source: source,
+		sourceLen: sourceLen,
+		size: msg.GetRawLen(),
+	}
+	return reader, nil
+}
+
+//read data at assigned position
+func (r *Reader) readAt(p []byte, off int64) (int, error) {
+	//check
+	if r.closed {
+		return 0, errors.New("reader had closed")
+	}
+	if off < 0 {
+		return 0, errors.New("negative position")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	//read real data
+	readLen := int64(len(p))
+	if readLen > r.size - off {
+		readLen = r.size - off
+	}
+	var err error
+	if r.codec == define.CodecOfNone {
+		_, err = r.source.ReadAt(p[:readLen], off)
+	}else{
+		err = r.readDecoded(p[:readLen], off)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if readLen < int64(len(p)) {
+		return int(readLen), io.EOF
+	}
+	return int(readLen), nil
+}
+
+//read decoded data at logical position
+//decoder restart for backward position
+func (r *Reader) readDecoded(p []byte, off int64) error {
+	var (
+		err error
+	)
+	if r.decoder == nil || off < r.decodePos {
+		if r.decoder != nil {
+			r.decoder.Close()
+			r.decoder = nil
+		}
+		storedReader := io.NewSectionReader(r.source, 0, r.sourceLen)
+		r.decoder, err = newDecompressReader(r.codec, storedReader)
+		if err != nil {
+			return err
+		}
+		r.decodePos = 0
+	}
+
+	//skip data before position
+	if off > r.decodePos {
+		skipped, subErr := io.CopyN(io.Discard, r.decoder, off - r.decodePos)
+		r.decodePos += skipped
+		if subErr != nil {
+			return subErr
+		}
+	}
+
+	//read decoded data
+	n, err := io.ReadFull(r.decoder, p)
+	r.decodePos += int64(n)
+	return err
+}
+
+//read chunk data at assigned offset
+func (c *chunkReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	err := c.chunk.readAt(p, off)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
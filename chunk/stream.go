@@ -0,0 +1,342 @@
+package chunk
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+)
+
+/*
+ * chunk stream write face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - header + realData as whole data value
+ * - reserve space first, then write data piece by piece
+ * - header written at last, so torn write has empty header
+ * - compressed stream spooled into temp file first
+ * - stored data encrypted by stream if enabled
+ */
+
+//write stream data
+//if md5 is empty, use content md5 as header md5
+//return ChunkWriteResp
+func (f *Chunk) WriteStream(
+		md5Val string,
+		reader io.Reader,
+		size int64,
+		offsets ...int64,
+	) *WriteResp {
+	return f.WriteCodecStream(md5Val, reader, size, define.CodecOfNone, offsets...)
+}
+
+//write stream data with compress codec
+//if md5 is empty, use raw content md5 as header md5
+//return ChunkWriteResp
+func (f *Chunk) WriteCodecStream(
+		md5Val string,
+		reader io.Reader,
+		size int64,
+		codec uint8,
+		offsets ...int64,
+	) *WriteResp {
+	var (
+		offset int64 = -1
+		resp WriteResp
+	)
+
+	//check
+	if reader == nil || size <= 0 {
+		resp.Err = errors.New("invalid parameter")
+		return &resp
+	}
+	if !f.IsOpened() || f.file == nil {
+		resp.Err = errors.New("file not opened yet")
+		return &resp
+	}
+
+	//detect offset
+	if offsets != nil && len(offsets) > 0 {
+		offset = offsets[0]
+	}
+
+	//init header message
+	//raw content md5 calculated during read
+	rawHash := md5.New()
+	msg := f.genHeaderMessage(md5Val, 0, size, 0)
+	plainReader := io.TeeReader(reader, rawHash)
+	plainSize := size
+
+	if codec != define.CodecOfNone {
+		//spool compressed data
+		spool, err := f.spoolCompress(codec, plainReader, size)
+		if spool != nil {
+			defer func() {
+				spool.Close()
+				os.Remove(spool.Name())
+			}()
+		}
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		spoolInfo, err := spool.Stat()
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		spoolSize := spoolInfo.Size()
+		spoolReader := io.NewSectionReader(spool, 0, spoolSize)
+		if spoolSize < size {
+			//write compressed data
+			plainReader = spoolReader
+			plainSize = spoolSize
+			msg.SetCodec(codec)
+		}else{
+			//compressed data not smaller, write raw data
+			rawReader, subErr := newDecompressReader(codec, spoolReader)
+			if subErr != nil {
+				resp.Err = subErr
+				return &resp
+			}
+			defer rawReader.Close()
+			plainReader = rawReader
+		}
+	}
+
+	//encrypt data
+	storedReader := plainReader
+	storedSize := plainSize
+	if f.isEncryptEnabled() {
+		keyId, aead, err := f.getEncryptCipher()
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		storedReader, err = newEncryptReader(aead, plainReader, plainSize)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		storedSize = encryptedSize(plainSize)
+		msg.SetFlags(msg.GetFlags() | define.HeaderFlagOfEncrypt)
+		msg.SetKeyId(keyId)
+	}
+
+	//write stored data
+	return f.writeStream(msg, rawHash, storedReader, storedSize, offset)
+}
+
+/////////////////
+//private func
+/////////////////
+
+//write stored stream data
+//header md5 use raw content md5 if not assigned
+func (f *Chunk) writeStream(
+		msg face.IMessage,
+		rawHash hash.Hash,
+		reader io.Reader,
+		size int64,
+		offset int64,
+	) *WriteResp {
+	var (
+		resp WriteResp
+		err error
+	)
+
+	//calculate real block size
+	realBlockSize := f.calRealBlockSize(size)
+	headerLen := f.getHeaderLen()
+
+	//reserve chunk space
+	offset, err = f.reserveSpace(headerLen + realBlockSize, offset)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+	resp.NewOffSet = offset
+	resp.BlockSize = realBlockSize
+	resp.Codec = msg.GetCodec()
+
+	//write real data piece by piece
+	crcHash := crc32.New(crcTable)
+	buff := make([]byte, define.DefaultStreamBuffSize)
+	dataOffset := offset + headerLen
+	written := int64(0)
+	for written < realBlockSize {
+		pieceSize := realBlockSize - written
+		if pieceSize > int64(len(buff)) {
+			pieceSize = int64(len(buff))
+		}
+		piece := buff[:pieceSize]
+		if written < size {
+			//read from stream
+			if size - written < pieceSize {
+				piece = buff[:size - written]
+			}
+			_, err = io.ReadFull(reader, piece)
+			if err != nil {
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					err = fmt.Errorf("stream data less than assigned size %v", msg.GetRawLen())
+				}
+				resp.Err = err
+				return &resp
+			}
+			crcHash.Write(piece)
+		}else{
+			//padding block data
+			for i := range piece {
+				piece[i] = 0
+			}
+		}
+		err = f.writeAt(piece, dataOffset + written)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		written += int64(len(piece))
+	}
+
+	//write header at last
+	resp.Md5 = fmt.Sprintf("%x", rawHash.Sum(nil))
+	if msg.GetMd5() == "" {
+		msg.SetMd5(resp.Md5)
+	}
+	msg.SetBlocks(realBlockSize)
+	msg.SetLen(size)
+	msg.SetCrc(crcHash.Sum32())
+	header, subErr := f.packHeader(msg)
+	if subErr != nil {
+		resp.Err = subErr
+		return &resp
+	}
+	resp.Err = f.writeAt(header, offset)
+	return &resp
+}
+
+//spool compressed stream data into temp file
+//return temp file, error
+func (f *Chunk) spoolCompress(
+		codec uint8,
+		reader io.Reader,
+		size int64,
+	) (*os.File, error) {
+	//create temp file
+	tempDir := fmt.Sprintf("%v/%v", f.cfg.DataPath, define.SubDirOfFile)
+	spool, err := os.CreateTemp(tempDir, define.ChunkSpoolFilePara)
+	if err != nil {
+		return nil, err
+	}
+
+	//compress raw data into temp file
+	writer, err := newCompressWriter(codec, spool)
+	if err != nil {
+		return spool, err
+	}
+	_, err = io.CopyN(writer, reader, size)
+	if err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("stream data less than assigned size %v", size)
+		}
+		return spool, err
+	}
+	err = writer.Close()
+	return spool, err
+}
+
+//reserve chunk space for whole data
+//if offset < 0, append at chunk tail
+//return real offset, error
+func (f *Chunk) reserveSpace(
+		size int64,
+		offset int64,
+	) (int64, error) {
+	//opt with locker
+	f.fileLocker.Lock()
+	defer f.fileLocker.Unlock()
+
+	//assigned offset, do nothing
+	if offset >= 0 {
+		if f.cfg.UseMemoryMap {
+			err := f.expandMemoryMap(offset + size)
+			if err != nil {
+				return offset, err
+			}
+		}
+		return offset, nil
+	}
+
+	//append at chunk tail
+	offset = f.chunkObj.Size
+	if f.cfg.UseMemoryMap {
+		err := f.expandMemoryMap(offset + size)
+		if err != nil {
+			return offset, err
+		}
+	}
+
+	//update chunk obj
+	f.chunkObj.Files++
+	f.chunkObj.Size += size
+	f.lastActiveTime = time.Now().Unix()
+	err := f.updateMetaFile(true)
+	return offset, err
+}
+
+//write data at assigned offset
+func (f *Chunk) writeAt(data []byte, offset int64) error {
+	var (
+		err error
+	)
+	//opt with locker
+	f.fileLocker.Lock()
+	defer f.fileLocker.Unlock()
+	if !f.openDone || f.file == nil {
+		return errors.New("data file not opened yet")
+	}
+	if f.cfg.UseMemoryMap {
+		//use memory map data
+		if offset + int64(len(data)) > int64(len(f.data)) {
+			return errors.New("offset exceed memory map data size")
+		}
+		copy(f.data[offset:], data)
+	}else{
+		//origin file opt
+		_, err = f.file.WriteAt(data, offset)
+	}
+	f.lastActiveTime = time.Now().Unix()
+	return err
+}
+
+//read data at assigned offset
+func (f *Chunk) readAt(data []byte, offset int64) error {
+	var (
+		err error
+	)
+	//opt with locker
+	f.fileLocker.Lock()
+	defer f.fileLocker.Unlock()
+	if !f.openDone || f.file == nil {
+		return errors.New("data file not opened yet")
+	}
+	if f.cfg.UseMemoryMap {
+		//use memory map data
+		if offset + int64(len(data)) > int64(len(f.data)) {
+			return errors.New("offset exceed memory map data size")
+		}
+		copy(data, f.data[offset:])
+	}else{
+		//origin file opt
+		_, err = f.file.ReadAt(data, offset)
+	}
+	f.lastActiveTime = time.Now().Unix()
+	return err
+}
@@ -0,0 +1,121 @@
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+)
+
+/*
+ * chunk data header upgrade face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - offline opt, pond should not running on the data path
+ * - rewrite legacy header as the latest version in place
+ * - all header versions keep the same size, data not moved
+ */
+
+//upgrade result
+type UpgradeResult struct {
+	FilePath string
+	Upgraded int64 //upgraded records
+	Walk     *WalkResult
+}
+
+//upgrade headers of all chunk data files under data path
+func UpgradeDataPath(
+		dataPath string,
+		blockSize int64,
+	) ([]*UpgradeResult, error) {
+	//check
+	if dataPath == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//get all chunk data files
+	filePattern := fmt.Sprintf("%v/%v/%v", dataPath, define.SubDirOfFile,
+		fmt.Sprintf(define.ChunkDataFilePara, "*"))
+	filePaths, err := filepath.Glob(filePattern)
+	if err != nil {
+		return nil, err
+	}
+
+	//upgrade one by one
+	results := make([]*UpgradeResult, 0)
+	for _, filePath := range filePaths {
+		result, subErr := UpgradeDataFile(filePath, blockSize)
+		if result != nil {
+			results = append(results, result)
+		}
+		if subErr != nil {
+			return results, subErr
+		}
+	}
+	return results, nil
+}
+
+//upgrade headers of one chunk data file
+func UpgradeDataFile(
+		filePath string,
+		blockSize int64,
+	) (*UpgradeResult, error) {
+	//check
+	if filePath == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//open data file
+	file, err := os.OpenFile(filePath, os.O_RDWR, define.FilePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	//walk and upgrade legacy headers
+	result := &UpgradeResult{
+		FilePath: filePath,
+	}
+	pack := face.NewPacketV2()
+	buff := make([]byte, define.DefaultStreamBuffSize)
+	walkResult, err := WalkDataFile(file, blockSize, func(offset int64, msg face.IMessage) error {
+		if msg.GetVersion() >= face.PacketVersionLatest {
+			return nil
+		}
+
+		//calculate crc of real data
+		crcHash := crc32.New(crcTable)
+		dataReader := io.NewSectionReader(file, offset + face.PacketHeadSize, msg.GetLen())
+		_, subErr := io.CopyBuffer(crcHash, dataReader, buff)
+		if subErr != nil {
+			return subErr
+		}
+		msg.SetCrc(crcHash.Sum32())
+		msg.SetRawLen(msg.GetLen())
+
+		//rewrite header
+		header, subErr := pack.Pack(msg)
+		if subErr != nil {
+			return subErr
+		}
+		_, subErr = file.WriteAt(header, offset)
+		if subErr != nil {
+			return subErr
+		}
+		result.Upgraded++
+		return nil
+	})
+	result.Walk = walkResult
+	if err != nil {
+		return result, err
+	}
+
+	//sync file data
+	err = file.Sync()
+	return result, err
+}
@@ -13,15 +13,23 @@ type (
 		Err  error
 	}
 
+	//write prepare callback
+	//called with real blocks before data written, used for write ahead log
+	WritePrepare func(blocks int64) error
+
 	//write req
 	WriteReq struct {
-		Md5    string
-		Data   []byte
-		Offset int64 //assigned offset for overwrite
+		Md5     string
+		Data    []byte
+		Offset  int64 //assigned offset for overwrite
+		Codec   uint8 //compress codec
+		Prepare WritePrepare
 	}
 	WriteResp struct {
 		NewOffSet int64
 		BlockSize int64
+		Md5       string //content md5, only for stream write
+		Codec     uint8  //real compress codec of stored data
 		Err       error
 	}
 )
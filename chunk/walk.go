@@ -0,0 +1,178 @@
+package chunk
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+)
+
+/*
+ * chunk data file walk face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - walk record headers of data file, offline opt
+ * - walk record headers of opened chunk, online opt
+ * - known free space skipped, split remainder of free space has no header
+ * - invalid header skipped by block size step
+ */
+
+//walk record callback
+type WalkCallback func(offset int64, msg face.IMessage) error
+
+//walk free space checker
+//return end offset of free space contain offset, zero means not free
+type WalkSkip func(offset int64) int64
+
+//walk result
+type WalkResult struct {
+	Records    int64 //valid records
+	Versions   map[int]int64 //records of header version
+	SkipBytes  int64 //bytes without valid header
+	FreeBytes  int64 //bytes of known free space
+	TailOffset int64 //end offset of last valid record or known free space
+	FileSize   int64
+	TornTail   bool //last record exceed file size
+}
+
+//walk all record headers of data file
+func WalkDataFile(
+		file *os.File,
+		blockSize int64,
+		cb WalkCallback,
+		skips ...WalkSkip,
+	) (*WalkResult, error) {
+	//check
+	if file == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return walkData(file, fileInfo.Size(), blockSize, cb, skips...)
+}
+
+//walk all record headers of opened chunk
+//only walk data within current chunk size
+func (f *Chunk) Walk(cb WalkCallback, skips ...WalkSkip) (*WalkResult, error) {
+	//check
+	if !f.IsOpened() {
+		return nil, errors.New("file not opened yet")
+	}
+	return walkData(&chunkReaderAt{chunk: f}, f.GetChunkActiveSize(), f.cfg.ChunkBlockSize, cb, skips...)
+}
+
+//check header message is valid or not
+func IsValidHeader(msg face.IMessage) bool {
+	if msg == nil {
+		return false
+	}
+	if msg.GetLen() <= 0 || msg.GetBlocks() <= 0 || msg.GetLen() > msg.GetBlocks() {
+		return false
+	}
+	md5Val := msg.GetMd5()
+	if len(md5Val) != face.Md5Size {
+		return false
+	}
+	for _, c := range md5Val {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
+
+/////////////////
+//private func
+/////////////////
+
+//walk all record headers of data
+func walkData(
+		reader io.ReaderAt,
+		dataSize int64,
+		blockSize int64,
+		cb WalkCallback,
+		skips ...WalkSkip,
+	) (*WalkResult, error) {
+	var (
+		skip WalkSkip
+		err error
+	)
+	if blockSize <= 0 {
+		blockSize = define.DefaultChunkBlockSize
+	}
+	if skips != nil && len(skips) > 0 {
+		skip = skips[0]
+	}
+
+	//init result
+	result := &WalkResult{
+		Versions: map[int]int64{},
+		FileSize: dataSize,
+	}
+
+	//walk record one by one
+	headerLen := int64(face.PacketHeadSize)
+	header := make([]byte, headerLen)
+	offset := int64(0)
+	for offset < result.FileSize {
+		//skip known free space
+		if skip != nil {
+			freeEnd := skip(offset)
+			if freeEnd > offset {
+				if freeEnd > result.FileSize {
+					freeEnd = result.FileSize
+				}
+				result.FreeBytes += freeEnd - offset
+				result.TailOffset = freeEnd
+				offset = freeEnd
+				continue
+			}
+		}
+		if offset + headerLen > result.FileSize {
+			break
+		}
+
+		//read and check header
+		_, err = reader.ReadAt(header, offset)
+		if err != nil && err != io.EOF {
+			return result, err
+		}
+		msg, subErr := face.DetectPacket(header).UnPack(header)
+		if subErr != nil || !IsValidHeader(msg) {
+			//skip one block
+			offset += blockSize
+			result.SkipBytes += blockSize
+			continue
+		}
+		recordEnd := offset + headerLen + msg.GetBlocks()
+		if recordEnd > result.FileSize {
+			//torn record at tail
+			result.TornTail = true
+			break
+		}
+
+		//run callback
+		if cb != nil {
+			err = cb(offset, msg)
+			if err != nil {
+				return result, err
+			}
+		}
+		result.Records++
+		result.Versions[msg.GetVersion()]++
+		result.TailOffset = recordEnd
+		offset = recordEnd
+	}
+	if offset > result.FileSize {
+		//skipped block exceed file size
+		result.SkipBytes -= offset - result.FileSize
+	}else if !result.TornTail && offset < result.FileSize {
+		//left bytes can't hold one header
+		result.TornTail = true
+	}
+	return result, nil
+}
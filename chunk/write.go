@@ -6,6 +6,10 @@ import (
 	"golang.org/x/sys/unix"
 	"math"
 	"time"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
 )
 
 /*
@@ -14,6 +18,7 @@ import (
  * @mail <diudiu8848@163.com>
  * - header + realData as whole data value
  * - support queue and direct opt
+ * - prepare callback run before data written, failed prepare keep old data
  */
 
 //write file
@@ -23,6 +28,18 @@ func (f *Chunk) WriteFile(
 		data []byte,
 		offsets ...int64,
 	) *WriteResp {
+	return f.WriteCodecFile(md5, data, define.CodecOfNone, offsets...)
+}
+
+//write file with compress codec
+//header record codec and raw data length
+//return ChunkWriteResp, error
+func (f *Chunk) WriteCodecFile(
+		md5 string,
+		data []byte,
+		codec uint8,
+		offsets ...int64,
+	) *WriteResp {
 	var (
 		offset int64 = -1
 		resp   WriteResp
@@ -34,12 +51,6 @@ func (f *Chunk) WriteFile(
 		return &resp
 	}
 
-	//check lazy mode
-	if !f.writeLazy {
-		//direct write data
-		return f.directWriteData(md5, data, offsets...)
-	}
-
 	//detect offset
 	if offsets != nil && len(offsets) > 0 {
 		offset = offsets[0]
@@ -50,10 +61,59 @@ func (f *Chunk) WriteFile(
 		Md5: md5,
 		Offset: offset,
 		Data: data,
+		Codec: codec,
+	}
+	return f.sendWriteReq(&req)
+}
+
+//overwrite file with compress codec at assigned offset
+//prepare called with real blocks before any data written
+//return ChunkWriteResp, error
+func (f *Chunk) OverwriteCodecFile(
+		md5 string,
+		data []byte,
+		codec uint8,
+		offset int64,
+		prepare WritePrepare,
+	) *WriteResp {
+	var (
+		resp WriteResp
+	)
+	//check
+	if md5 == "" || data == nil || offset < 0 {
+		resp.Err = errors.New("invalid parameter")
+		return &resp
+	}
+
+	//init write request
+	req := WriteReq{
+		Md5: md5,
+		Offset: offset,
+		Data: data,
+		Codec: codec,
+		Prepare: prepare,
+	}
+	return f.sendWriteReq(&req)
+}
+
+/////////////////
+//private func
+/////////////////
+
+//send write request
+//direct write if not lazy mode
+func (f *Chunk) sendWriteReq(req *WriteReq) *WriteResp {
+	var (
+		resp WriteResp
+	)
+	//check lazy mode
+	if !f.writeLazy {
+		//direct write data
+		return f.directWriteData(req.Md5, req.Data, req.Codec, req.Prepare, req.Offset)
 	}
 
 	//send request
-	result, err := f.writeQueue.SendData(req, true)
+	result, err := f.writeQueue.SendData(*req, true)
 	if err != nil {
 		resp.Err = err
 		return &resp
@@ -66,10 +126,6 @@ func (f *Chunk) WriteFile(
 	return &respObj
 }
 
-/////////////////
-//private func
-/////////////////
-
 //cb for write queue
 func (f *Chunk) cbForWriteOpt(
 		data interface{},
@@ -89,7 +145,7 @@ func (f *Chunk) cbForWriteOpt(
 	realData := req.Data
 
 	//direct write data
-	resp := f.directWriteData(md5, realData, offset)
+	resp := f.directWriteData(md5, realData, req.Codec, req.Prepare, offset)
 	return *resp, nil
 }
 
@@ -97,6 +153,8 @@ func (f *Chunk) cbForWriteOpt(
 func (f *Chunk) directWriteData(
 		md5 string,
 		data []byte,
+		codec uint8,
+		prepare WritePrepare,
 		offsets ...int64,
 	) *WriteResp {
 	var (
@@ -122,23 +180,41 @@ func (f *Chunk) directWriteData(
 	if offsets != nil && len(offsets) > 0 {
 		offset = offsets[0]
 	}
-	if offset < 0 {
-		offset = f.chunkObj.Size
-	}else{
-		//assigned offset
-		assignedOffset = true
+
+	//compress data
+	rawLen := int64(len(data))
+	data, codec, err = compressData(codec, data)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
+
+	//encrypt data
+	flags, keyId := uint8(0), uint32(0)
+	if f.isEncryptEnabled() {
+		keyId, data, err = f.encryptData(data)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+		flags |= define.HeaderFlagOfEncrypt
 	}
 
 	//calculate real block size
 	dataLen := int64(len(data))
-	dataSize := float64(dataLen)
-	realBlocks := int64(math.Ceil(dataSize / float64(f.cfg.ChunkBlockSize)))
-
-	//create block buffer
-	realBlockSize := realBlocks * f.cfg.ChunkBlockSize
+	realBlockSize := f.calRealBlockSize(dataLen)
 
 	//format header data
-	header, _ := f.packHeader(md5, realBlockSize, dataLen)
+	msg := f.genHeaderMessage(md5, realBlockSize, dataLen, f.crc32Sum(data))
+	msg.SetCodec(codec)
+	msg.SetRawLen(rawLen)
+	msg.SetFlags(flags)
+	msg.SetKeyId(keyId)
+	header, err := f.packHeader(msg)
+	if err != nil {
+		resp.Err = err
+		return &resp
+	}
 	headerLen := len(header)
 
 	//init whole data
@@ -156,47 +232,34 @@ func (f *Chunk) directWriteData(
 	f.fileLocker.Lock()
 	defer f.fileLocker.Unlock()
 
+	//append at chunk tail if not assigned offset
+	if offset < 0 {
+		offset = f.chunkObj.Size
+	}else{
+		//assigned offset
+		assignedOffset = true
+	}
+
+	//run prepare before data written
+	if prepare != nil {
+		err = prepare(realBlockSize)
+		if err != nil {
+			resp.Err = err
+			return &resp
+		}
+	}
+
 	//memory map data or origin file opt
 	if f.cfg.UseMemoryMap {
-		requiredSize := offset + int64(len(byteData))
-		fileDataLen := int64(len(f.data))
-		if requiredSize > fileDataLen {
-			//need expand data
-			err = f.file.Truncate(requiredSize)
-			if err != nil {
-				resp.Err = err
-				return &resp
-			}
-
-			//re-map memory data
-			err = unix.Munmap(f.data)
-			if err != nil {
-				resp.Err = err
-				return &resp
-			}
-			newData, subErr := unix.Mmap(
-									int(f.file.Fd()),
-									0,
-									int(requiredSize),
-									unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
-			if subErr != nil {
-				resp.Err = subErr
-				return &resp
-			}
-
-			////flush to file
-			//err = unix.Msync(newData, unix.MS_ASYNC)
-			//if err != nil {
-			//	resp.Err = err
-			//	return &resp
-			//}
-
-			//sync memory map data
-			f.data = newData
+		//check and expand memory map data
+		err = f.expandMemoryMap(offset + int64(len(byteData)))
+		if err != nil {
+			resp.Err = err
+			return &resp
 		}
 
 		//write new data
-		copy(f.data[f.chunkObj.Size:], byteData)
+		copy(f.data[offset:], byteData)
 	}else{
 		//origin file opt
 		_, err = f.file.WriteAt(byteData, offset)
@@ -219,12 +282,64 @@ func (f *Chunk) directWriteData(
 	//format resp
 	resp.NewOffSet = oldOffset
 	resp.BlockSize = realBlockSize
+	resp.Codec = codec
 	if assignedOffset {
 		resp.NewOffSet = offset
 	}
 	return &resp
 }
 
+//check and expand memory map data
+//must call with file locker
+func (f *Chunk) expandMemoryMap(requiredSize int64) error {
+	fileDataLen := int64(len(f.data))
+	if requiredSize <= fileDataLen {
+		return nil
+	}
+
+	//need expand data
+	err := f.file.Truncate(requiredSize)
+	if err != nil {
+		return err
+	}
+
+	//re-map memory data
+	err = unix.Munmap(f.data)
+	if err != nil {
+		return err
+	}
+	newData, subErr := unix.Mmap(
+		int(f.file.Fd()),
+		0,
+		int(requiredSize),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if subErr != nil {
+		return subErr
+	}
+
+	//sync memory map data
+	f.data = newData
+	return nil
+}
+
+//calculate real block size of data
+func (f *Chunk) calRealBlockSize(dataLen int64) int64 {
+	dataSize := float64(dataLen)
+	realBlocks := int64(math.Ceil(dataSize / float64(f.cfg.ChunkBlockSize)))
+	return realBlocks * f.cfg.ChunkBlockSize
+}
+
+//calculate max record space of raw data size
+//include header and encrypt overhead, used for space allocate
+func CalRecordSpace(cfg *conf.Config, dataSize int64) int64 {
+	storedSize := dataSize
+	if cfg.KeyProvider != nil {
+		storedSize = encryptedSize(dataSize)
+	}
+	blocks := (storedSize + cfg.ChunkBlockSize - 1) / cfg.ChunkBlockSize
+	return int64(face.PacketHeadSize) + blocks * cfg.ChunkBlockSize
+}
+
 //gen real header data
 func (f *Chunk) genRealHeaderData(
 	md5 string,
@@ -244,14 +359,11 @@ func (f *Chunk) genRealHeaderData(
 
 	//calculate real block size
 	dataLen := int64(len(data))
-	dataSize := float64(dataLen)
-	realBlocks := int64(math.Ceil(dataSize / float64(f.cfg.ChunkBlockSize)))
-
-	//create block buffer
-	realBlockSize := realBlocks * f.cfg.ChunkBlockSize
+	realBlockSize := f.calRealBlockSize(dataLen)
 
 	//format header data
-	header, _ := f.packHeader(md5, realBlockSize, dataLen)
+	msg := f.genHeaderMessage(md5, realBlockSize, dataLen, f.crc32Sum(data))
+	header, _ := f.packHeader(msg)
 	headerLen := len(header)
 
 	//init whole data
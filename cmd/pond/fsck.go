@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * fsck command
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - walk all chunk files, report orphan and torn tail records
+ * - rebuild lost meta data if repopulate
+ * - usage: pond fsck -path <data path> [-repopulate] [-redis <address>]
+ */
+
+//run fsck command
+func runFsck(args []string) error {
+	//parse options
+	flagSet := flag.NewFlagSet("fsck", flag.ExitOnError)
+	dataPath := flagSet.String("path", "", "pond data path")
+	blockSize := flagSet.Int64("block", define.DefaultChunkBlockSize, "chunk block size")
+	repopulate := flagSet.Bool("repopulate", false, "save orphan data into meta data")
+	redisAddr := flagSet.String("redis", "", "redis address, empty means use local search")
+	redisPass := flagSet.String("redis-pass", "", "redis password")
+	redisDB := flagSet.Int("redis-db", 0, "redis db number")
+	redisGroup := flagSet.String("redis-group", define.DefaultRedisGroup, "redis group tag")
+	flagSet.Parse(args)
+	if *dataPath == "" {
+		flagSet.Usage()
+		return errors.New("data path is empty")
+	}
+
+	//init pond
+	p := pond.NewPond()
+	defer p.Quit()
+	cfg := p.GenConfig()
+	cfg.DataPath = *dataPath
+	cfg.ChunkBlockSize = *blockSize
+	redisCfgs := make([]*conf.RedisConfig, 0)
+	if *redisAddr != "" {
+		redisCfg := p.GenRedisConfig()
+		redisCfg.Address = *redisAddr
+		redisCfg.Password = *redisPass
+		redisCfg.DBNum = *redisDB
+		redisCfg.GroupTag = *redisGroup
+		redisCfgs = append(redisCfgs, redisCfg)
+	}
+	err := p.SetConfig(cfg, redisCfgs...)
+	if err != nil {
+		return err
+	}
+
+	//recover chunk files
+	result, err := p.Recover(&conf.RecoverOption{
+		Repopulate: *repopulate,
+	})
+	if result == nil {
+		return err
+	}
+	for _, chunkResult := range result.Chunks {
+		walk := chunkResult.Walk
+		if walk == nil {
+			continue
+		}
+		fmt.Printf("%v, records:%v, live:%v, stale:%v, orphans:%v, free bytes:%v, skip bytes:%v, torn tail:%v\n",
+			chunkResult.FilePath, walk.Records, chunkResult.Live, chunkResult.Stale,
+			len(chunkResult.Orphans), walk.FreeBytes, walk.SkipBytes, walk.TornTail)
+		for _, fileBase := range chunkResult.Orphans {
+			fmt.Printf("  orphan md5:%v, offset:%v, size:%v, short url:%v\n",
+				fileBase.Md5, fileBase.Offset, fileBase.Size, chunkResult.Recovered[fileBase.Md5])
+		}
+	}
+	return err
+}
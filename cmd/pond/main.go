@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+ * pond command tool
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - offline maintain opt for data path
+ * - usage: pond <command> [options]
+ */
+
+//command func
+type command struct {
+	run  func(args []string) error
+	desc string
+}
+
+//all sub commands
+var commands = map[string]command{
+	"upgrade": {run: runUpgrade, desc: "rewrite legacy chunk headers as the latest version"},
+	"fsck":    {run: runFsck, desc: "check chunk files and rebuild lost meta data"},
+	"migrate": {run: runMigrate, desc: "copy meta data between meta stores"},
+}
+
+//print usage
+func usage() {
+	fmt.Println("usage: pond <command> [options]")
+	fmt.Println("commands:")
+	for name, cmd := range commands {
+		fmt.Printf("  %-10v %v\n", name, cmd.desc)
+	}
+}
+
+func main() {
+	//check
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	//run sub command
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(1)
+	}
+	err := cmd.run(os.Args[2:])
+	if err != nil {
+		fmt.Printf("%v failed, err:%v\n", os.Args[1], err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/storage"
+)
+
+/*
+ * migrate command
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - copy meta data between meta stores, search/redis/bolt
+ * - source store only read, verify counts and records by default
+ * - usage: pond migrate -path <data path> -from search -to redis -redis <address>
+ */
+
+//run migrate command
+func runMigrate(args []string) error {
+	var (
+		redisCfg *conf.RedisConfig
+	)
+	//parse options
+	flagSet := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dataPath := flagSet.String("path", "", "pond data path")
+	from := flagSet.String("from", define.MetaStoreOfSearch, "source meta store, search/redis/bolt")
+	to := flagSet.String("to", define.MetaStoreOfBolt, "dest meta store, search/redis/bolt")
+	pageSize := flagSet.Int("page", define.DefaultPageSizeMax, "records per page")
+	verify := flagSet.Bool("verify", true, "verify migrated records and counts")
+	redisAddr := flagSet.String("redis", "", "redis address, used by redis store")
+	redisPass := flagSet.String("redis-pass", "", "redis password")
+	redisDB := flagSet.Int("redis-db", 0, "redis db number")
+	redisGroup := flagSet.String("redis-group", define.DefaultRedisGroup, "redis group tag")
+	flagSet.Parse(args)
+	if *dataPath == "" {
+		flagSet.Usage()
+		return errors.New("data path is empty")
+	}
+	if (*from == define.MetaStoreOfRedis || *to == define.MetaStoreOfRedis) && *redisAddr == "" {
+		flagSet.Usage()
+		return errors.New("redis address is empty")
+	}
+
+	//setup config
+	p := pond.NewPond()
+	cfg := p.GenConfig()
+	cfg.DataPath = *dataPath
+	if *redisAddr != "" {
+		redisCfg = p.GenRedisConfig()
+		redisCfg.Address = *redisAddr
+		redisCfg.Password = *redisPass
+		redisCfg.DBNum = *redisDB
+		redisCfg.GroupTag = *redisGroup
+	}
+
+	//migrate meta data
+	opt := &conf.MigrateOption{
+		PageSize: *pageSize,
+		Verify: *verify,
+	}
+	result, err := storage.MigrateMetaByName(*from, *to, cfg, redisCfg, opt)
+	if result != nil {
+		fmt.Printf("%v -> %v, infos:%v/%v, bases:%v, removed:%v/%v, mismatched:%v\n",
+			*from, *to, result.Infos, result.SrcInfos, result.Bases,
+			result.Removed, result.SrcRemoved, result.Mismatched)
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * upgrade command
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - pond should stop before upgrade
+ * - usage: pond upgrade -path <data path> [-block <chunk block size>]
+ */
+
+//run upgrade command
+func runUpgrade(args []string) error {
+	//parse options
+	flagSet := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	dataPath := flagSet.String("path", "", "pond data path")
+	blockSize := flagSet.Int64("block", define.DefaultChunkBlockSize, "chunk block size")
+	flagSet.Parse(args)
+	if *dataPath == "" {
+		flagSet.Usage()
+		return errors.New("data path is empty")
+	}
+
+	//upgrade all chunk data files
+	results, err := chunk.UpgradeDataPath(*dataPath, *blockSize)
+	for _, result := range results {
+		walk := result.Walk
+		if walk == nil {
+			continue
+		}
+		fmt.Printf("%v, records:%v, upgraded:%v, skip bytes:%v, torn tail:%v\n",
+			result.FilePath, walk.Records, result.Upgraded, walk.SkipBytes, walk.TornTail)
+	}
+	return err
+}
@@ -1,5 +1,7 @@
 package conf
 
+import "github.com/andyzhou/pond/face"
+
 //pond base config
 type Config struct {
 	DataPath        string //data root path
@@ -11,6 +13,17 @@ type Config struct {
 	WriteLazy       bool   //switcher for lazy queue opt
 	CheckSame       bool   //switcher for check same data
 	UseMemoryMap	bool   //switcher for use memory map file
+	VerifyOnRead    bool   //switcher for verify data checksum when read
+	HeaderVersion   int    //chunk data header version, zero means the latest
+	Compress        string //compress for all data, zstd/gzip/snappy, empty means none
+	KeyProvider     face.IKeyProvider //data encrypt key provider, nil means no encrypt
+	CompactRatio    float64 //chunk dead space ratio for auto compact, zero means no auto compact
+	CompactRate     int64  //compact copy bytes per second, zero means no limit
+	MetaStore       string //meta data store name, search/redis/bolt or registered, empty means redis if redis config assigned, else search
+	LifecycleRules  []*LifecycleRule //lifecycle rules evaluated by inter ticker, nil means no lifecycle
+	LifecycleSeconds int   //lifecycle rules evaluate interval seconds, zero means one hour
+	UploadExpireSeconds int64 //incomplete upload session expire seconds, zero means one day
+	Versioning      bool   //switcher for file versioning, old data kept until versions purged
 	FileActiveHours int32  //chunk file active hours
 	InterQueueSize  int	   //for inter data save queue size, default 1024
 }
@@ -30,3 +43,38 @@ type RedisConfig struct {
 	FileInfoHashKeys int
 	FileBaseHashKeys int
 }
+
+//write option (optional)
+//used for one data write
+type WriteOption struct {
+	Name        string            //file name
+	ContentType string            //if empty, detect by data
+	Metadata    map[string]string //user meta data
+	TTL         int64             //time to live seconds, zero means never expire
+	ExpireAt    int64             //expire unix time, overwrite ttl if assigned
+	Compress    string            //compress of this data, overwrite config value
+}
+
+//lifecycle rule (optional)
+//file matched all not empty conditions will be acted
+type LifecycleRule struct {
+	Name        string //rule name, used in report
+	Action      string //delete or archive
+	ContentType string //file content type, empty means all
+	NamePrefix  string //file name prefix, empty means all
+	AgeSeconds  int64  //file created before this seconds, zero means no check
+	IdleSeconds int64  //file not accessed in this seconds, zero means no check
+}
+
+//recover option (optional)
+//used for rebuild meta data from chunk files
+type RecoverOption struct {
+	Repopulate bool //save recovered file base and info into meta data
+}
+
+//migrate option (optional)
+//used for copy meta data between stores
+type MigrateOption struct {
+	PageSize int  //records per page, zero means default
+	Verify   bool //verify migrated records and counts
+}
@@ -0,0 +1,167 @@
+package crypt
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * data encrypt key provider face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - application `IKeyProvider`
+ * - key ring keep all keys, key id stored in data header
+ * - rotate key for new data, old data still use old key
+ */
+
+//face info
+type KeyRing struct {
+	keys      map[uint32][]byte
+	currentId uint32
+	sync.RWMutex
+}
+
+//construct
+func NewKeyRing() *KeyRing {
+	this := &KeyRing{
+		keys: map[uint32][]byte{},
+	}
+	return this
+}
+
+//new static key provider
+//only one key with default key id
+func NewStaticKey(key []byte) (*KeyRing, error) {
+	this := NewKeyRing()
+	err := this.Rotate(define.DefaultEncryptKeyId, key)
+	return this, err
+}
+
+//new key file provider
+//file line format: `keyId:hexKey`, the max key id as current
+func NewKeyFile(filePath string) (*KeyRing, error) {
+	this := NewKeyRing()
+	err := this.LoadFile(filePath)
+	return this, err
+}
+
+//get current key for new data
+func (f *KeyRing) CurrentKey() (uint32, []byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	key, ok := f.keys[f.currentId]
+	if !ok {
+		return 0, nil, errors.New("no current key")
+	}
+	return f.currentId, key, nil
+}
+
+//get key by id
+func (f *KeyRing) GetKey(keyId uint32) ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	key, ok := f.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("key %v not found", keyId)
+	}
+	return key, nil
+}
+
+//add key, current key not changed
+func (f *KeyRing) AddKey(keyId uint32, key []byte) error {
+	//check
+	if keyId <= 0 {
+		return errors.New("invalid key id")
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return errors.New("key size should be 16, 24 or 32")
+	}
+
+	//add with locker
+	f.Lock()
+	defer f.Unlock()
+	f.keys[keyId] = key
+	return nil
+}
+
+//rotate new key as current key
+func (f *KeyRing) Rotate(keyId uint32, key []byte) error {
+	err := f.AddKey(keyId, key)
+	if err != nil {
+		return err
+	}
+	return f.SetCurrent(keyId)
+}
+
+//set current key id
+func (f *KeyRing) SetCurrent(keyId uint32) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.keys[keyId]; !ok {
+		return fmt.Errorf("key %v not found", keyId)
+	}
+	f.currentId = keyId
+	return nil
+}
+
+//load keys from file
+//the max key id of file as current
+func (f *KeyRing) LoadFile(filePath string) error {
+	var (
+		maxKeyId uint32
+	)
+	//check
+	if filePath == "" {
+		return errors.New("invalid parameter")
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	//read key line by line
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid key line `%v`", line)
+		}
+		keyId, subErr := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 32)
+		if subErr != nil {
+			return subErr
+		}
+		key, subErr := hex.DecodeString(strings.TrimSpace(parts[1]))
+		if subErr != nil {
+			return subErr
+		}
+		subErr = f.AddKey(uint32(keyId), key)
+		if subErr != nil {
+			return subErr
+		}
+		if uint32(keyId) > maxKeyId {
+			maxKeyId = uint32(keyId)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if maxKeyId <= 0 {
+		return errors.New("no key in file")
+	}
+	return f.SetCurrent(maxKeyId)
+}
@@ -105,6 +105,30 @@ func (d *HashData) GetOneValue(
 	return value, err
 }
 
+//check field exists or not
+func (d *HashData) IsFieldExists(
+		tag string,
+		field string,
+	) (bool, error) {
+	//check
+	if tag == "" || field == "" {
+		return false, errors.New("invalid parameter")
+	}
+
+	//get key and connect
+	connect, key, err := d.getKeyConnect(tag)
+	if err != nil {
+		return false, err
+	}
+
+	//create context
+	ctx, cancel := d.CreateContext()
+	defer cancel()
+
+	//check field
+	return connect.HExists(ctx, key, field).Result()
+}
+
 //get batch fields value
 func (d *HashData) GetValues(
 		tag string,
@@ -169,7 +169,7 @@ func (d *SortedData) GetBatchMembers(
 		zSlice, err = connect.ZRevRangeWithScores(ctx, key, int64(start), int64(end)).Result()
 	}else{
 		//asc order
-		zSlice, err = connect.ZRevRangeWithScores(ctx, key, int64(start), int64(end)).Result()
+		zSlice, err = connect.ZRangeWithScores(ctx, key, int64(start), int64(end)).Result()
 	}
 	if err != nil {
 		return nil, err
@@ -180,6 +180,163 @@ func (d *SortedData) GetBatchMembers(
 	return zSlice, nil
 }
 
+//get batch members by score range
+//min, max support redis range format, like "(100", "-inf"
+//count zero means no limit
+func (d *SortedData) GetMembersByScore(
+		tag string,
+		min, max string,
+		count int64,
+		isByDesc ...bool,
+	) ([]genRedis.Z, error) {
+	var (
+		isZRevRange bool
+		zSlice []genRedis.Z
+		err error
+	)
+	//check
+	if tag == "" || min == "" || max == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if isByDesc != nil && len(isByDesc) > 0 {
+		isZRevRange = isByDesc[0]
+	}
+
+	//get key and connect
+	connect, key, subErr := d.getKeyConnect(tag)
+	if subErr != nil {
+		return nil, subErr
+	}
+
+	//create context
+	ctx, cancel := d.CreateContext()
+	defer cancel()
+
+	//get batch data with score value
+	rangeBy := &genRedis.ZRangeBy{
+		Min: min,
+		Max: max,
+		Count: count,
+	}
+	if isZRevRange {
+		//desc order
+		zSlice, err = connect.ZRevRangeByScoreWithScores(ctx, key, rangeBy).Result()
+	}else{
+		//asc order
+		zSlice, err = connect.ZRangeByScoreWithScores(ctx, key, rangeBy).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return zSlice, nil
+}
+
+//get page members by score range
+//min, max support redis range format, like "(100", "-inf"
+func (d *SortedData) GetPageMembersByScore(
+		tag string,
+		min, max string,
+		offset, count int64,
+		isByDesc ...bool,
+	) ([]genRedis.Z, error) {
+	var (
+		isZRevRange bool
+		zSlice []genRedis.Z
+		err error
+	)
+	//check
+	if tag == "" || min == "" || max == "" || offset < 0 || count <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	if isByDesc != nil && len(isByDesc) > 0 {
+		isZRevRange = isByDesc[0]
+	}
+
+	//get key and connect
+	connect, key, subErr := d.getKeyConnect(tag)
+	if subErr != nil {
+		return nil, subErr
+	}
+
+	//create context
+	ctx, cancel := d.CreateContext()
+	defer cancel()
+
+	//get page data with score value
+	rangeBy := &genRedis.ZRangeBy{
+		Min: min,
+		Max: max,
+		Offset: offset,
+		Count: count,
+	}
+	if isZRevRange {
+		//desc order
+		zSlice, err = connect.ZRevRangeByScoreWithScores(ctx, key, rangeBy).Result()
+	}else{
+		//asc order
+		zSlice, err = connect.ZRangeByScoreWithScores(ctx, key, rangeBy).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return zSlice, nil
+}
+
+//get members count by score range
+//min, max support redis range format, like "(100", "-inf"
+func (d *SortedData) GetCountByScore(
+		tag string,
+		min, max string,
+	) (int64, error) {
+	//check
+	if tag == "" || min == "" || max == "" {
+		return 0, errors.New("invalid parameter")
+	}
+
+	//get key and connect
+	connect, key, err := d.getKeyConnect(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	//create context
+	ctx, cancel := d.CreateContext()
+	defer cancel()
+
+	//get count
+	total, subErr := connect.ZCount(ctx, key, min, max).Result()
+	return total, subErr
+}
+
+//get batch members by lex range, all members with same score
+//min, max support redis lex format, like "[abc", "(abc", "-", "+"
+func (d *SortedData) GetMembersByLex(
+		tag string,
+		min, max string,
+	) ([]string, error) {
+	//check
+	if tag == "" || min == "" || max == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//get key and connect
+	connect, key, err := d.getKeyConnect(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	//create context
+	ctx, cancel := d.CreateContext()
+	defer cancel()
+
+	//get batch members
+	rangeBy := &genRedis.ZRangeBy{
+		Min: min,
+		Max: max,
+	}
+	return connect.ZRangeByLex(ctx, key, rangeBy).Result()
+}
+
 //remove member
 func (d *SortedData) RemoveMember(
 	tag string,
@@ -3,12 +3,14 @@ package data
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/andyzhou/pond/conf"
 	"github.com/andyzhou/pond/data/base"
 	"github.com/andyzhou/pond/define"
 	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/utils"
 	"github.com/andyzhou/tinylib/util"
 	genRedis "github.com/go-redis/redis/v8"
 )
@@ -19,6 +21,9 @@ import (
  * file base and info data
  * - use batch hash table keys
  * - hashed by the first two element of short url or md5
+ * - file list sorted by create time, same time sorted by short url
+ * - size, chunk, expire, name and content type kept in sorted set for query
+ * - query only by create time or size range paged by sorted set
  */
 
 //data face
@@ -29,6 +34,7 @@ type FileData struct {
 	initDone bool
 	base.Base
 	util.Util
+	utils.Utils
 }
 
 //construct
@@ -51,7 +57,7 @@ func (f *FileData) LoadRemovedFileBase(
 		page = define.DefaultPage
 	}
 	start := (page - 1) * pageSize
-	end := start + pageSize
+	end := start + pageSize - 1
 	zSlice, err := f.sorted.GetBatchMembers(
 						f.getRemovedFileBaseKey(),
 						start,
@@ -60,6 +66,11 @@ func (f *FileData) LoadRemovedFileBase(
 	return zSlice, err
 }
 
+//get removed file base count
+func (f *FileData) GetRemovedFileBaseCount() (int64, error) {
+	return f.sorted.GetTotalCount(f.getRemovedFileBaseKey())
+}
+
 //remove removed file base
 func (f *FileData) RemoveRemovedFileBase(md5 string) error {
 	//check
@@ -87,6 +98,145 @@ func (f *FileData) AddRemovedFileBase(md5 string, blocks int64) error {
 //api for info
 ///////////////
 
+//get file info count
+func (f *FileData) GetInfoCount() (int64, error) {
+	return f.sorted.GetTotalCount(f.getFileListKey())
+}
+
+//get file info list by cursor
+//cursor is create time and short url of last file info
+//same time file info sorted by short url desc
+func (f *FileData) GetInfoListByCursor(
+		createAt int64,
+		shortUrl string,
+		pageSize int,
+	) ([]*json.FileInfoJson, error) {
+	//check
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	listKeyTag := f.getFileListKey()
+	shortUrls := make([]string, 0)
+
+	//get same time file after cursor
+	maxScore := "+inf"
+	if shortUrl != "" {
+		sameTime := fmt.Sprintf("%v", createAt)
+		zSlice, err := f.sorted.GetMembersByScore(listKeyTag, sameTime, sameTime, 0, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, z := range zSlice {
+			member, _ := z.Member.(string)
+			if member != "" && member < shortUrl && len(shortUrls) < pageSize {
+				shortUrls = append(shortUrls, member)
+			}
+		}
+		maxScore = fmt.Sprintf("(%v", createAt)
+	}
+
+	//get older file
+	if len(shortUrls) < pageSize {
+		count := int64(pageSize - len(shortUrls))
+		zSlice, err := f.sorted.GetMembersByScore(listKeyTag, "-inf", maxScore, count, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, z := range zSlice {
+			member, _ := z.Member.(string)
+			if member != "" {
+				shortUrls = append(shortUrls, member)
+			}
+		}
+	}
+
+	//format result
+	result := make([]*json.FileInfoJson, 0)
+	for _, v := range shortUrls {
+		fileInfo, _ := f.GetInfo(v)
+		if fileInfo == nil || fileInfo.ShortUrl != v {
+			continue
+		}
+		result = append(result, fileInfo)
+	}
+	return result, nil
+}
+
+//get file info list by query
+//query only by create time or size range, paged by sorted set
+//else candidates picked from one sorted set, then exact matched, sorted and paged
+func (f *FileData) GetInfoListByQuery(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	var (
+		shortUrls []string
+		err error
+	)
+	//check
+	if query == nil {
+		return 0, nil, errors.New("invalid parameter")
+	}
+
+	//page by sorted set of single range
+	isOtherEmpty := query.ContentType == "" && query.NamePrefix == "" &&
+		query.ChunkFileId <= 0 && query.ExpireAtMax <= 0
+	isSizeEmpty := query.SizeMin <= 0 && query.SizeMax <= 0
+	isCreateAtEmpty := query.CreateAtMin <= 0 && query.CreateAtMax <= 0
+	switch {
+	case isOtherEmpty && isSizeEmpty && query.OrderBy != define.FileQueryOrderBySize &&
+		query.OrderBy != define.FileQueryOrderByName:
+		return f.getInfoListByScore(f.getFileListKey(), query.CreateAtMin, query.CreateAtMax, query)
+	case isOtherEmpty && isCreateAtEmpty && query.OrderBy == define.FileQueryOrderBySize:
+		return f.getInfoListByScore(f.getFileSizeKey(), query.SizeMin, query.SizeMax, query)
+	}
+
+	//pick candidates by most selective index
+	switch {
+	case query.ExpireAtMax > 0:
+		shortUrls, err = f.getMembersByScore(f.getFileExpireKey(), 1, query.ExpireAtMax)
+	case query.ContentType != "":
+		shortUrls, err = f.getMembersByScore(
+							f.getFileTypeKey(query.ContentType),
+							query.CreateAtMin,
+							query.CreateAtMax)
+	case query.NamePrefix != "":
+		var members []string
+		members, err = f.sorted.GetMembersByLex(
+							f.getFileNameKey(),
+							"[" + query.NamePrefix,
+							"[" + query.NamePrefix + "\xff")
+		for _, v := range members {
+			idx := strings.LastIndex(v, define.RedisFileNameSplit)
+			if idx >= 0 {
+				shortUrls = append(shortUrls, v[idx+1:])
+			}
+		}
+	case query.ChunkFileId > 0:
+		shortUrls, err = f.getMembersByScore(f.getFileChunkKey(), query.ChunkFileId, query.ChunkFileId)
+	case query.SizeMin > 0 || query.SizeMax > 0:
+		shortUrls, err = f.getMembersByScore(f.getFileSizeKey(), query.SizeMin, query.SizeMax)
+	default:
+		shortUrls, err = f.getMembersByScore(f.getFileListKey(), query.CreateAtMin, query.CreateAtMax)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	//exact match
+	result := make([]*json.FileInfoJson, 0)
+	for _, v := range shortUrls {
+		fileInfo, _ := f.GetInfo(v)
+		if fileInfo == nil || fileInfo.ShortUrl != v {
+			continue
+		}
+		if f.IsFileMatched(query, fileInfo) {
+			result = append(result, fileInfo)
+		}
+	}
+	f.SortFiles(query, result)
+	return int64(len(result)), f.PageFiles(query, result), nil
+}
+
 //get file info list
 func (f *FileData) GetInfoList(page, pageSize int) ([]*json.FileInfoJson, error) {
 	//setup start, end value
@@ -97,7 +247,7 @@ func (f *FileData) GetInfoList(page, pageSize int) ([]*json.FileInfoJson, error)
 		pageSize = define.DefaultPageSize
 	}
 	start := (page - 1) *pageSize
-	end := start + pageSize
+	end := start + pageSize - 1
 
 	//get from sort list
 	listKeyTag := f.getFileListKey()
@@ -136,6 +286,9 @@ func (f *FileData) DelInfo(shortUrl string) error {
 		return err
 	}
 
+	//get old info for query index
+	oldInfo, _ := f.GetInfo(shortUrl)
+
 	//del from redis
 	field := shortUrl
 	err = f.hash.DelFields(keyTag, field)
@@ -146,7 +299,29 @@ func (f *FileData) DelInfo(shortUrl string) error {
 	//remove from file list
 	listKeyTag := f.getFileListKey()
 	err = f.sorted.RemoveMember(listKeyTag, shortUrl)
-	return err
+	if err != nil {
+		return err
+	}
+
+	//remove from query index
+	return f.delQueryIndex(shortUrl, oldInfo)
+}
+
+//check file info exists or not
+func (f *FileData) IsInfoExists(shortUrl string) (bool, error) {
+	//check
+	if shortUrl == "" {
+		return false, errors.New("invalid parameter")
+	}
+
+	//get key tag
+	keyTag, err := f.getFileInfoKey(shortUrl)
+	if err != nil {
+		return false, err
+	}
+
+	//check from redis
+	return f.hash.IsFieldExists(keyTag, shortUrl)
 }
 
 //get file info
@@ -188,6 +363,9 @@ func (f *FileData) AddInfo(obj *json.FileInfoJson) error {
 		return err
 	}
 
+	//get old info for query index
+	oldInfo, _ := f.GetInfo(obj.ShortUrl)
+
 	//encode json string
 	jsonStr, _ := obj.Encode2Str(obj)
 
@@ -199,14 +377,29 @@ func (f *FileData) AddInfo(obj *json.FileInfoJson) error {
 	}
 
 	//add new file short url into sorted set
-	//short url as member, time as score
+	//short url as member, create time as score
 	listKeyTag := f.getFileListKey()
+	createAt := obj.CreateAt
+	if createAt <= 0 {
+		createAt = time.Now().Unix()
+	}
 	member := &genRedis.Z{
 		Member: obj.ShortUrl,
-		Score: float64(time.Now().Nanosecond()),
+		Score: float64(createAt),
 	}
 	err = f.sorted.AddMembers(listKeyTag, member)
-	return err
+	if err != nil {
+		return err
+	}
+
+	//update query index
+	if oldInfo != nil && (oldInfo.Name != obj.Name || oldInfo.ContentType != obj.ContentType) {
+		err = f.delQueryIndex(obj.ShortUrl, oldInfo)
+		if err != nil {
+			return err
+		}
+	}
+	return f.addQueryIndex(obj, float64(createAt))
 }
 
 ///////////////
@@ -313,6 +506,161 @@ func (f *FileData) getFileListKey() string {
 	return define.RedisKeyFilesList
 }
 
+//get file query index key tag
+func (f *FileData) getFileSizeKey() string {
+	return define.RedisKeyFilesSize
+}
+
+func (f *FileData) getFileChunkKey() string {
+	return define.RedisKeyFilesChunk
+}
+
+func (f *FileData) getFileExpireKey() string {
+	return define.RedisKeyFilesExpire
+}
+
+func (f *FileData) getFileNameKey() string {
+	return define.RedisKeyFilesName
+}
+
+func (f *FileData) getFileTypeKey(contentType string) string {
+	return fmt.Sprintf(define.RedisKeyFilesTypePattern, contentType)
+}
+
+//get members by score range, zero value means no limit
+func (f *FileData) getMembersByScore(tag string, min, max int64) ([]string, error) {
+	minScore, maxScore := "-inf", "+inf"
+	if min > 0 {
+		minScore = fmt.Sprintf("%v", min)
+	}
+	if max > 0 {
+		maxScore = fmt.Sprintf("%v", max)
+	}
+	zSlice, err := f.sorted.GetMembersByScore(tag, minScore, maxScore, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, z := range zSlice {
+		member, _ := z.Member.(string)
+		if member != "" {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+//get file info list of score range by sorted set page
+//same score sorted by short url, the same as sorted files
+//return total, []*FileInfoJson, error
+func (f *FileData) getInfoListByScore(
+		tag string,
+		min, max int64,
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	page, pageSize := query.Page, query.PageSize
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	minScore, maxScore := "-inf", "+inf"
+	if min > 0 {
+		minScore = fmt.Sprintf("%v", min)
+	}
+	if max > 0 {
+		maxScore = fmt.Sprintf("%v", max)
+	}
+
+	//get total and page members
+	total, err := f.sorted.GetCountByScore(tag, minScore, maxScore)
+	if err != nil {
+		return 0, nil, err
+	}
+	offset := int64((page - 1) * pageSize)
+	if offset >= total {
+		return total, []*json.FileInfoJson{}, nil
+	}
+	zSlice, err := f.sorted.GetPageMembersByScore(tag, minScore, maxScore, offset, int64(pageSize), !query.Asc)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	//format result
+	result := make([]*json.FileInfoJson, 0)
+	for _, z := range zSlice {
+		member, _ := z.Member.(string)
+		if member == "" {
+			continue
+		}
+		fileInfo, _ := f.GetInfo(member)
+		if fileInfo == nil || fileInfo.ShortUrl != member {
+			continue
+		}
+		result = append(result, fileInfo)
+	}
+	return total, result, nil
+}
+
+//add file info into query index
+func (f *FileData) addQueryIndex(obj *json.FileInfoJson, createAt float64) error {
+	err := f.sorted.AddMembers(f.getFileSizeKey(), f.sorted.GenMember(obj.ShortUrl, float64(obj.Size)))
+	if err != nil {
+		return err
+	}
+	err = f.sorted.AddMembers(f.getFileChunkKey(), f.sorted.GenMember(obj.ShortUrl, float64(obj.ChunkFileId)))
+	if err != nil {
+		return err
+	}
+	if obj.ExpireAt > 0 {
+		err = f.sorted.AddMembers(f.getFileExpireKey(), f.sorted.GenMember(obj.ShortUrl, float64(obj.ExpireAt)))
+	}else{
+		err = f.sorted.RemoveMember(f.getFileExpireKey(), obj.ShortUrl)
+	}
+	if err != nil {
+		return err
+	}
+	if obj.Name != "" {
+		member := fmt.Sprintf(define.RedisFileNamePara, obj.Name, define.RedisFileNameSplit, obj.ShortUrl)
+		err = f.sorted.AddMembers(f.getFileNameKey(), f.sorted.GenMember(member, 0))
+		if err != nil {
+			return err
+		}
+	}
+	if obj.ContentType != "" {
+		err = f.sorted.AddMembers(f.getFileTypeKey(obj.ContentType), f.sorted.GenMember(obj.ShortUrl, createAt))
+	}
+	return err
+}
+
+//del file info from query index
+func (f *FileData) delQueryIndex(shortUrl string, oldInfo *json.FileInfoJson) error {
+	err := f.sorted.RemoveMember(f.getFileSizeKey(), shortUrl)
+	if err != nil {
+		return err
+	}
+	err = f.sorted.RemoveMember(f.getFileChunkKey(), shortUrl)
+	if err != nil {
+		return err
+	}
+	err = f.sorted.RemoveMember(f.getFileExpireKey(), shortUrl)
+	if err != nil || oldInfo == nil {
+		return err
+	}
+	if oldInfo.Name != "" {
+		member := fmt.Sprintf(define.RedisFileNamePara, oldInfo.Name, define.RedisFileNameSplit, shortUrl)
+		err = f.sorted.RemoveMember(f.getFileNameKey(), member)
+		if err != nil {
+			return err
+		}
+	}
+	if oldInfo.ContentType != "" {
+		err = f.sorted.RemoveMember(f.getFileTypeKey(oldInfo.ContentType), shortUrl)
+	}
+	return err
+}
+
 //get file info key tag
 func (f *FileData) getFileInfoKey(shortUrl string) (string, error) {
 	//check
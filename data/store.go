@@ -0,0 +1,204 @@
+package data
+
+import (
+	"errors"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * meta store face base on redis
+ * - file info and base storage into redis hash
+ * - removed file base md5 kept in sorted set
+ * - upload session storage into redis hash
+ */
+
+//face info
+type Store struct {
+	data *InterRedisData
+}
+
+//construct
+func NewStore(cfg *conf.RedisConfig) (*Store, error) {
+	//check
+	if cfg == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	this := &Store{
+		data: NewInterRedisData(),
+	}
+	this.data.SetRedisConf(cfg)
+	return this, nil
+}
+
+//quit
+func (f *Store) Quit() {
+}
+
+///////////////
+//api for info
+///////////////
+
+//get file info
+func (f *Store) GetInfo(shortUrl string) (*json.FileInfoJson, error) {
+	return f.data.GetFile().GetInfo(shortUrl)
+}
+
+//save file info
+func (f *Store) PutInfo(obj *json.FileInfoJson) error {
+	return f.data.GetFile().AddInfo(obj)
+}
+
+//del file info
+func (f *Store) DelInfo(shortUrl string) error {
+	return f.data.GetFile().DelInfo(shortUrl)
+}
+
+//check file info exists or not
+func (f *Store) IsInfoExists(shortUrl string) (bool, error) {
+	return f.data.GetFile().IsInfoExists(shortUrl)
+}
+
+//get batch file info by create time desc
+func (f *Store) ListInfoByTime(page, pageSize int) (int64, []*json.FileInfoJson, error) {
+	fileData := f.data.GetFile()
+	total, err := fileData.GetInfoCount()
+	if err != nil {
+		return 0, nil, err
+	}
+	filesInfo, err := fileData.GetInfoList(page, pageSize)
+	return total, filesInfo, err
+}
+
+//get batch file info by query
+func (f *Store) ListInfoByQuery(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	return f.data.GetFile().GetInfoListByQuery(query)
+}
+
+//get batch file info after cursor by create time desc
+func (f *Store) ListInfoByCursor(
+		createAt int64,
+		shortUrl string,
+		pageSize int,
+	) (int64, []*json.FileInfoJson, error) {
+	fileData := f.data.GetFile()
+	total, err := fileData.GetInfoCount()
+	if err != nil {
+		return 0, nil, err
+	}
+	filesInfo, err := fileData.GetInfoListByCursor(createAt, shortUrl, pageSize)
+	return total, filesInfo, err
+}
+
+///////////////
+//api for base
+///////////////
+
+//get file base
+func (f *Store) GetBase(md5 string) (*json.FileBaseJson, error) {
+	return f.data.GetFile().GetBase(md5)
+}
+
+//save file base
+//sync removed md5 sorted set
+func (f *Store) PutBase(obj *json.FileBaseJson) error {
+	fileData := f.data.GetFile()
+	err := fileData.AddBase(obj)
+	if err != nil {
+		return err
+	}
+	if obj.Removed && obj.Blocks > 0 {
+		return fileData.AddRemovedFileBase(obj.Md5, obj.Blocks)
+	}
+	return fileData.RemoveRemovedFileBase(obj.Md5)
+}
+
+//del file base
+func (f *Store) DelBase(md5 string) error {
+	fileData := f.data.GetFile()
+	err := fileData.DelBase(md5)
+	if err != nil {
+		return err
+	}
+	return fileData.RemoveRemovedFileBase(md5)
+}
+
+//get batch removed file base
+//sort by blocks asc
+func (f *Store) ListRemovedBase(page, pageSize int) (int64, []*json.FileBaseJson, error) {
+	fileData := f.data.GetFile()
+	total, err := fileData.GetRemovedFileBaseCount()
+	if err != nil {
+		return 0, nil, err
+	}
+	zSlice, err := fileData.LoadRemovedFileBase(page, pageSize)
+	if err != nil {
+		return total, nil, err
+	}
+	result := make([]*json.FileBaseJson, 0)
+	for _, z := range zSlice {
+		md5, _ := z.Member.(string)
+		if md5 == "" {
+			continue
+		}
+		fileBase, _ := fileData.GetBase(md5)
+		if fileBase == nil || !fileBase.Removed {
+			continue
+		}
+		result = append(result, fileBase)
+	}
+	return total, result, nil
+}
+
+/////////////////
+//api for upload
+/////////////////
+
+//get upload session
+func (f *Store) GetUpload(uploadId string) (*json.UploadJson, error) {
+	return f.data.GetFile().GetUpload(uploadId)
+}
+
+//save upload session
+func (f *Store) PutUpload(obj *json.UploadJson) error {
+	return f.data.GetFile().AddUpload(obj)
+}
+
+//del upload session
+func (f *Store) DelUpload(uploadId string) error {
+	return f.data.GetFile().DelUpload(uploadId)
+}
+
+//get batch upload session
+func (f *Store) ListUploads(page, pageSize int) (int64, []*json.UploadJson, error) {
+	return f.data.GetFile().GetUploadList(page, pageSize)
+}
+
+/////////////////
+//api for version
+/////////////////
+
+//get file versions
+func (f *Store) GetVersions(shortUrl string) (*json.FileVersionsJson, error) {
+	return f.data.GetFile().GetVersions(shortUrl)
+}
+
+//save file versions
+func (f *Store) PutVersions(obj *json.FileVersionsJson) error {
+	return f.data.GetFile().AddVersions(obj)
+}
+
+//del file versions
+func (f *Store) DelVersions(shortUrl string) error {
+	return f.data.GetFile().DelVersions(shortUrl)
+}
+
+//get batch file versions
+func (f *Store) ListVersions(page, pageSize int) (int64, []*json.FileVersionsJson, error) {
+	return f.data.GetFile().GetVersionsList(page, pageSize)
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * upload session data
+ * - all sessions kept in one hash, upload id as field
+ */
+
+//get upload session
+func (f *FileData) GetUpload(uploadId string) (*json.UploadJson, error) {
+	//check
+	if uploadId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//get from redis
+	jsonStr, err := f.hash.GetOneValue(f.getUploadKey(), uploadId)
+	if err != nil || jsonStr == "" {
+		return nil, err
+	}
+
+	//decode obj
+	obj := json.NewUploadJson()
+	err = obj.Decode([]byte(jsonStr), obj)
+	return obj, err
+}
+
+//add upload session
+func (f *FileData) AddUpload(obj *json.UploadJson) error {
+	//check
+	if obj == nil || obj.UploadId == "" {
+		return errors.New("invalid parameter")
+	}
+
+	//encode json string
+	jsonStr, err := obj.Encode2Str(obj)
+	if err != nil {
+		return err
+	}
+
+	//save into redis
+	return f.hash.SetOneValue(f.getUploadKey(), obj.UploadId, jsonStr)
+}
+
+//del upload session
+func (f *FileData) DelUpload(uploadId string) error {
+	//check
+	if uploadId == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.hash.DelFields(f.getUploadKey(), uploadId)
+}
+
+//get batch upload session
+//sort by upload id
+func (f *FileData) GetUploadList(page, pageSize int) (int64, []*json.UploadJson, error) {
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+
+	//get all sessions
+	fieldMap, err := f.hash.GetAllFields(f.getUploadKey())
+	if err != nil {
+		return 0, nil, err
+	}
+	uploadIds := make([]string, 0)
+	for uploadId := range fieldMap {
+		uploadIds = append(uploadIds, uploadId)
+	}
+	sort.Strings(uploadIds)
+
+	//format result
+	result := make([]*json.UploadJson, 0)
+	total := int64(len(uploadIds))
+	start := (page - 1) * pageSize
+	for i := start; i < len(uploadIds) && len(result) < pageSize; i++ {
+		obj := json.NewUploadJson()
+		err = obj.Decode([]byte(fieldMap[uploadIds[i]]), obj)
+		if err != nil {
+			return total, nil, err
+		}
+		result = append(result, obj)
+	}
+	return total, result, nil
+}
+
+//////////////////
+//private func
+//////////////////
+
+//get upload session key tag
+func (f *FileData) getUploadKey() string {
+	return define.RedisKeyUploads
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * file versions data
+ * - all versions kept in one hash, short url as field
+ */
+
+//get file versions
+func (f *FileData) GetVersions(shortUrl string) (*json.FileVersionsJson, error) {
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//get from redis
+	jsonStr, err := f.hash.GetOneValue(f.getVersionKey(), shortUrl)
+	if err != nil || jsonStr == "" {
+		return nil, err
+	}
+
+	//decode obj
+	obj := json.NewFileVersionsJson()
+	err = obj.Decode([]byte(jsonStr), obj)
+	return obj, err
+}
+
+//add file versions
+func (f *FileData) AddVersions(obj *json.FileVersionsJson) error {
+	//check
+	if obj == nil || obj.ShortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+
+	//encode json string
+	jsonStr, err := obj.Encode2Str(obj)
+	if err != nil {
+		return err
+	}
+
+	//save into redis
+	return f.hash.SetOneValue(f.getVersionKey(), obj.ShortUrl, jsonStr)
+}
+
+//del file versions
+func (f *FileData) DelVersions(shortUrl string) error {
+	//check
+	if shortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.hash.DelFields(f.getVersionKey(), shortUrl)
+}
+
+//get batch file versions
+//sort by short url
+func (f *FileData) GetVersionsList(page, pageSize int) (int64, []*json.FileVersionsJson, error) {
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+
+	//get all versions
+	fieldMap, err := f.hash.GetAllFields(f.getVersionKey())
+	if err != nil {
+		return 0, nil, err
+	}
+	shortUrls := make([]string, 0)
+	for shortUrl := range fieldMap {
+		shortUrls = append(shortUrls, shortUrl)
+	}
+	sort.Strings(shortUrls)
+
+	//format result
+	result := make([]*json.FileVersionsJson, 0)
+	total := int64(len(shortUrls))
+	start := (page - 1) * pageSize
+	for i := start; i < len(shortUrls) && len(result) < pageSize; i++ {
+		obj := json.NewFileVersionsJson()
+		err = obj.Decode([]byte(fieldMap[shortUrls[i]]), obj)
+		if err != nil {
+			return total, nil, err
+		}
+		result = append(result, obj)
+	}
+	return total, result, nil
+}
+
+//////////////////
+//private func
+//////////////////
+
+//get file versions key tag
+func (f *FileData) getVersionKey() string {
+	return define.RedisKeyVersions
+}
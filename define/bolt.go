@@ -0,0 +1,26 @@
+package define
+
+//file
+const (
+	BoltDbFile        = "meta.db"
+	BoltOpenTimeout   = 5 //xx seconds
+	BoltTimeKeyPrefix = 8 //create or expire time bytes of time key
+)
+
+//bucket
+const (
+	BoltBucketOfFileInfo = "fileInfo"    //shortUrl -> file info
+	BoltBucketOfFileBase = "fileBase"    //md5 -> file base
+	BoltBucketOfFileTime = "fileTime"    //createAt + shortUrl -> nil
+	BoltBucketOfExpire   = "fileExpire"  //expireAt + shortUrl -> nil
+	BoltBucketOfRemoved  = "removedBase" //md5 -> blocks
+	BoltBucketOfStat     = "stat"        //stat key -> count
+	BoltBucketOfUpload   = "upload"      //uploadId -> upload session
+	BoltBucketOfVersion  = "version"     //shortUrl -> file versions
+)
+
+//stat key
+const (
+	BoltStatOfFileInfo = "fileInfo"
+	BoltStatOfRemoved  = "removedBase"
+)
@@ -9,8 +9,9 @@ const (
 
 // file para
 const (
-	ChunkMetaFilePara = "chunk-%v.meta" //chunk meta file
-	ChunkDataFilePara = "chunk-%v.data" //chunk data file
+	ChunkMetaFilePara  = "chunk-%v.meta"  //chunk meta file
+	ChunkDataFilePara  = "chunk-%v.data"  //chunk data file
+	ChunkSpoolFilePara = "stream-*.spool" //temp spool file of stream
 )
 
 // data size
@@ -30,4 +31,18 @@ const (
 	DefaultChunkActiveHours  = 4 //xx hours
 	DefaultChunkMetaTicker   = 5 //xx seconds
 	DefaultChunkExceedBlocks = 2 //exceed max blocks
+	DefaultStreamBuffSize    = 64 * DataSizeOfKB //stream opt buff size
+)
+
+// data header flag
+const (
+	HeaderFlagOfEncrypt uint8 = 1 << iota //data encrypted
+)
+
+// encrypt
+const (
+	DefaultEncryptKeyId = 1
+	EncryptSegmentSize  = 64 * DataSizeOfKB //plain data size of one sealed segment
+	EncryptNonceSize    = 12
+	EncryptTagSize      = 16
 )
@@ -0,0 +1,17 @@
+package define
+
+// compress name
+const (
+	CompressOfNone   = "none"
+	CompressOfZstd   = "zstd"
+	CompressOfGzip   = "gzip"
+	CompressOfSnappy = "snappy"
+)
+
+// compress codec, stored in data header
+const (
+	CodecOfNone uint8 = iota
+	CodecOfZstd
+	CodecOfGzip
+	CodecOfSnappy
+)
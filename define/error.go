@@ -0,0 +1,34 @@
+package define
+
+import (
+	"errors"
+	"fmt"
+)
+
+// inter error
+var (
+	ErrFileNotFound  = errors.New("file not found")
+	ErrDataCorrupted = errors.New("data corrupted")
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrSpaceNotEnough = errors.New("space not enough")
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+//data corrupted error
+//returned when read data not match the stored checksum
+type DataCorruptedError struct {
+	ShortUrl string
+	Expect   string
+	Actual   string
+}
+
+//get error message
+func (e *DataCorruptedError) Error() string {
+	return fmt.Sprintf("data of %v corrupted, expect checksum:%v, actual:%v",
+		e.ShortUrl, e.Expect, e.Actual)
+}
+
+//match `ErrDataCorrupted` by errors.Is
+func (e *DataCorruptedError) Is(target error) bool {
+	return target == ErrDataCorrupted
+}
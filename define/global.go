@@ -4,6 +4,7 @@ package define
 const (
 	SubDirOfSearch = "search"
 	SubDirOfFile   = "file"
+	SubDirOfBolt   = "bolt"
 )
 
 // seconds
@@ -17,6 +18,7 @@ const (
 const (
 	DefaultPacketMaxSize = 2048 //2KB
 	DefaultQueueSize     = 1024
+	ContentTypeSniffSize = 512 //used for detect content type
 )
 
 // others
@@ -0,0 +1,14 @@
+package define
+
+// lifecycle action
+const (
+	LifecycleActionOfDelete  = "delete"
+	LifecycleActionOfArchive = "archive"
+)
+
+// default
+const (
+	DefaultLifecycleSeconds   = SecondsOfHour //rules evaluate interval
+	FileAccessUpdateSeconds   = SecondsOfHour //min interval of file access time update
+	LifecycleReportMaxActions = 1000          //max action details kept in report
+)
@@ -3,10 +3,33 @@ package define
 const (
 	ChunksMetaFile    = "chunks.meta"
 	ChunksRemovedFile = "chunkRemoved.meta"
+	ChunksExtentFile  = "chunkExtent.meta"
+	ChunksWalFile     = "chunkWal.log"
+)
+
+//meta store name
+const (
+	MetaStoreOfSearch = "search"
+	MetaStoreOfRedis  = "redis"
+	MetaStoreOfBolt   = "bolt"
+)
+
+//wal operate
+const (
+	WalOpOfWrite = iota + 1
+	WalOpOfOverwrite
+	WalOpOfDelete
+	WalOpOfReuseRemoved
 )
 
 // default value
 const (
 	MetaAutoSaveTicker    = 5  //xx seconds
 	RemovedAutoSaveTicker = 10 //xx seconds
+	CompactTickerSeconds  = 60 //xx seconds
+	DefaultCompactRatio   = 0.5 //dead space ratio of chunk
+	WalEntryHeadSize      = 8   //entry data size + crc32
+	WalEntryMaxSize       = 1 << 20
+	WalCheckpointSize     = 16 << 20 //wal bytes of auto checkpoint
+	WalCheckpointSeconds  = 60       //xx seconds, age of auto checkpoint
 )
@@ -0,0 +1,13 @@
+package define
+
+// file query order
+const (
+	FileQueryOrderByCreateAt = "createAt"
+	FileQueryOrderBySize     = "size"
+	FileQueryOrderByName     = "name"
+)
+
+// default
+const (
+	FileQueryScanSize = 1000 //records per scan when filter by store
+)
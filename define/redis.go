@@ -21,6 +21,19 @@ const (
 	RedisKeyFileBasePattern = "fileBase:%v" //*:{hashIdx}
 	RedisKeyFilesList       = "filesList"   //sorted data, shortUrl -> createTime
 	RedisKeyRemovedFileBase = "removedBase"
+	RedisKeyFilesSize       = "filesSize"    //sorted data, shortUrl -> size
+	RedisKeyFilesChunk      = "filesChunk"   //sorted data, shortUrl -> chunk file id
+	RedisKeyFilesName       = "filesName"    //sorted data by lex, name:shortUrl -> 0
+	RedisKeyFilesExpire     = "filesExpire"  //sorted data, shortUrl -> expire time
+	RedisKeyFilesTypePattern = "filesType:%v" //sorted data, *:{contentType}, shortUrl -> createTime
+	RedisKeyUploads          = "uploads"      //hash data, uploadId -> upload session
+	RedisKeyVersions         = "versions"     //hash data, shortUrl -> file versions
+)
+
+//file name member of query index
+const (
+	RedisFileNamePara  = "%v%v%v" //{name}:{shortUrl}
+	RedisFileNameSplit = ":"
 )
 
 //redis key and num info
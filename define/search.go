@@ -4,6 +4,8 @@ package define
 const (
 	SearchIndexOfFileBase = "pond-file-base"
 	SearchIndexOfFileInfo = "pond-file-info"
+	SearchIndexOfUpload   = "pond-upload"
+	SearchIndexOfVersion  = "pond-version"
 )
 
 // doc filed
@@ -12,6 +14,11 @@ const (
 	SearchFieldOfRemoved  = "removed"
 	SearchFieldOfSize	  = "size"
 	SearchFieldOfBlocks   = "blocks"
+	SearchFieldOfMetadata = "metadata"
+	SearchFieldOfContentType = "contentType"
+	SearchFieldOfChunkFileId = "chunkFileId"
+	SearchFieldOfExpireAt    = "expireAt"
+	SearchSortOfDocIdDesc    = "-_id" //doc id is short url
 )
 
 // default
@@ -21,3 +28,9 @@ const (
 	DefaultPageSizeMax = 50
 	DefaultFileAppoint = 1
 )
+
+// file list cursor, format: createAt:shortUrl
+const (
+	FileCursorPara  = "%v%v%v"
+	FileCursorSplit = ":"
+)
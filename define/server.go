@@ -0,0 +1,29 @@
+package define
+
+// server path and para
+const (
+	ServerPathOfRoot  = "/"
+	ServerPathOfFiles = "/files"
+	ServerParaOfPage     = "page"
+	ServerParaOfPageSize = "pageSize"
+	ServerParaOfName     = "name"
+	ServerParaOfCursor   = "cursor"
+)
+
+// server header
+const (
+	ServerHeaderOfMetaPrefix = "X-Pond-Meta-"
+	ServerHeaderOfTTL        = "X-Pond-Ttl"
+	ServerHeaderOfExpireAt   = "X-Pond-Expire-At"
+	ServerHeaderOfCompress   = "X-Pond-Compress"
+)
+
+// server etag, changed when data written
+const (
+	ServerETagPara = "\"%v-%v-%x\"" //md5-size-crc32
+)
+
+// default
+const (
+	DefaultServerTimeOut = 60 //xx seconds
+)
@@ -0,0 +1,8 @@
+package define
+
+// default
+const (
+	DefaultUploadExpireSeconds = SecondsOfDay //incomplete upload session expire seconds
+	UploadPartMax              = 10000        //max part number of one upload
+	UploadMd5Para              = "%v-%v"      //{md5 of parts md5}-{parts}
+)
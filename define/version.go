@@ -0,0 +1,6 @@
+package define
+
+// default
+const (
+	VersionIdOfFirst = 1 //version id of the first data
+)
@@ -1,5 +1,7 @@
 package face
 
+import "github.com/andyzhou/pond/json"
+
 /*
  * interface define
  * @author <AndyZhou>
@@ -13,12 +15,24 @@ type IMessage interface {
 	GetId() int64
 	GetBlocks() int64
 	GetLen() int64
+	GetVersion() int
+	GetFlags() uint8
+	GetCodec() uint8
+	GetRawLen() int64
+	GetCrc() uint32
+	GetKeyId() uint32
 
 	//set
 	SetMd5(string)
 	SetId(int64)
 	SetBlocks(int64)
 	SetLen(int64)
+	SetVersion(int)
+	SetFlags(uint8)
+	SetCodec(uint8)
+	SetRawLen(int64)
+	SetCrc(uint32)
+	SetKeyId(uint32)
 }
 
 //packet
@@ -28,6 +42,7 @@ type IPacket interface {
 	Pack(message IMessage) ([]byte, error)
 
 	//get opt
+	GetVersion() int
 	GetHeadLen() int64
 	GetMaxPackSize() int64
 
@@ -35,3 +50,76 @@ type IPacket interface {
 	SetMaxPackSize(size int64)
 	SetLittleEndian(littleEndian bool)
 }
+
+//key provider
+//used for data encrypt and decrypt
+type IKeyProvider interface {
+	//get current key for new data
+	CurrentKey() (keyId uint32, key []byte, err error)
+
+	//get key by id for stored data
+	GetKey(keyId uint32) ([]byte, error)
+}
+
+//meta data store
+//used for file info and base storage
+type IMetaStore interface {
+	//file info
+	GetInfo(shortUrl string) (*json.FileInfoJson, error)
+	PutInfo(obj *json.FileInfoJson) error
+	DelInfo(shortUrl string) error
+	IsInfoExists(shortUrl string) (bool, error)
+	ListInfoByTime(page, pageSize int) (int64, []*json.FileInfoJson, error)
+	ListInfoByCursor(createAt int64, shortUrl string, pageSize int) (int64, []*json.FileInfoJson, error)
+
+	//file base
+	GetBase(md5 string) (*json.FileBaseJson, error)
+	PutBase(obj *json.FileBaseJson) error
+	DelBase(md5 string) error
+	ListRemovedBase(page, pageSize int) (int64, []*json.FileBaseJson, error)
+
+	//quit
+	Quit()
+}
+
+//meta store with transaction (optional)
+//file base and info saved atomic
+type IMetaTxStore interface {
+	PutFile(base *json.FileBaseJson, info *json.FileInfoJson) error
+}
+
+//meta store with async write (optional)
+//queued write flushed before wal checkpoint
+type IMetaFlushStore interface {
+	Flush() error
+}
+
+//meta store with native query (optional)
+//live file info filtered, sorted and paged by store
+type IMetaQueryStore interface {
+	ListInfoByQuery(query *json.FileQueryJson) (int64, []*json.FileInfoJson, error)
+}
+
+//meta store with expire index (optional)
+//used for expired files sweep without native query
+type IMetaExpireStore interface {
+	ListInfoByExpire(expireAtMax int64, page, pageSize int) ([]*json.FileInfoJson, error)
+}
+
+//meta store with upload session (optional)
+//used for multipart upload
+type IMetaUploadStore interface {
+	GetUpload(uploadId string) (*json.UploadJson, error)
+	PutUpload(obj *json.UploadJson) error
+	DelUpload(uploadId string) error
+	ListUploads(page, pageSize int) (int64, []*json.UploadJson, error)
+}
+
+//meta store with file versions (optional)
+//used for file versioning
+type IMetaVersionStore interface {
+	GetVersions(shortUrl string) (*json.FileVersionsJson, error)
+	PutVersions(obj *json.FileVersionsJson) error
+	DelVersions(shortUrl string) error
+	ListVersions(page, pageSize int) (int64, []*json.FileVersionsJson, error)
+}
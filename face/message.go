@@ -13,6 +13,12 @@ type Message struct {
 	id	 	int64
 	blocks  int64
 	length  int64
+	version int
+	flags   uint8
+	codec   uint8
+	rawLen  int64
+	crc     uint32
+	keyId   uint32
 }
 
 //construct
@@ -35,6 +41,25 @@ func (f *Message) GetMd5() string {
 	return f.md5
 }
 
+func (f *Message) GetVersion() int {
+	return f.version
+}
+func (f *Message) GetFlags() uint8 {
+	return f.flags
+}
+func (f *Message) GetCodec() uint8 {
+	return f.codec
+}
+func (f *Message) GetRawLen() int64 {
+	return f.rawLen
+}
+func (f *Message) GetCrc() uint32 {
+	return f.crc
+}
+func (f *Message) GetKeyId() uint32 {
+	return f.keyId
+}
+
 //set opt
 func (f *Message) SetLen(val int64) {
 	f.length = val
@@ -47,4 +72,22 @@ func (f *Message) SetId(val int64) {
 }
 func (f *Message) SetMd5(val string) {
 	f.md5 = val
-}
\ No newline at end of file
+}
+func (f *Message) SetVersion(val int) {
+	f.version = val
+}
+func (f *Message) SetFlags(val uint8) {
+	f.flags = val
+}
+func (f *Message) SetCodec(val uint8) {
+	f.codec = val
+}
+func (f *Message) SetRawLen(val int64) {
+	f.rawLen = val
+}
+func (f *Message) SetCrc(val uint32) {
+	f.crc = val
+}
+func (f *Message) SetKeyId(val uint32) {
+	f.keyId = val
+}
@@ -13,11 +13,13 @@ import (
  * @author <AndyZhou>
  * @mail <diudiu8848@163.com>
  * - application `IPacket`
+ * - legacy v1 header, no magic prefix
  */
 
 //inter macro define
 //DO NOT CHANGE THIS!!!
 //md5(32byte) + dataId(8byte) + blocks(8byte) + dataLen(8byte)
+//all header versions keep the same size, so header can be upgraded in place
 const (
 	Md5Size = 32
 	PacketHeadSize = 24 + Md5Size
@@ -94,6 +96,8 @@ func (f *Packet) UnPack(header []byte) (IMessage, error) {
 	message.SetId(dataId)
 	message.SetBlocks(blocks)
 	message.SetLen(length)
+	message.SetRawLen(length)
+	message.SetVersion(PacketVersionOne)
 
 	return message, nil
 }
@@ -148,6 +152,11 @@ func (f *Packet) GetMaxPackSize() int64 {
 	return f.maxPackSize
 }
 
+//get header version
+func (f *Packet) GetVersion() int {
+	return PacketVersionOne
+}
+
 //get header length
 func (f *Packet) GetHeadLen() int64 {
 	return PacketHeadSize
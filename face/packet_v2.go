@@ -0,0 +1,202 @@
+package face
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * packet v2 data face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - application `IPacket`
+ * - magic and version prefix, same size as v1
+ */
+
+//inter macro define
+//magic(4byte) + version(1byte) + flags(1byte) + codec(1byte) + reserved(1byte) +
+//md5(16byte) + blocks(8byte) + dataLen(8byte) + rawLen(8byte) + crc32c(4byte) + keyId(4byte)
+const (
+	PacketMagic         = "POND"
+	PacketMagicSize     = 4
+	PacketMd5BinSize    = 16
+	PacketVersionOne    = 1
+	PacketVersionTwo    = 2
+	PacketVersionLatest = PacketVersionTwo
+)
+
+//face info
+type PacketV2 struct {
+	maxPackSize  int64
+	littleEndian bool
+	byteOrder    binary.ByteOrder
+}
+
+//construct
+func NewPacketV2() *PacketV2 {
+	this := &PacketV2{
+		maxPackSize: define.DefaultPacketMaxSize,
+		littleEndian: true,
+		byteOrder: binary.LittleEndian,
+	}
+	return this
+}
+
+//get packet face by header bytes
+//header with magic prefix use v2, others as legacy v1
+func DetectPacket(header []byte) IPacket {
+	if IsPacketV2(header) {
+		return NewPacketV2()
+	}
+	return NewPacket()
+}
+
+//get packet face by version
+//zero means the latest version
+func GetPacket(version int) (IPacket, error) {
+	switch version {
+	case 0, PacketVersionTwo:
+		return NewPacketV2(), nil
+	case PacketVersionOne:
+		return NewPacket(), nil
+	default:
+		return nil, fmt.Errorf("unsupported packet version %v", version)
+	}
+}
+
+//check header is v2 or not
+func IsPacketV2(header []byte) bool {
+	if len(header) < PacketMagicSize + 1 {
+		return false
+	}
+	if string(header[:PacketMagicSize]) != PacketMagic {
+		return false
+	}
+	return header[PacketMagicSize] == PacketVersionTwo
+}
+
+//pack & unpack opt
+//un-pack header bytes
+func (f *PacketV2) UnPack(header []byte) (IMessage, error) {
+	//check
+	if header == nil || len(header) < PacketHeadSize {
+		return nil, errors.New("invalid parameter")
+	}
+	if !IsPacketV2(header) {
+		return nil, errors.New("invalid packet magic or version")
+	}
+
+	//read header
+	pos := PacketMagicSize
+	version := int(header[pos])
+	flags := header[pos+1]
+	codec := header[pos+2]
+	pos += 4
+
+	//md5
+	md5Val := hex.EncodeToString(header[pos:pos+PacketMd5BinSize])
+	pos += PacketMd5BinSize
+
+	//blocks, length, raw length
+	blocks := int64(f.byteOrder.Uint64(header[pos:]))
+	pos += 8
+	length := int64(f.byteOrder.Uint64(header[pos:]))
+	pos += 8
+	rawLen := int64(f.byteOrder.Uint64(header[pos:]))
+	pos += 8
+
+	//crc and key id
+	crc := f.byteOrder.Uint32(header[pos:])
+	pos += 4
+	keyId := f.byteOrder.Uint32(header[pos:])
+
+	//init message data
+	message := NewMessage()
+	message.SetVersion(version)
+	message.SetFlags(flags)
+	message.SetCodec(codec)
+	message.SetMd5(md5Val)
+	message.SetBlocks(blocks)
+	message.SetLen(length)
+	message.SetRawLen(rawLen)
+	message.SetCrc(crc)
+	message.SetKeyId(keyId)
+	return message, nil
+}
+
+//pack header message
+func (f *PacketV2) Pack(message IMessage) ([]byte, error) {
+	//check
+	if message == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	md5Bytes, err := hex.DecodeString(message.GetMd5())
+	if err != nil || len(md5Bytes) != PacketMd5BinSize {
+		return nil, errors.New("invalid md5 value of message")
+	}
+	rawLen := message.GetRawLen()
+	if rawLen <= 0 {
+		rawLen = message.GetLen()
+	}
+
+	//init data buff
+	dataBuff := bytes.NewBuffer(nil)
+
+	//write header
+	//magic and version
+	dataBuff.WriteString(PacketMagic)
+	dataBuff.WriteByte(PacketVersionTwo)
+	dataBuff.WriteByte(message.GetFlags())
+	dataBuff.WriteByte(message.GetCodec())
+	dataBuff.WriteByte(0)
+
+	//md5
+	dataBuff.Write(md5Bytes)
+
+	//blocks, length, raw length
+	binary.Write(dataBuff, f.byteOrder, message.GetBlocks())
+	binary.Write(dataBuff, f.byteOrder, message.GetLen())
+	binary.Write(dataBuff, f.byteOrder, rawLen)
+
+	//crc and key id
+	binary.Write(dataBuff, f.byteOrder, message.GetCrc())
+	binary.Write(dataBuff, f.byteOrder, message.GetKeyId())
+	return dataBuff.Bytes(), nil
+}
+
+//get opt
+//get max pack size
+func (f *PacketV2) GetMaxPackSize() int64 {
+	return f.maxPackSize
+}
+
+//get header version
+func (f *PacketV2) GetVersion() int {
+	return PacketVersionTwo
+}
+
+//get header length
+func (f *PacketV2) GetHeadLen() int64 {
+	return PacketHeadSize
+}
+
+//set opt
+//set max pack size
+func (f *PacketV2) SetMaxPackSize(val int64) {
+	f.maxPackSize = val
+}
+
+//set little endian
+func (f *PacketV2) SetLittleEndian(littleEndian bool) {
+	f.littleEndian = littleEndian
+	if littleEndian {
+		f.byteOrder = binary.LittleEndian
+	}else{
+		f.byteOrder = binary.BigEndian
+	}
+}
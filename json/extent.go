@@ -0,0 +1,38 @@
+package json
+
+/*
+ * chunk free extent json info
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - free space of chunk, sorted by offset
+ * - removed data still kept in extent as owner, used for re-use same data
+ */
+
+//free extent owner
+//removed data whole space in extent
+type ExtentOwnerJson struct {
+	Md5    string `json:"md5"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+//free extent
+//size is whole bytes, include header
+type FreeExtentJson struct {
+	Offset int64              `json:"offset"`
+	Size   int64              `json:"size"`
+	Owners []*ExtentOwnerJson `json:"owners"`
+}
+
+//all chunks free extent
+type ExtentJson struct {
+	Chunks map[int64][]*FreeExtentJson `json:"chunks"` //chunkId -> free extents
+}
+
+//construct
+func NewExtentJson() *ExtentJson {
+	this := &ExtentJson{
+		Chunks: map[int64][]*FreeExtentJson{},
+	}
+	return this
+}
@@ -11,15 +11,21 @@ import "github.com/andyzhou/tinylib/util"
 
 //file info json
 type FileInfoJson struct {
-	ShortUrl    string `json:"shortUrl"` //unique url
-	Name        string `json:"name"`
-	ContentType string `json:"contentType"`
-	Size        int64  `json:"size"`
-	Md5         string `json:"md5"` //unique, base file md5
-	ChunkFileId int64  `json:"chunkFileId"`
-	Offset      int64  `json:"offset"`
-	Blocks      int64  `json:"blocks"`
-	CreateAt    int64  `json:"createAt"`
+	ShortUrl    string            `json:"shortUrl"` //unique url
+	Name        string            `json:"name"`
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata"` //user meta data
+	Size        int64             `json:"size"`
+	Md5         string            `json:"md5"` //unique, base file md5
+	ChunkFileId int64             `json:"chunkFileId"`
+	Offset      int64             `json:"offset"`
+	Blocks      int64             `json:"blocks"`
+	Crc32       uint32            `json:"crc32"` //crc32c of file data
+	CreateAt    int64             `json:"createAt"`
+	UpdateAt    int64             `json:"updateAt"` //last data written time
+	ExpireAt    int64             `json:"expireAt"` //zero means never expire
+	AccessAt    int64             `json:"accessAt"` //last read time, updated at most once an hour
+	Parts       int               `json:"parts"`    //parts of multipart uploaded file, zero means normal file
 	util.BaseJson
 }
 
@@ -32,10 +38,12 @@ type FileBaseJson struct {
 	ChunkFileId int64  `json:"chunkFileId"` //chunk file id
 	Offset      int64  `json:"offset"`
 	Blocks      int64  `json:"blocks"`   //current blocks
+	Crc32       uint32 `json:"crc32"`    //crc32c of file data, zero means unknown
 	Appoints    int32  `json:"appoints"` //if value is zero, means need removed.
 	Removed     bool   `json:"removed"`
 	Backed      bool   `json:"backed"` //backed or not
 	CreateAt    int64  `json:"createAt"`
+	Parts       []string `json:"parts"` //part file base md5 list of multipart uploaded file
 	util.BaseJson
 }
 
@@ -26,6 +26,7 @@ type MetaJson struct {
 	FileId  int64   `json:"fileId"`  //inter dynamic data file id
 	ChunkId int64   `json:"chunkId"` //inter chunk storage file id
 	Chunks  []int64 `json:"chunks"`  //active chunk file ids
+	Archives []int64 `json:"archives"` //archive chunk file ids, no new data written
 	util.BaseJson
 }
 
@@ -38,6 +39,7 @@ func NewChunkFileJson() *ChunkFileJson {
 func NewMetaJson() *MetaJson {
 	this := &MetaJson{
 		Chunks: []int64{},
+		Archives: []int64{},
 	}
 	return this
 }
\ No newline at end of file
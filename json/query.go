@@ -0,0 +1,35 @@
+package json
+
+import "github.com/andyzhou/tinylib/util"
+
+/*
+ * file query json info
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - used for list files with filters and order
+ * - zero value of filter means no limit
+ */
+
+//file query json
+type FileQueryJson struct {
+	CreateAtMin int64  `json:"createAtMin"` //min <= createAt
+	CreateAtMax int64  `json:"createAtMax"` //createAt <= max
+	SizeMin     int64  `json:"sizeMin"`     //min <= size
+	SizeMax     int64  `json:"sizeMax"`     //size <= max
+	ContentType string `json:"contentType"` //exact match
+	NamePrefix  string `json:"namePrefix"`
+	ChunkFileId int64  `json:"chunkFileId"`
+	ExpireAtMax int64  `json:"expireAtMax"` //0 < expireAt <= max, used for pick expired files
+	Removed     bool   `json:"removed"` //list removed file data, short url of result is empty
+	OrderBy     string `json:"orderBy"` //createAt/size/name, default createAt
+	Asc         bool   `json:"asc"`     //default desc
+	Page        int    `json:"page"`
+	PageSize    int    `json:"pageSize"`
+	util.BaseJson
+}
+
+//construct
+func NewFileQueryJson() *FileQueryJson {
+	this := &FileQueryJson{}
+	return this
+}
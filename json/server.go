@@ -0,0 +1,48 @@
+package json
+
+import "github.com/andyzhou/tinylib/util"
+
+/*
+ * http server json info
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//write response json
+type WriteRespJson struct {
+	ShortUrl string `json:"shortUrl"`
+	util.BaseJson
+}
+
+//files list response json
+type FilesRespJson struct {
+	Total int64           `json:"total"`
+	Page  int             `json:"page"`
+	Files []*FileInfoJson `json:"files"`
+	NextCursor string     `json:"nextCursor"`
+	util.BaseJson
+}
+
+//error response json
+type ErrRespJson struct {
+	ErrMsg string `json:"errMsg"`
+	util.BaseJson
+}
+
+//construct
+func NewWriteRespJson() *WriteRespJson {
+	this := &WriteRespJson{}
+	return this
+}
+
+func NewFilesRespJson() *FilesRespJson {
+	this := &FilesRespJson{
+		Files: []*FileInfoJson{},
+	}
+	return this
+}
+
+func NewErrRespJson() *ErrRespJson {
+	this := &ErrRespJson{}
+	return this
+}
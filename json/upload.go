@@ -0,0 +1,48 @@
+package json
+
+import "github.com/andyzhou/tinylib/util"
+
+/*
+ * upload session json info
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - parts staged in chunk as file base without file info
+ * - upload id is the short url of completed file
+ */
+
+//upload part json
+type UploadPartJson struct {
+	Number   int    `json:"number"` //part number, begin with 1
+	Md5      string `json:"md5"`    //part file base md5
+	Size     int64  `json:"size"`
+	CreateAt int64  `json:"createAt"`
+}
+
+//upload session json
+type UploadJson struct {
+	UploadId    string            `json:"uploadId"` //primary key
+	Name        string            `json:"name"`
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata"`
+	TTL         int64             `json:"ttl"`
+	ExpireAt    int64             `json:"expireAt"`
+	Compress    string            `json:"compress"`
+	Parts       []*UploadPartJson `json:"parts"` //sort by part number
+	CreateAt    int64             `json:"createAt"`
+	UpdateAt    int64             `json:"updateAt"` //session expired by this time
+	util.BaseJson
+}
+
+//construct
+func NewUploadJson() *UploadJson {
+	this := &UploadJson{
+		Metadata: map[string]string{},
+		Parts: []*UploadPartJson{},
+	}
+	return this
+}
+
+func NewUploadPartJson() *UploadPartJson {
+	this := &UploadPartJson{}
+	return this
+}
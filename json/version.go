@@ -0,0 +1,42 @@
+package json
+
+import "github.com/andyzhou/tinylib/util"
+
+/*
+ * file version json info
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - the last version is current file info if file not deleted
+ * - file base of noncurrent version kept by appoint value
+ */
+
+//file version json
+type FileVersionJson struct {
+	VersionId    string        `json:"versionId"`
+	Info         *FileInfoJson `json:"info"`         //file info of this version, nil if delete marker
+	DeleteMarker bool          `json:"deleteMarker"` //file deleted at this version
+	IsLatest     bool          `json:"isLatest"`     //only set when listed
+	CreateAt     int64         `json:"createAt"`
+}
+
+//file versions json
+type FileVersionsJson struct {
+	ShortUrl string             `json:"shortUrl"` //primary key
+	NextId   int64              `json:"nextId"`   //id of next version
+	Versions []*FileVersionJson `json:"versions"` //sort by create time, the last is latest
+	CreateAt int64              `json:"createAt"`
+	util.BaseJson
+}
+
+//construct
+func NewFileVersionsJson() *FileVersionsJson {
+	this := &FileVersionsJson{
+		Versions: []*FileVersionJson{},
+	}
+	return this
+}
+
+func NewFileVersionJson() *FileVersionJson {
+	this := &FileVersionJson{}
+	return this
+}
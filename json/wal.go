@@ -0,0 +1,25 @@
+package json
+
+/*
+ * wal entry json info
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - one logical meta data operate
+ * - file base and info after operate, redo by replay
+ */
+
+//wal entry json
+type WalEntryJson struct {
+	Seq      int64         `json:"seq"`
+	Op       int           `json:"op"` //operate kind, see define
+	ShortUrl string        `json:"shortUrl"`
+	FileBase *FileBaseJson `json:"fileBase"` //file base after operate
+	FileInfo *FileInfoJson `json:"fileInfo"` //file info after operate, nil for delete
+	CreateAt int64         `json:"createAt"`
+}
+
+//construct
+func NewWalEntryJson() *WalEntryJson {
+	this := &WalEntryJson{}
+	return this
+}
@@ -0,0 +1,715 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+ * meta store face base on embedded bolt db
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - file info and base storage into local bolt db
+ * - write opt is sync, read after write always hit
+ * - file info and base saved in one transaction
+ * - file time index key is createAt + shortUrl, used for list by time
+ * - file expire index key is expireAt + shortUrl, used for expired files sweep
+ * - upload session saved in upload bucket
+ */
+
+//face info
+type Store struct {
+	db     *bolt.DB
+	dbFile string
+	utils.Utils
+}
+
+//construct
+func NewStore(dataPath string) (*Store, error) {
+	//check
+	if dataPath == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	this := &Store{}
+
+	//check and create sub dir
+	rootPath := fmt.Sprintf("%v/%v", dataPath, define.SubDirOfBolt)
+	err := this.CheckDir(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	//open db file
+	this.dbFile = fmt.Sprintf("%v/%v", rootPath, define.BoltDbFile)
+	opts := &bolt.Options{
+		Timeout: define.BoltOpenTimeout * time.Second,
+	}
+	this.db, err = bolt.Open(this.dbFile, define.FilePerm, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//init buckets
+	err = this.initBuckets()
+	if err != nil {
+		this.db.Close()
+		return nil, err
+	}
+	return this, nil
+}
+
+//quit
+func (f *Store) Quit() {
+	f.db.Close()
+}
+
+///////////////
+//api for info
+///////////////
+
+//get file info
+func (f *Store) GetInfo(shortUrl string) (*json.FileInfoJson, error) {
+	var (
+		fileInfo *json.FileInfoJson
+	)
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	err := f.db.View(func(tx *bolt.Tx) error {
+		var subErr error
+		fileInfo, subErr = f.getInfo(tx, shortUrl)
+		return subErr
+	})
+	return fileInfo, err
+}
+
+//save file info
+func (f *Store) PutInfo(obj *json.FileInfoJson) error {
+	//check
+	if obj == nil || obj.ShortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		return f.putInfo(tx, obj)
+	})
+}
+
+//del file info
+func (f *Store) DelInfo(shortUrl string) error {
+	//check
+	if shortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		oldInfo, err := f.getInfo(tx, shortUrl)
+		if err != nil || oldInfo == nil {
+			return err
+		}
+		err = tx.Bucket([]byte(define.BoltBucketOfFileTime)).Delete(f.genTimeKey(oldInfo))
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket([]byte(define.BoltBucketOfExpire)).Delete(f.genExpireKey(oldInfo))
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket([]byte(define.BoltBucketOfFileInfo)).Delete([]byte(shortUrl))
+		if err != nil {
+			return err
+		}
+		return f.incStat(tx, define.BoltStatOfFileInfo, -1)
+	})
+}
+
+//check file info exists or not
+func (f *Store) IsInfoExists(shortUrl string) (bool, error) {
+	var (
+		isExists bool
+	)
+	//check
+	if shortUrl == "" {
+		return false, errors.New("invalid parameter")
+	}
+	err := f.db.View(func(tx *bolt.Tx) error {
+		isExists = tx.Bucket([]byte(define.BoltBucketOfFileInfo)).Get([]byte(shortUrl)) != nil
+		return nil
+	})
+	return isExists, err
+}
+
+//get batch file info by create time desc
+//scan file time index from tail
+func (f *Store) ListInfoByTime(page, pageSize int) (int64, []*json.FileInfoJson, error) {
+	var (
+		total int64
+	)
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	result := make([]*json.FileInfoJson, 0)
+	err := f.db.View(func(tx *bolt.Tx) error {
+		total = f.getStat(tx, define.BoltStatOfFileInfo)
+		skip := (page - 1) * pageSize
+		cursor := tx.Bucket([]byte(define.BoltBucketOfFileTime)).Cursor()
+		for k, _ := cursor.Last(); k != nil && len(result) < pageSize; k, _ = cursor.Prev() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			fileInfo, subErr := f.getInfo(tx, string(k[define.BoltTimeKeyPrefix:]))
+			if subErr != nil {
+				return subErr
+			}
+			if fileInfo != nil {
+				result = append(result, fileInfo)
+			}
+		}
+		return nil
+	})
+	return total, result, err
+}
+
+//get batch file info after cursor by create time desc
+//same time file info sorted by short url desc
+func (f *Store) ListInfoByCursor(
+		createAt int64,
+		shortUrl string,
+		pageSize int,
+	) (int64, []*json.FileInfoJson, error) {
+	var (
+		total int64
+	)
+	//check
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	result := make([]*json.FileInfoJson, 0)
+	err := f.db.View(func(tx *bolt.Tx) error {
+		var k []byte
+		total = f.getStat(tx, define.BoltStatOfFileInfo)
+		cursor := tx.Bucket([]byte(define.BoltBucketOfFileTime)).Cursor()
+		if shortUrl == "" {
+			k, _ = cursor.Last()
+		}else{
+			//seek to cursor, then move to prev
+			cursorInfo := &json.FileInfoJson{CreateAt: createAt, ShortUrl: shortUrl}
+			k, _ = cursor.Seek(f.genTimeKey(cursorInfo))
+			if k == nil {
+				k, _ = cursor.Last()
+			}else{
+				k, _ = cursor.Prev()
+			}
+		}
+		for ; k != nil && len(result) < pageSize; k, _ = cursor.Prev() {
+			fileInfo, subErr := f.getInfo(tx, string(k[define.BoltTimeKeyPrefix:]))
+			if subErr != nil {
+				return subErr
+			}
+			if fileInfo != nil {
+				result = append(result, fileInfo)
+			}
+		}
+		return nil
+	})
+	return total, result, err
+}
+
+//get batch expired file info by expire time asc
+//scan file expire index from head
+func (f *Store) ListInfoByExpire(
+		expireAtMax int64,
+		page, pageSize int,
+	) ([]*json.FileInfoJson, error) {
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	result := make([]*json.FileInfoJson, 0)
+	err := f.db.View(func(tx *bolt.Tx) error {
+		skip := (page - 1) * pageSize
+		cursor := tx.Bucket([]byte(define.BoltBucketOfExpire)).Cursor()
+		for k, _ := cursor.First(); k != nil && len(result) < pageSize; k, _ = cursor.Next() {
+			if int64(binary.BigEndian.Uint64(k)) > expireAtMax {
+				break
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			fileInfo, subErr := f.getInfo(tx, string(k[define.BoltTimeKeyPrefix:]))
+			if subErr != nil {
+				return subErr
+			}
+			if fileInfo != nil {
+				result = append(result, fileInfo)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+///////////////
+//api for base
+///////////////
+
+//get file base
+func (f *Store) GetBase(md5 string) (*json.FileBaseJson, error) {
+	var (
+		fileBase *json.FileBaseJson
+	)
+	//check
+	if md5 == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	err := f.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(define.BoltBucketOfFileBase)).Get([]byte(md5))
+		if data == nil {
+			return nil
+		}
+		fileBase = json.NewFileBaseJson()
+		return fileBase.Decode(data, fileBase)
+	})
+	return fileBase, err
+}
+
+//save file base
+func (f *Store) PutBase(obj *json.FileBaseJson) error {
+	//check
+	if obj == nil || obj.Md5 == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		return f.putBase(tx, obj)
+	})
+}
+
+//del file base
+func (f *Store) DelBase(md5 string) error {
+	//check
+	if md5 == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(define.BoltBucketOfFileBase)).Delete([]byte(md5))
+		if err != nil {
+			return err
+		}
+		return f.setRemoved(tx, md5, 0)
+	})
+}
+
+//get batch removed file base
+//sort by md5
+func (f *Store) ListRemovedBase(page, pageSize int) (int64, []*json.FileBaseJson, error) {
+	var (
+		total int64
+	)
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	result := make([]*json.FileBaseJson, 0)
+	err := f.db.View(func(tx *bolt.Tx) error {
+		total = f.getStat(tx, define.BoltStatOfRemoved)
+		skip := (page - 1) * pageSize
+		baseBucket := tx.Bucket([]byte(define.BoltBucketOfFileBase))
+		cursor := tx.Bucket([]byte(define.BoltBucketOfRemoved)).Cursor()
+		for k, _ := cursor.First(); k != nil && len(result) < pageSize; k, _ = cursor.Next() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			data := baseBucket.Get(k)
+			if data == nil {
+				continue
+			}
+			fileBase := json.NewFileBaseJson()
+			err := fileBase.Decode(data, fileBase)
+			if err != nil {
+				return err
+			}
+			result = append(result, fileBase)
+		}
+		return nil
+	})
+	return total, result, err
+}
+
+//save file base and info in one transaction
+func (f *Store) PutFile(base *json.FileBaseJson, info *json.FileInfoJson) error {
+	//check
+	if base == nil || base.Md5 == "" || info == nil || info.ShortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		err := f.putBase(tx, base)
+		if err != nil {
+			return err
+		}
+		return f.putInfo(tx, info)
+	})
+}
+
+/////////////////
+//api for upload
+/////////////////
+
+//get upload session
+func (f *Store) GetUpload(uploadId string) (*json.UploadJson, error) {
+	var (
+		upload *json.UploadJson
+	)
+	//check
+	if uploadId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	err := f.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(define.BoltBucketOfUpload)).Get([]byte(uploadId))
+		if data == nil {
+			return nil
+		}
+		upload = json.NewUploadJson()
+		return upload.Decode(data, upload)
+	})
+	return upload, err
+}
+
+//save upload session
+func (f *Store) PutUpload(obj *json.UploadJson) error {
+	//check
+	if obj == nil || obj.UploadId == "" {
+		return errors.New("invalid parameter")
+	}
+	data, err := obj.Encode(obj)
+	if err != nil {
+		return err
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(define.BoltBucketOfUpload)).Put([]byte(obj.UploadId), data)
+	})
+}
+
+//del upload session
+func (f *Store) DelUpload(uploadId string) error {
+	//check
+	if uploadId == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(define.BoltBucketOfUpload)).Delete([]byte(uploadId))
+	})
+}
+
+//get batch upload session
+//sort by upload id
+func (f *Store) ListUploads(page, pageSize int) (int64, []*json.UploadJson, error) {
+	var (
+		total int64
+	)
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	result := make([]*json.UploadJson, 0)
+	err := f.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(define.BoltBucketOfUpload))
+		total = int64(bucket.Stats().KeyN)
+		skip := (page - 1) * pageSize
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil && len(result) < pageSize; k, v = cursor.Next() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			upload := json.NewUploadJson()
+			err := upload.Decode(v, upload)
+			if err != nil {
+				return err
+			}
+			result = append(result, upload)
+		}
+		return nil
+	})
+	return total, result, err
+}
+
+/////////////////
+//api for version
+/////////////////
+
+//get file versions
+func (f *Store) GetVersions(shortUrl string) (*json.FileVersionsJson, error) {
+	var (
+		versions *json.FileVersionsJson
+	)
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	err := f.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(define.BoltBucketOfVersion)).Get([]byte(shortUrl))
+		if data == nil {
+			return nil
+		}
+		versions = json.NewFileVersionsJson()
+		return versions.Decode(data, versions)
+	})
+	return versions, err
+}
+
+//save file versions
+func (f *Store) PutVersions(obj *json.FileVersionsJson) error {
+	//check
+	if obj == nil || obj.ShortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	data, err := obj.Encode(obj)
+	if err != nil {
+		return err
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(define.BoltBucketOfVersion)).Put([]byte(obj.ShortUrl), data)
+	})
+}
+
+//del file versions
+func (f *Store) DelVersions(shortUrl string) error {
+	//check
+	if shortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(define.BoltBucketOfVersion)).Delete([]byte(shortUrl))
+	})
+}
+
+//get batch file versions
+//sort by short url
+func (f *Store) ListVersions(page, pageSize int) (int64, []*json.FileVersionsJson, error) {
+	var (
+		total int64
+	)
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	result := make([]*json.FileVersionsJson, 0)
+	err := f.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(define.BoltBucketOfVersion))
+		total = int64(bucket.Stats().KeyN)
+		skip := (page - 1) * pageSize
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil && len(result) < pageSize; k, v = cursor.Next() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			versions := json.NewFileVersionsJson()
+			err := versions.Decode(v, versions)
+			if err != nil {
+				return err
+			}
+			result = append(result, versions)
+		}
+		return nil
+	})
+	return total, result, err
+}
+
+///////////////
+//private func
+///////////////
+
+//get file info in transaction
+func (f *Store) getInfo(tx *bolt.Tx, shortUrl string) (*json.FileInfoJson, error) {
+	data := tx.Bucket([]byte(define.BoltBucketOfFileInfo)).Get([]byte(shortUrl))
+	if data == nil {
+		return nil, nil
+	}
+	fileInfo := json.NewFileInfoJson()
+	err := fileInfo.Decode(data, fileInfo)
+	return fileInfo, err
+}
+
+//save file info, time and expire index in transaction
+func (f *Store) putInfo(tx *bolt.Tx, obj *json.FileInfoJson) error {
+	timeBucket := tx.Bucket([]byte(define.BoltBucketOfFileTime))
+	expireBucket := tx.Bucket([]byte(define.BoltBucketOfExpire))
+	oldInfo, err := f.getInfo(tx, obj.ShortUrl)
+	if err != nil {
+		return err
+	}
+	if oldInfo != nil {
+		//remove old time and expire index
+		err = timeBucket.Delete(f.genTimeKey(oldInfo))
+		if err == nil {
+			err = expireBucket.Delete(f.genExpireKey(oldInfo))
+		}
+	}else{
+		err = f.incStat(tx, define.BoltStatOfFileInfo, 1)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := obj.Encode(obj)
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket([]byte(define.BoltBucketOfFileInfo)).Put([]byte(obj.ShortUrl), data)
+	if err != nil {
+		return err
+	}
+	err = timeBucket.Put(f.genTimeKey(obj), []byte{})
+	if err != nil || obj.ExpireAt <= 0 {
+		return err
+	}
+	return expireBucket.Put(f.genExpireKey(obj), []byte{})
+}
+
+//save file base and removed index in transaction
+func (f *Store) putBase(tx *bolt.Tx, obj *json.FileBaseJson) error {
+	data, err := obj.Encode(obj)
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket([]byte(define.BoltBucketOfFileBase)).Put([]byte(obj.Md5), data)
+	if err != nil {
+		return err
+	}
+	blocks := int64(0)
+	if obj.Removed {
+		blocks = obj.Blocks
+	}
+	return f.setRemoved(tx, obj.Md5, blocks)
+}
+
+//set or remove removed index in transaction
+//zero blocks means remove
+func (f *Store) setRemoved(tx *bolt.Tx, md5 string, blocks int64) error {
+	var (
+		err error
+	)
+	removedBucket := tx.Bucket([]byte(define.BoltBucketOfRemoved))
+	isExists := removedBucket.Get([]byte(md5)) != nil
+	if blocks <= 0 {
+		if !isExists {
+			return nil
+		}
+		err = removedBucket.Delete([]byte(md5))
+		if err != nil {
+			return err
+		}
+		return f.incStat(tx, define.BoltStatOfRemoved, -1)
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(blocks))
+	err = removedBucket.Put([]byte(md5), value)
+	if err != nil || isExists {
+		return err
+	}
+	return f.incStat(tx, define.BoltStatOfRemoved, 1)
+}
+
+//get stat count in transaction
+func (f *Store) getStat(tx *bolt.Tx, key string) int64 {
+	value := tx.Bucket([]byte(define.BoltBucketOfStat)).Get([]byte(key))
+	if len(value) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+//inc stat count in transaction
+func (f *Store) incStat(tx *bolt.Tx, key string, diff int64) error {
+	count := f.getStat(tx, key) + diff
+	if count < 0 {
+		count = 0
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(count))
+	return tx.Bucket([]byte(define.BoltBucketOfStat)).Put([]byte(key), value)
+}
+
+//gen file time index key
+//createAt(8 bytes big endian) + shortUrl
+func (f *Store) genTimeKey(obj *json.FileInfoJson) []byte {
+	key := make([]byte, define.BoltTimeKeyPrefix + len(obj.ShortUrl))
+	binary.BigEndian.PutUint64(key, uint64(obj.CreateAt))
+	copy(key[define.BoltTimeKeyPrefix:], obj.ShortUrl)
+	return key
+}
+
+//gen file expire index key
+//expireAt(8 bytes big endian) + shortUrl
+func (f *Store) genExpireKey(obj *json.FileInfoJson) []byte {
+	key := make([]byte, define.BoltTimeKeyPrefix + len(obj.ShortUrl))
+	binary.BigEndian.PutUint64(key, uint64(obj.ExpireAt))
+	copy(key[define.BoltTimeKeyPrefix:], obj.ShortUrl)
+	return key
+}
+
+//init expire index of old db in transaction
+func (f *Store) initExpireIndex(tx *bolt.Tx) error {
+	expireBucket := tx.Bucket([]byte(define.BoltBucketOfExpire))
+	return tx.Bucket([]byte(define.BoltBucketOfFileInfo)).ForEach(func(k, v []byte) error {
+		fileInfo := json.NewFileInfoJson()
+		err := fileInfo.Decode(v, fileInfo)
+		if err != nil || fileInfo.ExpireAt <= 0 {
+			return err
+		}
+		return expireBucket.Put(f.genExpireKey(fileInfo), []byte{})
+	})
+}
+
+//init all buckets
+func (f *Store) initBuckets() error {
+	buckets := []string{
+		define.BoltBucketOfFileInfo,
+		define.BoltBucketOfFileBase,
+		define.BoltBucketOfFileTime,
+		define.BoltBucketOfExpire,
+		define.BoltBucketOfRemoved,
+		define.BoltBucketOfStat,
+		define.BoltBucketOfUpload,
+		define.BoltBucketOfVersion,
+	}
+	return f.db.Update(func(tx *bolt.Tx) error {
+		//expire index added later, init by file info
+		isExpireIndexed := tx.Bucket([]byte(define.BoltBucketOfExpire)) != nil
+		for _, bucket := range buckets {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+		}
+		if isExpireIndexed {
+			return nil
+		}
+		return f.initExpireIndex(tx)
+	})
+}
@@ -2,6 +2,7 @@ package pond
 
 import (
 	"errors"
+	"io"
 	"sync"
 
 	"github.com/andyzhou/pond/conf"
@@ -41,17 +42,19 @@ func GetPond() *Pond {
 
 //construct
 func NewPond() *Pond {
-	wg := sync.WaitGroup{}
-	this := &Pond{
-		wg: wg,
-		storage: storage.NewStorage(&wg),
-	}
+	this := &Pond{}
+	this.storage = storage.NewStorage(&this.wg)
 	return this
 }
 
 //quit
+//quit more than once is safe
 func (f *Pond) Quit() {
+	if !f.initDone {
+		return
+	}
 	f.storage.Quit()
+	f.initDone = false
 }
 
 //get batch file info by create time
@@ -66,7 +69,104 @@ func (f *Pond) GetFiles(
 	return f.storage.GetFilesInfo(page, pageSize)
 }
 
+//list files by query
+//filter by create time, size, content type, name prefix, chunk id and removed state
+//return total, []*FileInfoJson, error
+func (f *Pond) ListFiles(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	//check
+	if !f.initDone {
+		return 0, nil, errors.New("inter config not init")
+	}
+	return f.storage.GetFilesInfoByQuery(query)
+}
+
+//get batch file info after cursor by create time desc
+//cursor is empty for first page, or next cursor of last page
+//total of next pages may be zero, count it by the first page
+//return total, []*FileInfoJson, next cursor, error
+func (f *Pond) GetFilesByCursor(
+		cursor string,
+		pageSize int,
+	) (int64, []*json.FileInfoJson, string, error) {
+	//check
+	if !f.initDone {
+		return 0, nil, "", errors.New("inter config not init")
+	}
+	return f.storage.GetFilesInfoByCursor(cursor, pageSize)
+}
+
+//get file info, like http HEAD request
+//include size, md5, create time, chunk location,
+//and name, content type, meta data and ttl of write option
+//only opt meta data, not read file data
+func (f *Pond) Stat(shortUrl string) (*json.FileInfoJson, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.GetFileInfo(shortUrl)
+}
+
+//check file exists or not
+//only opt meta data, not read file data
+func (f *Pond) Exists(shortUrl string) (bool, error) {
+	//check
+	if !f.initDone {
+		return false, errors.New("inter config not init")
+	}
+	return f.storage.IsFileExists(shortUrl)
+}
+
+//compact chunks, online opt
+//if no chunk id assigned, pick chunks by dead space ratio
+func (f *Pond) Compact(chunkIds ...int64) ([]*storage.CompactResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.Compact(chunkIds...)
+}
+
+//recover meta data from chunk files
+//orphan data saved with new short url if repopulate
+func (f *Pond) Recover(opts ...*conf.RecoverOption) (*storage.RecoverResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.Recover(opts...)
+}
+
+//run lifecycle rules of config, run by inter ticker too
+//return report of actions taken
+func (f *Pond) RunLifecycle() (*storage.LifecycleReport, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.RunLifecycle()
+}
+
+//get latest lifecycle report
+//nil means no lifecycle run yet
+func (f *Pond) GetLifecycleReport() *storage.LifecycleReport {
+	return f.storage.GetLifecycleReport()
+}
+
+//sweep expired files and upload sessions, run by inter ticker too
+//return deleted files and aborted sessions, error
+func (f *Pond) SweepExpired() (int, error) {
+	//check
+	if !f.initDone {
+		return 0, errors.New("inter config not init")
+	}
+	return f.storage.SweepExpired()
+}
+
 //del data
+//if versioning, delete marker left and data kept until versions purged
 func (f *Pond) DelData(shortUrl string) error {
 	//check
 	if !f.initDone {
@@ -88,8 +188,23 @@ func (f *Pond) ReadData(
 	return f.storage.ReadData(shortUrl, offsetAndLength...)
 }
 
+//open data reader
+//reader support seek, can be used for http range request
+func (f *Pond) Open(shortUrl string) (io.ReadSeekCloser, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	reader, err := f.storage.OpenData(shortUrl)
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
 //write new data, if assigned short url means overwrite data
-//if overwrite data, fix chunk size config should be true
+//if overwrite data, grown data relocated into new space
+//if versioning, overwrite data created as new version
 //return shortUrl, error
 func (f *Pond) WriteData(
 		data []byte,
@@ -99,7 +214,163 @@ func (f *Pond) WriteData(
 	if !f.initDone {
 		return "", errors.New("inter config not init")
 	}
-	return f.storage.WriteData(data, shortUrls...)
+	return f.storage.WriteData(data, nil, shortUrls...)
+}
+
+//write new data with option, if assigned short url means overwrite data
+//option include name, content type, meta data and ttl
+//return shortUrl, error
+func (f *Pond) WriteDataWithOption(
+		data []byte,
+		opt *conf.WriteOption,
+		shortUrls ...string,
+	) (string, error) {
+	//check
+	if !f.initDone {
+		return "", errors.New("inter config not init")
+	}
+	return f.storage.WriteData(data, opt, shortUrls...)
+}
+
+//write new data from stream
+//size is the whole data size of reader
+//return shortUrl, error
+func (f *Pond) WriteFrom(
+		reader io.Reader,
+		size int64,
+		opts ...*conf.WriteOption,
+	) (string, error) {
+	//check
+	if !f.initDone {
+		return "", errors.New("inter config not init")
+	}
+	return f.storage.WriteStream(reader, size, opts...)
+}
+
+//append data to the end of file
+//data extended in place if possible, else linked as new part
+//return new file size, error
+func (f *Pond) Append(
+		shortUrl string,
+		data []byte,
+	) (int64, error) {
+	//check
+	if !f.initDone {
+		return 0, errors.New("inter config not init")
+	}
+	return f.storage.AppendData(shortUrl, data)
+}
+
+//init multipart upload session
+//option applied to the completed file
+//return upload id, it's the short url of completed file
+func (f *Pond) InitUpload(opts ...*conf.WriteOption) (string, error) {
+	//check
+	if !f.initDone {
+		return "", errors.New("inter config not init")
+	}
+	return f.storage.InitUpload(opts...)
+}
+
+//upload one part data of session
+//part number begin with 1, old data of same number replaced
+func (f *Pond) UploadPart(
+		uploadId string,
+		number int,
+		data []byte,
+	) error {
+	//check
+	if !f.initDone {
+		return errors.New("inter config not init")
+	}
+	return f.storage.UploadPart(uploadId, number, data)
+}
+
+//complete upload session, parts should be continuous from 1
+//parts not copied, completed file readable by short url
+//return shortUrl, error
+func (f *Pond) CompleteUpload(uploadId string) (string, error) {
+	//check
+	if !f.initDone {
+		return "", errors.New("inter config not init")
+	}
+	return f.storage.CompleteUpload(uploadId)
+}
+
+//abort upload session, uploaded parts released
+func (f *Pond) AbortUpload(uploadId string) error {
+	//check
+	if !f.initDone {
+		return errors.New("inter config not init")
+	}
+	return f.storage.AbortUpload(uploadId)
+}
+
+//list versions of file, latest first
+//file without versions got one version of current data
+func (f *Pond) ListVersions(shortUrl string) ([]*json.FileVersionJson, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.ListVersions(shortUrl)
+}
+
+//read data of assigned version
+func (f *Pond) ReadVersion(
+		shortUrl string,
+		versionId string,
+	) ([]byte, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.ReadVersion(shortUrl, versionId)
+}
+
+//restore assigned version as current data
+//deleted file can be restored too
+func (f *Pond) RestoreVersion(
+		shortUrl string,
+		versionId string,
+	) error {
+	//check
+	if !f.initDone {
+		return errors.New("inter config not init")
+	}
+	return f.storage.RestoreVersion(shortUrl, versionId)
+}
+
+//purge noncurrent versions of file, old data released
+//return purged count, error
+func (f *Pond) PurgeVersions(shortUrl string) (int, error) {
+	//check
+	if !f.initDone {
+		return 0, errors.New("inter config not init")
+	}
+	return f.storage.PurgeVersions(shortUrl)
+}
+
+//migrate meta data into named meta store
+//meta data of this pond only read, can run with data opt
+func (f *Pond) MigrateMeta(
+		to string,
+		redisCfg *conf.RedisConfig,
+		opts ...*conf.MigrateOption,
+	) (*storage.MigrateResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("inter config not init")
+	}
+	return f.storage.MigrateMetaTo(to, redisCfg, opts...)
+}
+
+//register custom meta store, before set config
+//selected by config MetaStore value
+func (f *Pond) RegisterMetaStore(
+	name string,
+	creator storage.MetaStoreCreator) error {
+	return storage.RegisterMetaStore(name, creator)
 }
 
 //set config, STEP-2
@@ -148,4 +419,20 @@ func (f *Pond) GenRedisConfig() *conf.RedisConfig {
 		FileInfoHashKeys: define.DefaultFileInfoHashKeys,
 		FileBaseHashKeys: define.DefaultFileBaseHashKeys,
 	}
+}
+
+//gen file query
+func (f *Pond) GenFileQuery() *json.FileQueryJson {
+	return &json.FileQueryJson{
+		OrderBy: define.FileQueryOrderByCreateAt,
+		Page: define.DefaultPage,
+		PageSize: define.DefaultPageSize,
+	}
+}
+
+//gen write option
+func (f *Pond) GenWriteOption() *conf.WriteOption {
+	return &conf.WriteOption{
+		Metadata: map[string]string{},
+	}
 }
\ No newline at end of file
@@ -2,10 +2,18 @@ package search
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
 	"github.com/andyzhou/pond/define"
 	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/utils"
 	"github.com/andyzhou/tinylib/queue"
 	"github.com/andyzhou/tinysearch"
+	"github.com/blevesearch/bleve/v2"
+	tDefine "github.com/andyzhou/tinysearch/define"
 	tJson "github.com/andyzhou/tinysearch/json"
 )
 
@@ -14,13 +22,19 @@ import (
  * @author <AndyZhou>
  * @mail <diudiu8848@163.com>
  * - file short url as primary key
+ * - cursor list sorted by create time, same time sorted by short url
+ * - query numeric fields filtered by search, name and content type exact matched after
  */
 
+//inter flush request of queue
+type queueFlushReq struct {}
+
 //face info
 type FileInfo struct {
 	ts        *tinysearch.Service //reference
 	queue     *queue.Queue        //inter write or delete queue
 	queueSize int
+	utils.Utils
 }
 
 //construct
@@ -50,6 +64,19 @@ func (f *FileInfo) Quit() {
 	}
 }
 
+//flush queued write or delete opt
+//wait until all opt before sent into queue done
+func (f *FileInfo) Flush() error {
+	if f.queueSize <= 0 {
+		return nil
+	}
+	if f.queue == nil {
+		return errors.New("inter queue is nil or closed")
+	}
+	_, err := f.queue.SendData(&queueFlushReq{}, true)
+	return err
+}
+
 //get batch by create at desc
 func (f *FileInfo) GetBathByTime(
 		page, pageSize int,
@@ -66,6 +93,158 @@ func (f *FileInfo) GetBathByTime(
 	return f.QueryBatch(nil, sorts, page, pageSize)
 }
 
+//get batch after cursor by create at desc
+//cursor is create time and short url of last file info
+//same time file info sorted and paged by short url desc
+//total only counted for the first page, zero for next pages
+//sync opt
+func (f *FileInfo) GetBatchByCursor(
+		createAt int64,
+		shortUrl string,
+		pageSize int,
+	) (int64, []*json.FileInfoJson, error) {
+	//check
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+
+	var (
+		total int64
+	)
+
+	//get same time file after cursor
+	result := make([]*json.FileInfoJson, 0)
+	maxTime := math.MaxFloat64
+	if shortUrl != "" {
+		sameTimeInfos, err := f.getBatchOfTime(createAt, shortUrl, pageSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		result = append(result, sameTimeInfos...)
+		maxTime = float64(createAt)
+	}
+	if len(result) >= pageSize {
+		return total, result, nil
+	}
+
+	//get older file, min <= createAt < max
+	need := pageSize - len(result)
+	filters := []*tJson.FilterField{
+		{
+			Kind: tDefine.FilterKindNumericRange,
+			Field: define.SearchFieldOfCreateAt,
+			MinFloatVal: 0,
+			MaxFloatVal: maxTime,
+			IsMust: true,
+		},
+	}
+	sorts := []*tJson.SortField{
+		{
+			Field: define.SearchFieldOfCreateAt,
+			Desc: true,
+		},
+	}
+	olderTotal, olderInfos, err := f.QueryBatch(filters, sorts, define.DefaultPage, need)
+	if err != nil {
+		return 0, nil, err
+	}
+	if shortUrl == "" {
+		//all file matched for the first page
+		total = olderTotal
+	}
+	if len(olderInfos) >= need {
+		//same time file of last one may be cut, page them by short url
+		lastTime := olderInfos[len(olderInfos)-1].CreateAt
+		idx := len(olderInfos)
+		for idx > 0 && olderInfos[idx-1].CreateAt == lastTime {
+			idx--
+		}
+		sameTimeInfos, subErr := f.getBatchOfTime(lastTime, "", need - idx)
+		if subErr != nil {
+			return 0, nil, subErr
+		}
+		olderInfos = append(olderInfos[:idx], sameTimeInfos...)
+	}
+	f.sortByTime(olderInfos)
+	if len(olderInfos) > need {
+		olderInfos = olderInfos[:need]
+	}
+	result = append(result, olderInfos...)
+	return total, result, nil
+}
+
+//get batch by query
+//if no name or content type filter and not order by name, page by search
+//else scan all candidates, exact match and page
+//sync opt
+func (f *FileInfo) GetBatchByQuery(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	//check
+	if query == nil {
+		return 0, nil, errors.New("invalid parameter")
+	}
+
+	//setup numeric filters
+	filters := make([]*tJson.FilterField, 0)
+	if query.CreateAtMin > 0 || query.CreateAtMax > 0 {
+		filters = append(filters, f.genRangeFilter(define.SearchFieldOfCreateAt, query.CreateAtMin, query.CreateAtMax))
+	}
+	if query.SizeMin > 0 || query.SizeMax > 0 {
+		filters = append(filters, f.genRangeFilter(define.SearchFieldOfSize, query.SizeMin, query.SizeMax))
+	}
+	if query.ChunkFileId > 0 {
+		filters = append(filters, f.genRangeFilter(define.SearchFieldOfChunkFileId, query.ChunkFileId, query.ChunkFileId))
+	}
+	if query.ExpireAtMax > 0 {
+		filters = append(filters, f.genRangeFilter(define.SearchFieldOfExpireAt, 1, query.ExpireAtMax))
+	}
+
+	//page by search directly
+	if query.ContentType == "" && query.NamePrefix == "" && query.OrderBy != define.FileQueryOrderByName {
+		sortField := define.SearchFieldOfCreateAt
+		if query.OrderBy == define.FileQueryOrderBySize {
+			sortField = define.SearchFieldOfSize
+		}
+		sorts := []*tJson.SortField{
+			{
+				Field: sortField,
+				Desc: !query.Asc,
+			},
+		}
+		return f.QueryBatch(filters, sorts, query.Page, query.PageSize)
+	}
+
+	//content type terms as candidate filter
+	if query.ContentType != "" {
+		filters = append(filters, &tJson.FilterField{
+			Kind: tDefine.FilterKindMatch,
+			Field: define.SearchFieldOfContentType,
+			Val: query.ContentType,
+			IsMust: true,
+		})
+	}
+
+	//scan all candidates
+	result := make([]*json.FileInfoJson, 0)
+	for page := define.DefaultPage; ; page++ {
+		_, infos, err := f.QueryBatch(filters, nil, page, define.FileQueryScanSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, v := range infos {
+			if f.IsFileMatched(query, v) {
+				result = append(result, v)
+			}
+		}
+		if len(infos) < define.FileQueryScanSize {
+			break
+		}
+	}
+	f.SortFiles(query, result)
+	return int64(len(result)), f.PageFiles(query, result), nil
+}
+
 //get batch info
 //sync opt
 func (f *FileInfo) QueryBatch(
@@ -107,8 +286,7 @@ func (f *FileInfo) QueryBatch(
 			total--
 			continue
 		}
-		infoObj := json.NewFileInfoJson()
-		infoObj.Decode(v.OrgJson, infoObj)
+		infoObj, _ := f.decodeDoc(v.OrgJson)
 		if infoObj == nil || infoObj.ShortUrl == "" {
 			total--
 			continue
@@ -118,6 +296,29 @@ func (f *FileInfo) QueryBatch(
 	return total, result, nil
 }
 
+//check file info exists or not
+//sync opt
+func (f *FileInfo) IsExists(shortUrl string) (bool, error) {
+	//check
+	if shortUrl == "" {
+		return false, errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return false, errors.New("inter search engine not init")
+	}
+
+	//get relate face
+	index := f.ts.GetIndex(define.SearchIndexOfFileInfo)
+	doc := f.ts.GetDoc()
+
+	//get data by short url
+	hitDoc, err := doc.GetDoc(index, shortUrl)
+	if err != nil || hitDoc == nil {
+		return false, err
+	}
+	return true, nil
+}
+
 //get one file info
 //sync opt
 func (f *FileInfo) GetOne(
@@ -144,9 +345,7 @@ func (f *FileInfo) GetOne(
 	}
 
 	//decode json
-	fileInfoJson := json.NewFileInfoJson()
-	err = fileInfoJson.Decode(hitDoc.OrgJson, fileInfoJson)
-	return fileInfoJson, err
+	return f.decodeDoc(hitDoc.OrgJson)
 }
 
 //del one file info
@@ -205,6 +404,35 @@ func (f *FileInfo) AddOne(obj *json.FileInfoJson) error {
 //private func
 ////////////////
 
+//decode doc json
+//nested meta data stored as `metadata.{key}` fields
+func (f *FileInfo) decodeDoc(orgJson []byte) (*json.FileInfoJson, error) {
+	//decode base json
+	fileInfoJson := json.NewFileInfoJson()
+	err := fileInfoJson.Decode(orgJson, fileInfoJson)
+	if err != nil {
+		return nil, err
+	}
+
+	//decode meta data fields
+	fieldMap := map[string]interface{}{}
+	err = fileInfoJson.Decode(orgJson, &fieldMap)
+	if err != nil {
+		return fileInfoJson, nil
+	}
+	prefix := define.SearchFieldOfMetadata + "."
+	for k, v := range fieldMap {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if fileInfoJson.Metadata == nil {
+			fileInfoJson.Metadata = map[string]string{}
+		}
+		fileInfoJson.Metadata[strings.TrimPrefix(k, prefix)] = fmt.Sprintf("%v", v)
+	}
+	return fileInfoJson, nil
+}
+
 //del one doc
 func (f *FileInfo) delOneDoc(shortUrl string) error {
 	//check
@@ -224,6 +452,77 @@ func (f *FileInfo) delOneDoc(shortUrl string) error {
 	return err
 }
 
+//get batch file info of the same create time
+//sorted by short url desc, short url less than assigned if not empty
+//paged by doc id range of search, doc id is short url
+func (f *FileInfo) getBatchOfTime(
+		createAt int64,
+		shortUrl string,
+		size int,
+	) ([]*json.FileInfoJson, error) {
+	//check
+	if f.ts == nil {
+		return nil, errors.New("inter search engine not init")
+	}
+
+	//setup search request
+	minTime, maxTime := float64(createAt), float64(createAt + 1)
+	minInclusive, maxInclusive := true, false
+	timeQuery := bleve.NewNumericRangeInclusiveQuery(&minTime, &maxTime, &minInclusive, &maxInclusive)
+	timeQuery.SetField(define.SearchFieldOfCreateAt)
+	searchReq := bleve.NewSearchRequestOptions(timeQuery, size, 0, false)
+	searchReq.SortBy([]string{define.SearchSortOfDocIdDesc})
+	if shortUrl != "" {
+		searchReq.SetSearchAfter([]string{shortUrl})
+	}
+
+	//search doc ids
+	index := f.ts.GetIndex(define.SearchIndexOfFileInfo)
+	searchResult, err := index.GetIndex().Search(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	//get file info by doc id
+	result := make([]*json.FileInfoJson, 0)
+	for _, hit := range searchResult.Hits {
+		fileInfo, subErr := f.GetOne(hit.ID)
+		if subErr != nil {
+			return nil, subErr
+		}
+		if fileInfo != nil {
+			result = append(result, fileInfo)
+		}
+	}
+	return result, nil
+}
+
+//gen numeric range filter, min <= val <= max
+//zero value means no limit
+func (f *FileInfo) genRangeFilter(field string, min, max int64) *tJson.FilterField {
+	maxVal := math.MaxFloat64
+	if max > 0 {
+		maxVal = float64(max + 1)
+	}
+	return &tJson.FilterField{
+		Kind: tDefine.FilterKindNumericRange,
+		Field: field,
+		MinFloatVal: float64(min),
+		MaxFloatVal: maxVal,
+		IsMust: true,
+	}
+}
+
+//sort file info by create time desc, short url desc
+func (f *FileInfo) sortByTime(infos []*json.FileInfoJson) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].CreateAt != infos[j].CreateAt {
+			return infos[i].CreateAt > infos[j].CreateAt
+		}
+		return infos[i].ShortUrl > infos[j].ShortUrl
+	})
+}
+
 //add one doc
 func (f *FileInfo) addOneDoc(obj *json.FileInfoJson) error {
 	//check
@@ -268,6 +567,10 @@ func (f *FileInfo) cbForQueueOpt(
 			shortUrl, _ := data.(string)
 			err = f.delOneDoc(shortUrl)
 		}
+	case *queueFlushReq:
+		{
+			//for flush opt, opt before done
+		}
 	default:
 		{
 			err = errors.New("invalid data type")
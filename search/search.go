@@ -31,6 +31,8 @@ type Search struct {
 	initDone  bool
 	info      *FileInfo
 	base      *FileBase
+	upload    *Upload
+	version   *Version
 	ts        *tinysearch.Service
 	utils.Utils
 }
@@ -71,6 +73,14 @@ func (f *Search) GetFileBase() *FileBase {
 	return f.base
 }
 
+func (f *Search) GetUpload() *Upload {
+	return f.upload
+}
+
+func (f *Search) GetVersion() *Version {
+	return f.version
+}
+
 //set root path
 func (f *Search) SetCore(
 	path string,
@@ -116,4 +126,6 @@ func (f *Search) initIndex() {
 	//init file base and info
 	f.base = NewFileBase(f.ts, f.queueSize)
 	f.info = NewFileInfo(f.ts, f.queueSize)
+	f.upload = NewUpload(f.ts)
+	f.version = NewVersion(f.ts)
 }
\ No newline at end of file
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * meta store face base on local search
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - file info and base storage into local search
+ * - upload session storage into local search
+ */
+
+//face info
+type Store struct {
+	search *Search
+}
+
+//construct
+func NewStore(dataPath string, queueSize int) (*Store, error) {
+	//setup search core
+	//search not shared, store of other data path can be opened
+	search := NewSearch()
+	err := search.SetCore(dataPath, queueSize)
+	if err != nil {
+		return nil, err
+	}
+	this := &Store{
+		search: search,
+	}
+	return this, nil
+}
+
+//quit
+func (f *Store) Quit() {
+	f.search.Quit()
+}
+
+//flush queued file info write or delete
+func (f *Store) Flush() error {
+	return f.search.GetFileInfo().Flush()
+}
+
+///////////////
+//api for info
+///////////////
+
+//get file info
+func (f *Store) GetInfo(shortUrl string) (*json.FileInfoJson, error) {
+	return f.search.GetFileInfo().GetOne(shortUrl)
+}
+
+//save file info
+func (f *Store) PutInfo(obj *json.FileInfoJson) error {
+	return f.search.GetFileInfo().AddOne(obj)
+}
+
+//del file info
+func (f *Store) DelInfo(shortUrl string) error {
+	return f.search.GetFileInfo().DelOne(shortUrl)
+}
+
+//check file info exists or not
+func (f *Store) IsInfoExists(shortUrl string) (bool, error) {
+	return f.search.GetFileInfo().IsExists(shortUrl)
+}
+
+//get batch file info by create time desc
+func (f *Store) ListInfoByTime(page, pageSize int) (int64, []*json.FileInfoJson, error) {
+	return f.search.GetFileInfo().GetBathByTime(page, pageSize)
+}
+
+//get batch file info after cursor by create time desc
+func (f *Store) ListInfoByCursor(
+		createAt int64,
+		shortUrl string,
+		pageSize int,
+	) (int64, []*json.FileInfoJson, error) {
+	return f.search.GetFileInfo().GetBatchByCursor(createAt, shortUrl, pageSize)
+}
+
+//get batch file info by query
+func (f *Store) ListInfoByQuery(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	return f.search.GetFileInfo().GetBatchByQuery(query)
+}
+
+///////////////
+//api for base
+///////////////
+
+//get file base
+func (f *Store) GetBase(md5 string) (*json.FileBaseJson, error) {
+	return f.search.GetFileBase().GetOne(md5)
+}
+
+//save file base
+func (f *Store) PutBase(obj *json.FileBaseJson) error {
+	return f.search.GetFileBase().AddOne(obj)
+}
+
+//del file base
+func (f *Store) DelBase(md5 string) error {
+	return f.search.GetFileBase().DelOne(md5)
+}
+
+//get batch removed file base
+func (f *Store) ListRemovedBase(page, pageSize int) (int64, []*json.FileBaseJson, error) {
+	return f.search.GetFileBase().GetBatchByRemoved(page, pageSize)
+}
+
+/////////////////
+//api for upload
+/////////////////
+
+//get upload session
+func (f *Store) GetUpload(uploadId string) (*json.UploadJson, error) {
+	return f.search.GetUpload().GetOne(uploadId)
+}
+
+//save upload session
+func (f *Store) PutUpload(obj *json.UploadJson) error {
+	return f.search.GetUpload().AddOne(obj)
+}
+
+//del upload session
+func (f *Store) DelUpload(uploadId string) error {
+	return f.search.GetUpload().DelOne(uploadId)
+}
+
+//get batch upload session
+func (f *Store) ListUploads(page, pageSize int) (int64, []*json.UploadJson, error) {
+	return f.search.GetUpload().GetBatch(page, pageSize)
+}
+
+/////////////////
+//api for version
+/////////////////
+
+//get file versions
+func (f *Store) GetVersions(shortUrl string) (*json.FileVersionsJson, error) {
+	return f.search.GetVersion().GetOne(shortUrl)
+}
+
+//save file versions
+func (f *Store) PutVersions(obj *json.FileVersionsJson) error {
+	return f.search.GetVersion().AddOne(obj)
+}
+
+//del file versions
+func (f *Store) DelVersions(shortUrl string) error {
+	return f.search.GetVersion().DelOne(shortUrl)
+}
+
+//get batch file versions
+func (f *Store) ListVersions(page, pageSize int) (int64, []*json.FileVersionsJson, error) {
+	return f.search.GetVersion().GetBatch(page, pageSize)
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"errors"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/tinysearch"
+	tJson "github.com/andyzhou/tinysearch/json"
+)
+
+/*
+ * upload session search face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - upload id as primary key
+ * - all opt is sync, session read after write
+ */
+
+//face info
+type Upload struct {
+	ts *tinysearch.Service //reference
+}
+
+//construct
+func NewUpload(ts *tinysearch.Service) *Upload {
+	this := &Upload{
+		ts: ts,
+	}
+	this.interInit()
+	return this
+}
+
+//get batch sort by create time asc
+//sync opt
+func (f *Upload) GetBatch(
+		page, pageSize int,
+	) (int64, []*json.UploadJson, error) {
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	if f.ts == nil {
+		return 0, nil, errors.New("inter search engine not init")
+	}
+
+	//init query opt
+	queryOpt := tJson.NewQueryOptJson()
+	queryOpt.Sort = []*tJson.SortField{
+		{
+			Field: define.SearchFieldOfCreateAt,
+		},
+	}
+	queryOpt.Page = page
+	queryOpt.PageSize = pageSize
+	queryOpt.NeedDocs = true
+
+	//search data
+	index := f.ts.GetIndex(define.SearchIndexOfUpload)
+	query := f.ts.GetQuery()
+	resultSlice, err := query.Query(index, queryOpt)
+	if err != nil || resultSlice == nil || resultSlice.Total <= 0 {
+		return 0, nil, err
+	}
+
+	//format result
+	result := make([]*json.UploadJson, 0)
+	total := int64(resultSlice.Total)
+	for _, v := range resultSlice.Records {
+		if v == nil || v.OrgJson == nil {
+			total--
+			continue
+		}
+		uploadObj := json.NewUploadJson()
+		uploadObj.Decode(v.OrgJson, uploadObj)
+		if uploadObj.UploadId == "" {
+			total--
+			continue
+		}
+		result = append(result, uploadObj)
+	}
+	return total, result, nil
+}
+
+//get one upload session
+//sync opt
+func (f *Upload) GetOne(uploadId string) (*json.UploadJson, error) {
+	//check
+	if uploadId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return nil, errors.New("inter search engine not init")
+	}
+
+	//get data by id
+	index := f.ts.GetIndex(define.SearchIndexOfUpload)
+	hitDoc, err := f.ts.GetDoc().GetDoc(index, uploadId)
+	if err != nil || hitDoc == nil {
+		return nil, err
+	}
+
+	//decode json
+	uploadObj := json.NewUploadJson()
+	err = uploadObj.Decode(hitDoc.OrgJson, uploadObj)
+	return uploadObj, err
+}
+
+//del one upload session
+//sync opt
+func (f *Upload) DelOne(uploadId string) error {
+	//check
+	if uploadId == "" {
+		return errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return errors.New("inter search engine not init")
+	}
+	index := f.ts.GetIndex(define.SearchIndexOfUpload)
+	return f.ts.GetDoc().RemoveDoc(index, uploadId)
+}
+
+//add one upload session
+//sync opt
+func (f *Upload) AddOne(obj *json.UploadJson) error {
+	//check
+	if obj == nil || obj.UploadId == "" {
+		return errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return errors.New("inter search engine not init")
+	}
+	index := f.ts.GetIndex(define.SearchIndexOfUpload)
+	return f.ts.GetDoc().AddDoc(index, obj.UploadId, obj)
+}
+
+////////////////
+//private func
+////////////////
+
+//inter init
+func (f *Upload) interInit() {
+	if f.ts == nil {
+		return
+	}
+	//add index
+	err := f.ts.AddIndex(define.SearchIndexOfUpload)
+	if err != nil {
+		panic(any(err))
+	}
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"errors"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/tinysearch"
+	tJson "github.com/andyzhou/tinysearch/json"
+)
+
+/*
+ * file versions search face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - short url as primary key
+ * - all opt is sync, versions read after write
+ */
+
+//face info
+type Version struct {
+	ts *tinysearch.Service //reference
+}
+
+//construct
+func NewVersion(ts *tinysearch.Service) *Version {
+	this := &Version{
+		ts: ts,
+	}
+	this.interInit()
+	return this
+}
+
+//get batch sort by create time asc
+//sync opt
+func (f *Version) GetBatch(
+		page, pageSize int,
+	) (int64, []*json.FileVersionsJson, error) {
+	//check
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	if f.ts == nil {
+		return 0, nil, errors.New("inter search engine not init")
+	}
+
+	//init query opt
+	queryOpt := tJson.NewQueryOptJson()
+	queryOpt.Sort = []*tJson.SortField{
+		{
+			Field: define.SearchFieldOfCreateAt,
+		},
+	}
+	queryOpt.Page = page
+	queryOpt.PageSize = pageSize
+	queryOpt.NeedDocs = true
+
+	//search data
+	index := f.ts.GetIndex(define.SearchIndexOfVersion)
+	query := f.ts.GetQuery()
+	resultSlice, err := query.Query(index, queryOpt)
+	if err != nil || resultSlice == nil || resultSlice.Total <= 0 {
+		return 0, nil, err
+	}
+
+	//format result
+	result := make([]*json.FileVersionsJson, 0)
+	total := int64(resultSlice.Total)
+	for _, v := range resultSlice.Records {
+		if v == nil || v.OrgJson == nil {
+			total--
+			continue
+		}
+		versionsObj := json.NewFileVersionsJson()
+		versionsObj.Decode(v.OrgJson, versionsObj)
+		if versionsObj.ShortUrl == "" {
+			total--
+			continue
+		}
+		result = append(result, versionsObj)
+	}
+	return total, result, nil
+}
+
+//get versions of one file
+//sync opt
+func (f *Version) GetOne(shortUrl string) (*json.FileVersionsJson, error) {
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return nil, errors.New("inter search engine not init")
+	}
+
+	//get data by id
+	index := f.ts.GetIndex(define.SearchIndexOfVersion)
+	hitDoc, err := f.ts.GetDoc().GetDoc(index, shortUrl)
+	if err != nil || hitDoc == nil {
+		return nil, err
+	}
+
+	//decode json
+	versionsObj := json.NewFileVersionsJson()
+	err = versionsObj.Decode(hitDoc.OrgJson, versionsObj)
+	return versionsObj, err
+}
+
+//del versions of one file
+//sync opt
+func (f *Version) DelOne(shortUrl string) error {
+	//check
+	if shortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return errors.New("inter search engine not init")
+	}
+	index := f.ts.GetIndex(define.SearchIndexOfVersion)
+	return f.ts.GetDoc().RemoveDoc(index, shortUrl)
+}
+
+//add versions of one file
+//sync opt
+func (f *Version) AddOne(obj *json.FileVersionsJson) error {
+	//check
+	if obj == nil || obj.ShortUrl == "" {
+		return errors.New("invalid parameter")
+	}
+	if f.ts == nil {
+		return errors.New("inter search engine not init")
+	}
+	index := f.ts.GetIndex(define.SearchIndexOfVersion)
+	return f.ts.GetDoc().AddDoc(index, obj.ShortUrl, obj)
+}
+
+////////////////
+//private func
+////////////////
+
+//inter init
+func (f *Version) interInit() {
+	if f.ts == nil {
+		return
+	}
+	//add index
+	err := f.ts.AddIndex(define.SearchIndexOfVersion)
+	if err != nil {
+		panic(any(err))
+	}
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/tinylib/util"
+)
+
+/*
+ * http object serving face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - GET /{shortUrl}, support range and etag
+ * - POST /, upload new data
+ * - PUT /{shortUrl}, overwrite data
+ * - DELETE /{shortUrl}, delete data
+ * - GET /files?page=, files list
+ */
+
+//face info
+type Server struct {
+	pond   *pond.Pond //reference
+	server *http.Server
+	util.BaseJson
+}
+
+//construct
+func NewServer(p *pond.Pond) *Server {
+	this := &Server{
+		pond: p,
+	}
+	return this
+}
+
+//quit
+func (f *Server) Quit() {
+	if f.server != nil {
+		f.server.Close()
+	}
+}
+
+//start http server
+//sync opt, block until server closed
+func (f *Server) Start(address string) error {
+	//check
+	if address == "" {
+		return errors.New("invalid parameter")
+	}
+	if f.server != nil {
+		return errors.New("server had started")
+	}
+
+	//init http server
+	timeOut := time.Duration(define.DefaultServerTimeOut) * time.Second
+	f.server = &http.Server{
+		Addr: address,
+		Handler: f,
+		ReadHeaderTimeout: timeOut,
+	}
+	err := f.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+//serve http request
+//application `http.Handler`
+func (f *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	//check
+	if f.pond == nil {
+		f.writeError(w, http.StatusServiceUnavailable, errors.New("pond not setup"))
+		return
+	}
+
+	//files list
+	if r.URL.Path == define.ServerPathOfFiles {
+		if r.Method != http.MethodGet {
+			f.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		f.getFiles(w, r)
+		return
+	}
+
+	//upload new data
+	shortUrl := strings.TrimPrefix(r.URL.Path, define.ServerPathOfRoot)
+	if shortUrl == "" {
+		if r.Method != http.MethodPost {
+			f.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		f.writeData(w, r)
+		return
+	}
+	if strings.Contains(shortUrl, define.ServerPathOfRoot) {
+		f.writeError(w, http.StatusNotFound, define.ErrFileNotFound)
+		return
+	}
+
+	//short url opt
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		f.readData(w, r, shortUrl)
+	case http.MethodPut:
+		f.overwriteData(w, r, shortUrl)
+	case http.MethodDelete:
+		f.delData(w, r, shortUrl)
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+/////////////////
+//private func
+/////////////////
+
+//get files list
+func (f *Server) getFiles(w http.ResponseWriter, r *http.Request) {
+	//get para
+	page, _ := strconv.Atoi(r.URL.Query().Get(define.ServerParaOfPage))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get(define.ServerParaOfPageSize))
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 || pageSize > define.DefaultPageSizeMax {
+		pageSize = define.DefaultPageSize
+	}
+
+	//get batch files, by cursor if assigned
+	var (
+		total int64
+		files []*json.FileInfoJson
+		nextCursor string
+		err error
+	)
+	query := r.URL.Query()
+	if query.Has(define.ServerParaOfCursor) {
+		total, files, nextCursor, err = f.pond.GetFilesByCursor(query.Get(define.ServerParaOfCursor), pageSize)
+	}else{
+		total, files, err = f.pond.GetFiles(page, pageSize)
+	}
+	if err != nil {
+		f.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//format response
+	resp := json.NewFilesRespJson()
+	resp.Total = total
+	resp.Page = page
+	resp.NextCursor = nextCursor
+	if files != nil {
+		resp.Files = files
+	}
+	f.writeJson(w, http.StatusOK, resp)
+}
+
+//read data
+//support range and conditional request
+//HEAD and not modified request only opt meta data
+func (f *Server) readData(
+	w http.ResponseWriter,
+	r *http.Request,
+	shortUrl string) {
+	//get file info
+	fileInfo, err := f.pond.Stat(shortUrl)
+	if err != nil {
+		f.writeError(w, f.getErrStatus(err), err)
+		return
+	}
+
+	//setup header
+	//etag and modify time changed when data written
+	etag := ""
+	if fileInfo.Md5 != "" {
+		etag = fmt.Sprintf(define.ServerETagPara, fileInfo.Md5, fileInfo.Size, fileInfo.Crc32)
+		w.Header().Set("ETag", etag)
+	}
+	if fileInfo.ContentType != "" {
+		w.Header().Set("Content-Type", fileInfo.ContentType)
+	}
+	for k, v := range fileInfo.Metadata {
+		w.Header().Set(define.ServerHeaderOfMetaPrefix + k, v)
+	}
+	modTime := time.Unix(fileInfo.CreateAt, 0)
+	if fileInfo.UpdateAt > 0 {
+		modTime = time.Unix(fileInfo.UpdateAt, 0)
+	}
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	//check not modified
+	if etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	//head request
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	//open data reader
+	reader, subErr := f.pond.Open(shortUrl)
+	if subErr != nil {
+		f.writeError(w, f.getErrStatus(subErr), subErr)
+		return
+	}
+	defer reader.Close()
+
+	//serve content, range and etag checked inside
+	http.ServeContent(w, r, fileInfo.Name, modTime, reader)
+}
+
+//write new data
+func (f *Server) writeData(w http.ResponseWriter, r *http.Request) {
+	//check
+	if r.ContentLength <= 0 {
+		f.writeError(w, http.StatusLengthRequired, errors.New("content length required"))
+		return
+	}
+
+	//setup write option
+	opt := f.genWriteOption(r)
+
+	//write stream data
+	shortUrl, err := f.pond.WriteFrom(r.Body, r.ContentLength, opt)
+	if err != nil {
+		f.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	//format response
+	resp := json.NewWriteRespJson()
+	resp.ShortUrl = shortUrl
+	f.writeJson(w, http.StatusCreated, resp)
+}
+
+//overwrite data
+func (f *Server) overwriteData(
+	w http.ResponseWriter,
+	r *http.Request,
+	shortUrl string) {
+	//read whole data
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		f.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(data) <= 0 {
+		f.writeError(w, http.StatusBadRequest, errors.New("empty data"))
+		return
+	}
+
+	//overwrite data
+	opt := f.genWriteOption(r)
+	_, err = f.pond.WriteDataWithOption(data, opt, shortUrl)
+	if err != nil {
+		f.writeError(w, f.getErrStatus(err), err)
+		return
+	}
+
+	//format response
+	resp := json.NewWriteRespJson()
+	resp.ShortUrl = shortUrl
+	f.writeJson(w, http.StatusOK, resp)
+}
+
+//delete data
+func (f *Server) delData(
+	w http.ResponseWriter,
+	r *http.Request,
+	shortUrl string) {
+	err := f.pond.DelData(shortUrl)
+	if err != nil {
+		f.writeError(w, f.getErrStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//gen write option by request
+//name from query para, meta data, ttl and expire time from header
+func (f *Server) genWriteOption(r *http.Request) *conf.WriteOption {
+	opt := f.pond.GenWriteOption()
+	opt.Name = r.URL.Query().Get(define.ServerParaOfName)
+	opt.ContentType = r.Header.Get("Content-Type")
+	opt.TTL, _ = strconv.ParseInt(r.Header.Get(define.ServerHeaderOfTTL), 10, 64)
+	opt.ExpireAt, _ = strconv.ParseInt(r.Header.Get(define.ServerHeaderOfExpireAt), 10, 64)
+	opt.Compress = r.Header.Get(define.ServerHeaderOfCompress)
+	for k, v := range r.Header {
+		if len(v) <= 0 || !strings.HasPrefix(k, define.ServerHeaderOfMetaPrefix) {
+			continue
+		}
+		opt.Metadata[strings.TrimPrefix(k, define.ServerHeaderOfMetaPrefix)] = v[0]
+	}
+	return opt
+}
+
+//get http status by error
+func (f *Server) getErrStatus(err error) int {
+	if errors.Is(err, define.ErrFileNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+//write error response
+func (f *Server) writeError(w http.ResponseWriter, status int, err error) {
+	resp := json.NewErrRespJson()
+	if err != nil {
+		resp.ErrMsg = err.Error()
+	}
+	f.writeJson(w, status, resp)
+}
+
+//write json response
+func (f *Server) writeJson(w http.ResponseWriter, status int, obj interface{}) {
+	data, err := f.Encode(obj)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
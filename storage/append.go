@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * storage append data face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - raw data extended in place, if next space is free or data at chunk tail
+ * - else appended data written as new part, linked with old data as multipart file
+ * - data of check same mode always linked, content md5 can't be changed in place
+ */
+
+//append data to the end of file
+//return new file size, error
+func (f *Storage) AppendData(
+		shortUrl string,
+		data []byte,
+	) (int64, error) {
+	//check
+	if shortUrl == "" || len(data) <= 0 {
+		return 0, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return 0, errors.New("config didn't setup")
+	}
+
+	//append with compact read locker
+	//file base updated with locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+	f.searchLocker.Lock()
+	defer f.searchLocker.Unlock()
+
+	//get file and base info
+	fileInfo, err := f.getFileInfo(shortUrl)
+	if err != nil {
+		return 0, err
+	}
+	if fileInfo == nil || f.isFileExpired(fileInfo) {
+		return 0, define.ErrFileNotFound
+	}
+	fileBase, err := f.getFileBase(fileInfo.Md5)
+	if err != nil || fileBase == nil {
+		return 0, errors.New("can't get file base info")
+	}
+
+	//extend in place first
+	if f.isAppendInPlace(fileBase) {
+		err = f.appendInPlace(fileInfo, fileBase, data)
+		if err == nil {
+			return fileInfo.Size, nil
+		}
+		if !errors.Is(err, define.ErrSpaceNotEnough) {
+			return 0, err
+		}
+	}
+
+	//link appended data as new part
+	return f.appendLinked(fileInfo, fileBase, data)
+}
+
+/////////////////
+//private func
+/////////////////
+
+//check file base can be extended in place or not
+func (f *Storage) isAppendInPlace(fileBase *json.FileBaseJson) bool {
+	if f.cfg.CheckSame || len(fileBase.Parts) > 0 || fileBase.Appoints > 1 {
+		return false
+	}
+	if f.manager.IsWriteSkipped(fileBase.ChunkFileId) {
+		return false
+	}
+	chunkObj, _ := f.manager.GetChunkById(fileBase.ChunkFileId)
+	return chunkObj != nil && chunkObj.IsAppendable(fileBase.Offset)
+}
+
+//extend file data in place
+//free space after data claimed if need
+//return define.ErrSpaceNotEnough if no space
+func (f *Storage) appendInPlace(
+		fileInfo *json.FileInfoJson,
+		fileBase *json.FileBaseJson,
+		data []byte,
+	) error {
+	var (
+		claimedSize int64
+	)
+	chunkObj, err := f.manager.GetChunkById(fileBase.ChunkFileId)
+	if err != nil || chunkObj == nil {
+		return errors.New("can't get active chunk")
+	}
+
+	//claim free space after data
+	oldSpace := f.getSpaceSize(fileBase.Blocks)
+	needSpace := chunk.CalRecordSpace(f.cfg, fileBase.Size + int64(len(data)))
+	if needSpace > oldSpace {
+		claimed, _ := f.manager.GetRunningChunk().ClaimSpace(
+			fileBase.ChunkFileId,
+			fileBase.Offset + oldSpace,
+			needSpace - oldSpace)
+		if claimed {
+			claimedSize = needSpace - oldSpace
+		}
+	}
+
+	//update file base and info, write ahead log before data written
+	oldBase, oldInfo := *fileBase, *fileInfo
+	isLogged := false
+	prepare := func(blocks int64) error {
+		fileBase.Size += int64(len(data))
+		fileBase.Blocks = blocks
+		if fileBase.Crc32 > 0 {
+			fileBase.Crc32 = f.Crc32Update(fileBase.Crc32, data)
+		}
+		fileInfo.Size = fileBase.Size
+		fileInfo.Blocks = fileBase.Blocks
+		fileInfo.Crc32 = fileBase.Crc32
+		fileInfo.UpdateAt = time.Now().Unix()
+		err := f.manager.GetWal().Append(define.WalOpOfOverwrite, fileInfo.ShortUrl, fileBase, fileInfo)
+		isLogged = err == nil
+		return err
+	}
+
+	//append chunk data
+	resp := chunkObj.AppendRecord(fileBase.Offset, data, oldSpace + claimedSize, prepare)
+	if resp.Err != nil {
+		//restore file base and info
+		*fileBase, *fileInfo = oldBase, oldInfo
+		if isLogged {
+			f.manager.GetWal().Append(define.WalOpOfOverwrite, fileInfo.ShortUrl, fileBase, fileInfo)
+		}
+
+		//release claimed space
+		if claimedSize > 0 {
+			f.releaseChunkSpace(fileBase.ChunkFileId, fileBase.Offset + oldSpace, claimedSize)
+		}
+		return resp.Err
+	}
+
+	//save info and base data
+	return f.saveFile(fileBase, fileInfo)
+}
+
+//link appended data with old data as multipart file
+//appended data written as new part
+//return new file size, error
+func (f *Storage) appendLinked(
+		fileInfo *json.FileInfoJson,
+		fileBase *json.FileBaseJson,
+		data []byte,
+	) (int64, error) {
+	var (
+		partMd5 string
+		err error
+	)
+	//write appended data as new part
+	if f.cfg.CheckSame {
+		partMd5, err = f.Md5Sum(data)
+	}else{
+		partMd5, err = f.genRandMd5()
+	}
+	if err != nil {
+		return 0, err
+	}
+	codec, err := f.getCodec(nil)
+	if err != nil {
+		return 0, err
+	}
+	partBase, walOp, err := f.writeFileBase(partMd5, data, codec)
+	if err != nil {
+		return 0, err
+	}
+	err = f.manager.GetWal().Append(walOp, "", partBase, nil)
+	if err != nil {
+		return 0, err
+	}
+	err = f.saveFileBase(partBase)
+	if err != nil {
+		return 0, err
+	}
+
+	//gen linked parts
+	isMultipart := len(fileBase.Parts) > 0
+	linkParts := []string{fileBase.Md5}
+	if isMultipart {
+		linkParts = append([]string{}, fileBase.Parts...)
+	}
+	linkParts = append(linkParts, partBase.Md5)
+	newMd5, err := f.genPartsMd5(linkParts)
+	if err != nil {
+		f.releaseParts([]string{partBase.Md5})
+		return 0, err
+	}
+
+	//get same or create new file base
+	//part md5 is random without check same, no same file base
+	var (
+		newBase *json.FileBaseJson
+	)
+	if f.cfg.CheckSame {
+		newBase, _ = f.getFileBase(newMd5)
+	}
+	isReused := newBase != nil && !newBase.Removed
+	if isReused {
+		newBase.Appoints++
+	}else{
+		if isMultipart && fileBase.Appoints > 1 {
+			//old parts shared by new file base
+			err = f.incrParts(fileBase.Parts)
+			if err != nil {
+				f.releaseParts([]string{partBase.Md5})
+				return 0, err
+			}
+		}
+		newBase = json.NewFileBaseJson()
+		newBase.Md5 = newMd5
+		newBase.Size = fileBase.Size + partBase.Size
+		newBase.Parts = linkParts
+		newBase.Appoints = define.DefaultFileAppoint
+		newBase.CreateAt = fileBase.CreateAt
+	}
+
+	//update file info
+	fileInfo.Md5 = newBase.Md5
+	fileInfo.Size = newBase.Size
+	fileInfo.Parts = len(newBase.Parts)
+	fileInfo.ChunkFileId = 0
+	fileInfo.Offset = 0
+	fileInfo.Blocks = 0
+	fileInfo.Crc32 = 0
+	fileInfo.UpdateAt = time.Now().Unix()
+	err = f.manager.GetWal().Append(define.WalOpOfWrite, fileInfo.ShortUrl, newBase, fileInfo)
+	if err != nil {
+		f.releaseParts([]string{partBase.Md5})
+		return 0, err
+	}
+	err = f.saveFile(newBase, fileInfo)
+	if err != nil {
+		//saved part base not linked, release it
+		f.releaseParts([]string{partBase.Md5})
+		return 0, err
+	}
+
+	//release references of old data
+	if isReused {
+		//data kept by same file base
+		return newBase.Size, f.releaseParts([]string{partBase.Md5, fileBase.Md5})
+	}
+	if !isMultipart {
+		//old data used as the first part
+		return newBase.Size, nil
+	}
+	if fileBase.Appoints > 1 {
+		return newBase.Size, f.releaseParts([]string{fileBase.Md5})
+	}
+
+	//old parts taken over by new file base
+	fileBase.Appoints = 0
+	fileBase.Removed = true
+	err = f.manager.GetWal().Append(define.WalOpOfDelete, "", fileBase, nil)
+	if err != nil {
+		return newBase.Size, err
+	}
+	return newBase.Size, f.delFileBase(fileBase.Md5)
+}
+
+//incr appoint value of parts
+//should be called with compact read locker
+func (f *Storage) incrParts(partsMd5 []string) error {
+	for _, partMd5 := range partsMd5 {
+		partBase, _ := f.getFileBase(partMd5)
+		if partBase == nil || partBase.Removed {
+			return errors.New("can't get part file base info")
+		}
+		partBase.Appoints++
+		err := f.manager.GetWal().Append(define.WalOpOfWrite, "", partBase, nil)
+		if err != nil {
+			return err
+		}
+		err = f.saveFileBase(partBase)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,9 +1,10 @@
 package storage
 
 import (
-	"github.com/andyzhou/pond/data"
+	"errors"
+
+	"github.com/andyzhou/pond/face"
 	"github.com/andyzhou/pond/json"
-	"github.com/andyzhou/pond/search"
 )
 
 /*
@@ -11,18 +12,18 @@ import (
  */
 
 type Base struct {
-	data     *data.InterRedisData //reference obj
-	useRedis bool
+	store face.IMetaStore //reference obj
 }
 
-//set use redis
-func (f *Base) SetBaseUseRedis(useRedis bool) {
-	f.useRedis = useRedis
+//get whole space size of data blocks
+//include data header
+func (f *Base) getSpaceSize(blocks int64) int64 {
+	return int64(face.PacketHeadSize) + blocks
 }
 
-//set data obj
-func (f *Base) SetBaseData(data *data.InterRedisData) {
-	f.data = data
+//set meta store
+func (f *Base) SetBaseStore(store face.IMetaStore) {
+	f.store = store
 }
 
 ////////////////////////////
@@ -31,101 +32,91 @@ func (f *Base) SetBaseData(data *data.InterRedisData) {
 
 //del file info
 func (f *Base) delFileInfo(shortUrl string) error {
-	var (
-		err error
-	)
-	if f.useRedis {
-		//save into redis
-		fileData := f.data.GetFile()
-		err = fileData.DelInfo(shortUrl)
-	}else{
-		//save into search
-		fileInfoSearch := search.GetSearch().GetFileInfo()
-		err = fileInfoSearch.DelOne(shortUrl)
+	if f.store == nil {
+		return errors.New("meta store not setup")
 	}
-	return err
+	return f.store.DelInfo(shortUrl)
 }
 
 //del file base info
 func (f *Base) delFileBase(md5 string) error {
-	var (
-		err error
-	)
-	if f.useRedis {
-		//del from redis
-		fileData := f.data.GetFile()
-		err = fileData.DelBase(md5)
-	}else{
-		//del from search
-		fileBaseSearch := search.GetSearch().GetFileBase()
-		err = fileBaseSearch.DelOne(md5)
+	if f.store == nil {
+		return errors.New("meta store not setup")
 	}
-	return err
+	return f.store.DelBase(md5)
+}
+
+//check file info exists or not
+func (f *Base) isFileInfoExists(shortUrl string) (bool, error) {
+	if f.store == nil {
+		return false, errors.New("meta store not setup")
+	}
+	return f.store.IsInfoExists(shortUrl)
 }
 
 //get file base and info
 func (f *Base) getFileInfo(shortUrl string) (*json.FileInfoJson, error) {
-	var (
-		fileInfoObj *json.FileInfoJson
-		err error
-	)
-	if f.useRedis {
-		//get from redis
-		fileData := f.data.GetFile()
-		fileInfoObj, err = fileData.GetInfo(shortUrl)
-	}else{
-		//get from search
-		fileInfoSearch := search.GetSearch().GetFileInfo()
-		fileInfoObj, err = fileInfoSearch.GetOne(shortUrl)
+	if f.store == nil {
+		return nil, errors.New("meta store not setup")
+	}
+	fileInfoObj, err := f.store.GetInfo(shortUrl)
+	if fileInfoObj != nil && err == nil {
+		f.syncFileLocation(fileInfoObj)
 	}
 	return fileInfoObj, err
 }
 
+//sync data location of file info from file base
+//file base location may be changed by chunk compact
+func (f *Base) syncFileLocation(fileInfoObj *json.FileInfoJson) {
+	fileBaseObj, _ := f.getFileBase(fileInfoObj.Md5)
+	if fileBaseObj == nil || fileBaseObj.Removed {
+		return
+	}
+	fileInfoObj.ChunkFileId = fileBaseObj.ChunkFileId
+	fileInfoObj.Offset = fileBaseObj.Offset
+	fileInfoObj.Blocks = fileBaseObj.Blocks
+}
+
+//sync data location of batch file info
+func (f *Base) syncFilesLocation(filesInfo []*json.FileInfoJson) {
+	for _, fileInfoObj := range filesInfo {
+		if fileInfoObj != nil {
+			f.syncFileLocation(fileInfoObj)
+		}
+	}
+}
+
 func (f *Base) getFileBase(md5 string) (*json.FileBaseJson, error) {
-	var (
-		fileBaseObj *json.FileBaseJson
-		err error
-	)
-	if f.useRedis {
-		//get from redis
-		fileData := f.data.GetFile()
-		fileBaseObj, err = fileData.GetBase(md5)
-	}else{
-		//get from search
-		fileBaseSearch := search.GetSearch().GetFileBase()
-		fileBaseObj, err = fileBaseSearch.GetOne(md5)
+	if f.store == nil {
+		return nil, errors.New("meta store not setup")
 	}
-	return fileBaseObj, err
+	return f.store.GetBase(md5)
 }
 
 //save file base and info
 func (f *Base) saveFileInfo(obj *json.FileInfoJson) error {
-	var (
-		err error
-	)
-	if f.useRedis {
-		//save into redis
-		fileData := f.data.GetFile()
-		err = fileData.AddInfo(obj)
-	}else{
-		//save into search
-		fileInfoSearch := search.GetSearch().GetFileInfo()
-		err = fileInfoSearch.AddOne(obj)
+	if f.store == nil {
+		return errors.New("meta store not setup")
 	}
-	return err
+	return f.store.PutInfo(obj)
 }
 func (f *Base) saveFileBase(obj *json.FileBaseJson) error {
-	var (
-		err error
-	)
-	if f.useRedis {
-		//save into redis
-		fileData := f.data.GetFile()
-		err = fileData.AddBase(obj)
-	}else{
-		//save into search
-		fileBaseSearch := search.GetSearch().GetFileBase()
-		err = fileBaseSearch.AddOne(obj)
+	if f.store == nil {
+		return errors.New("meta store not setup")
+	}
+	return f.store.PutBase(obj)
+}
+
+//save file base and info
+//saved in one transaction if store support
+func (f *Base) saveFile(base *json.FileBaseJson, info *json.FileInfoJson) error {
+	if txStore, ok := f.store.(face.IMetaTxStore); ok {
+		return txStore.PutFile(base, info)
 	}
-	return err
-}
\ No newline at end of file
+	err := f.saveFileBase(base)
+	if err != nil {
+		return err
+	}
+	return f.saveFileInfo(info)
+}
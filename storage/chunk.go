@@ -3,9 +3,11 @@ package storage
 import (
 	"errors"
 	"github.com/andyzhou/pond/conf"
+	"log"
 	"sync"
 
-	"github.com/andyzhou/pond/data"
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/face"
 	"github.com/andyzhou/pond/json"
 )
 
@@ -14,20 +16,14 @@ import (
  * @author <AndyZhou>
  * @mail <diudiu8848@163.com>
  * - mark removed and re-use logic
+ * - removed space re-used by extent allocator
  */
 
-//inter data type
-type (
-	removedBaseFile struct {
-		md5    string
-		blocks int64
-	}
-)
-
 //face info
 type Chunk struct {
 	cfg          *conf.Config //reference
 	removed 	 *Removed
+	extent       *Extent
 	Base
 	sync.RWMutex
 }
@@ -36,6 +32,7 @@ type Chunk struct {
 func NewChunk(wg *sync.WaitGroup) *Chunk {
 	this := &Chunk{
 		removed: NewRemoved(wg),
+		extent: NewExtent(),
 	}
 	return this
 }
@@ -43,6 +40,7 @@ func NewChunk(wg *sync.WaitGroup) *Chunk {
 //quit
 func (f *Chunk) Quit() {
 	f.removed.Quit()
+	f.extent.Save()
 }
 
 ////remove file base info
@@ -83,30 +81,115 @@ func (f *Chunk) Quit() {
 //	return err
 //}
 
-//get available removed file base info
-func (f *Chunk) GetAvailableRemovedFileBase(
-	dataSize int64) (*json.FileBaseJson, error) {
-	var (
-		matchedMd5 string
-		err error
-	)
-	//check
-	if dataSize <= 0 {
-		return nil, errors.New("invalid parameter")
+//allocate space for new data
+//removed data of dropped space can't be re-used any more
+//return chunkId(zero means no space), offset, error
+func (f *Chunk) AllocSpace(
+		size int64,
+		skip func(chunkId int64) bool,
+	) (int64, int64, error) {
+	chunkId, offset, dropped, err := f.extent.Alloc(size, skip)
+	if err != nil || chunkId <= 0 {
+		return chunkId, offset, err
+	}
+
+	//clean dropped removed data
+	for _, md5 := range dropped {
+		if f.removed.TakeRemoved(md5) {
+			f.delFileBase(md5)
+		}
+	}
+	if len(dropped) > 0 {
+		f.removed.SaveRemoved()
+	}
+	return chunkId, offset, nil
+}
+
+//claim assigned free space
+//removed data of claimed space can't be re-used any more
+//return claimed or not, error
+func (f *Chunk) ClaimSpace(chunkId, offset, size int64) (bool, error) {
+	claimed, dropped, err := f.extent.Claim(chunkId, offset, size)
+	if err != nil || !claimed {
+		return claimed, err
+	}
+
+	//clean dropped removed data
+	for _, md5 := range dropped {
+		if f.removed.TakeRemoved(md5) {
+			f.delFileBase(md5)
+		}
+	}
+	if len(dropped) > 0 {
+		f.removed.SaveRemoved()
+	}
+	return true, nil
+}
+
+//occupy assigned space whether free or not
+//used for wal replay, space used after extent file saved
+func (f *Chunk) OccupySpace(chunkId, offset, size int64) error {
+	dropped, err := f.extent.Occupy(chunkId, offset, size)
+	if err != nil {
+		return err
 	}
 
-	//get valid removed file base md5
-	matchedMd5, err = f.removed.GetAvailableRemovedFileBase(dataSize)
-	if matchedMd5 == "" {
-		return nil, err
+	//clean dropped removed data
+	for _, md5 := range dropped {
+		if f.removed.TakeRemoved(md5) {
+			f.delFileBase(md5)
+		}
 	}
+	if len(dropped) > 0 {
+		f.removed.SaveRemoved()
+	}
+	return nil
+}
+
+//free chunk space
+//used for failed or useless written data
+func (f *Chunk) FreeSpace(chunkId, offset, size int64) error {
+	return f.extent.Free(chunkId, offset, size, "")
+}
+
+//free batch chunk space
+//used for old data space of archived files
+func (f *Chunk) FreeSpaces(extents map[int64][]*json.ExtentOwnerJson) error {
+	return f.extent.FreeBatch(extents)
+}
+
+//check chunk space is free or not
+func (f *Chunk) IsFreeSpace(chunkId, offset int64) bool {
+	return f.extent.IsFree(chunkId, offset)
+}
+
+//gen walk skip of chunk free space
+//free extents snapshot, walk chunk data after extents changed
+func (f *Chunk) GenWalkSkip(chunkId int64) chunk.WalkSkip {
+	return f.extent.SnapshotFreeEnd(chunkId)
+}
 
-	//get file base info
-	fileBase, subErr := f.getFileBase(matchedMd5)
-	return fileBase, subErr
+//get free space size of all chunks
+func (f *Chunk) GetFreeSpace() map[int64]int64 {
+	return f.extent.GetFreeSize()
+}
+
+//take out assigned removed file base info
+//used for re-use same removed data
+//only success when removed data still kept
+func (f *Chunk) TakeRemovedBaseInfo(obj *json.FileBaseJson) bool {
+	//check
+	if obj == nil || !f.removed.TakeRemoved(obj.Md5) {
+		return false
+	}
+	f.removed.SaveRemoved()
+
+	//take back space
+	return f.extent.Take(obj.ChunkFileId, obj.Offset, f.getSpaceSize(obj.Blocks), obj.Md5)
 }
 
 //add new removed file base info
+//space of removed data freed
 func (f *Chunk) AddRemovedBaseInfo(
 	obj *json.FileBaseJson) error {
 	//check
@@ -122,7 +205,87 @@ func (f *Chunk) AddRemovedBaseInfo(
 
 	//force save removed data
 	err = f.removed.SaveRemoved()
-	return err
+	if err != nil {
+		return err
+	}
+
+	//free data space
+	return f.extent.Free(obj.ChunkFileId, obj.Offset, f.getSpaceSize(obj.Blocks), obj.Md5)
+}
+
+//keep removed file base info, space not freed
+//used for wal replay, space kept by extent file
+func (f *Chunk) KeepRemovedBaseInfo(obj *json.FileBaseJson) error {
+	//check
+	if obj == nil {
+		return errors.New("invalid parameter")
+	}
+	if f.removed.AddRemoved(obj.Md5, obj.Blocks) != nil {
+		//already kept
+		return nil
+	}
+	return f.removed.SaveRemoved()
+}
+
+//free space of removed file base if not free
+//used for wal replay, delete may break before space freed
+func (f *Chunk) FreeRemovedSpace(obj *json.FileBaseJson) error {
+	//check
+	if obj == nil {
+		return errors.New("invalid parameter")
+	}
+	if f.extent.IsFree(obj.ChunkFileId, obj.Offset) {
+		return nil
+	}
+	return f.extent.Free(obj.ChunkFileId, obj.Offset, f.getSpaceSize(obj.Blocks), obj.Md5)
+}
+
+//drop removed file base info, space not taken
+//used for wal replay, space kept by extent file
+func (f *Chunk) DropRemovedBaseInfo(md5 string) error {
+	if !f.removed.TakeRemoved(md5) {
+		return nil
+	}
+	return f.removed.SaveRemoved()
+}
+
+//get all removed file base info
+func (f *Chunk) GetRemovedFileBases() []*json.FileBaseJson {
+	result := make([]*json.FileBaseJson, 0)
+	for _, md5 := range f.removed.GetRemovedMd5List() {
+		fileBase, _ := f.getFileBase(md5)
+		if fileBase != nil {
+			result = append(result, fileBase)
+		}
+	}
+	return result
+}
+
+//purge removed data and free space of assigned chunk
+//removed data of this chunk can't be re-used any more
+//return purged count
+func (f *Chunk) PurgeRemovedOfChunk(chunkId int64) int {
+	purged := 0
+	owners, _ := f.extent.RemoveChunk(chunkId)
+	for _, md5 := range owners {
+		if f.removed.TakeRemoved(md5) {
+			f.delFileBase(md5)
+			purged++
+		}
+	}
+	for _, fileBase := range f.GetRemovedFileBases() {
+		if fileBase.ChunkFileId != chunkId {
+			continue
+		}
+		if f.removed.TakeRemoved(fileBase.Md5) {
+			f.delFileBase(fileBase.Md5)
+			purged++
+		}
+	}
+	if purged > 0 {
+		f.removed.SaveRemoved()
+	}
+	return purged
 }
 
 //save removed
@@ -130,15 +293,14 @@ func (f *Chunk) SaveRemoved() error {
 	return f.removed.SaveRemoved()
 }
 
-//set redis
-func (f *Chunk) SetUseRedis(useRedis bool) {
-	f.useRedis = useRedis
-	f.SetBaseUseRedis(useRedis)
+//save extent if changed
+func (f *Chunk) SaveExtent() error {
+	return f.extent.Save()
 }
 
-//set inter data
-func (f *Chunk) SetData(data *data.InterRedisData) {
-	f.data = data
+//set meta store
+func (f *Chunk) SetStore(store face.IMetaStore) {
+	f.SetBaseStore(store)
 }
 
 //set config
@@ -150,4 +312,32 @@ func (f *Chunk) SetConfig(cfg *conf.Config) {
 
 	//set removed config
 	f.removed.SetConfig(cfg)
+
+	//set extent config
+	loaded, err := f.extent.SetConfig(cfg)
+	if err != nil {
+		log.Printf("chunk.SetConfig, load extent failed, err:%v\n", err.Error())
+	}
+	if !loaded && err == nil {
+		//init extents from old removed data
+		f.initExtentFromRemoved()
+	}
+}
+
+//////////////
+//private func
+//////////////
+
+//init extents from removed data
+func (f *Chunk) initExtentFromRemoved() error {
+	extents := map[int64][]*json.ExtentOwnerJson{}
+	for _, fileBase := range f.GetRemovedFileBases() {
+		owner := &json.ExtentOwnerJson{
+			Md5: fileBase.Md5,
+			Offset: fileBase.Offset,
+			Size: f.getSpaceSize(fileBase.Blocks),
+		}
+		extents[fileBase.ChunkFileId] = append(extents[fileBase.ChunkFileId], owner)
+	}
+	return f.extent.FreeBatch(extents)
 }
\ No newline at end of file
@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/tinylib/queue"
+)
+
+/*
+ * chunk compact face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - pick chunk by dead space ratio, dead space is free space of chunk
+ * - live data found by file base of file info, upload part and file version
+ * - copy live data into fresh chunk, then update file base location
+ * - data copied with compact read locker, only location swapped with locker
+ * - file info location synced from file base when read
+ * - remove old chunk data and meta file, abort if still referenced
+ * - run online, copy speed limited by config
+ */
+
+//compact result
+type CompactResult struct {
+	ChunkId     int64 //compacted chunk id
+	NewChunkId  int64 //chunk id of live data copied into, zero means no live data
+	Objects     int64 //copied live data count
+	CopiedBytes int64
+	FreedBytes  int64
+}
+
+//check chunk is compacting or not
+func (f *Manager) IsCompacting(chunkId int64) bool {
+	_, ok := f.compactingMap.Load(chunkId)
+	return ok
+}
+
+//check chunk skipped for new data or not
+//compacting and archive chunk not written
+func (f *Manager) IsWriteSkipped(chunkId int64) bool {
+	return f.IsCompacting(chunkId) || f.IsArchive(chunkId)
+}
+
+//get dead space ratio of all chunks
+//return map[chunkId]ratio
+func (f *Manager) GetChunkDeadRatio() map[int64]float64 {
+	//dead size is free space of chunk
+	deadSize := f.chunk.GetFreeSpace()
+
+	//format result
+	result := map[int64]float64{}
+	for chunkId, size := range f.GetAllChunkSize() {
+		if size <= 0 {
+			continue
+		}
+		ratio := float64(deadSize[chunkId]) / float64(size)
+		if ratio > 1 {
+			ratio = 1
+		}
+		result[chunkId] = ratio
+	}
+	return result
+}
+
+//compact chunks
+//if no chunk id assigned, pick chunks by dead space ratio
+func (f *Manager) Compact(chunkIds ...int64) ([]*CompactResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	if !atomic.CompareAndSwapInt32(&f.compactRunning, 0, 1) {
+		return nil, errors.New("compact is running")
+	}
+	defer atomic.StoreInt32(&f.compactRunning, 0)
+
+	//pick chunks by dead space ratio
+	if chunkIds == nil || len(chunkIds) <= 0 {
+		chunkIds = f.pickCompactChunks()
+	}
+
+	//compact chunk one by one
+	results := make([]*CompactResult, 0)
+	for _, chunkId := range chunkIds {
+		result, err := f.compactChunk(chunkId)
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+////////////////
+//private func
+////////////////
+
+//pick chunks which dead space ratio exceed config
+func (f *Manager) pickCompactChunks() []int64 {
+	minRatio := f.cfg.CompactRatio
+	if minRatio <= 0 {
+		minRatio = define.DefaultCompactRatio
+	}
+	chunkIds := make([]int64, 0)
+	for chunkId, ratio := range f.GetChunkDeadRatio() {
+		if ratio >= minRatio {
+			chunkIds = append(chunkIds, chunkId)
+		}
+	}
+	sort.Slice(chunkIds, func(i, j int) bool {
+		return chunkIds[i] < chunkIds[j]
+	})
+	return chunkIds
+}
+
+//compact one chunk
+func (f *Manager) compactChunk(chunkId int64) (*CompactResult, error) {
+	var (
+		dstChunk *chunk.Chunk
+		err error
+	)
+	//get source chunk
+	srcChunk, err := f.GetChunkById(chunkId)
+	if err != nil {
+		return nil, err
+	}
+	if srcChunk == nil {
+		return nil, errors.New("can't get chunk obj")
+	}
+	result := &CompactResult{
+		ChunkId: chunkId,
+	}
+	chunkSize := srcChunk.GetChunkActiveSize()
+
+	//mark compacting, no new data written into this chunk
+	//removed data of this chunk can't be re-used any more
+	f.compactLocker.Lock()
+	f.compactingMap.Store(chunkId, true)
+	f.chunk.PurgeRemovedOfChunk(chunkId)
+	f.checkpointWal() //old wal entries may point to purged data
+	f.compactLocker.Unlock()
+	defer f.compactingMap.Delete(chunkId)
+
+	//find live file base of this chunk
+	fileBases, infoRefs, err := f.listChunkRefs(chunkId)
+	if err != nil {
+		return result, err
+	}
+	if infoRefs > 0 {
+		return result, fmt.Errorf("chunk %v referenced by %v file info without file base", chunkId, infoRefs)
+	}
+
+	//fresh chunk for live data, init when first live data found
+	//live data of archive chunk copied into archive chunk
+	getDstChunk := func() (*chunk.Chunk, error) {
+		if dstChunk == nil {
+			newChunkId := f.InitNewChunk()
+			atomic.AddInt32(&f.chunks, 1)
+			result.NewChunkId = newChunkId
+			if f.IsArchive(chunkId) {
+				err = f.addArchive(newChunkId)
+				if err != nil {
+					return nil, err
+				}
+			}
+			dstChunk, err = f.GetChunkById(newChunkId)
+		}
+		return dstChunk, err
+	}
+
+	//copy live data record one by one
+	beginTime := time.Now()
+	for _, fileBase := range fileBases {
+		copied, subErr := f.compactRecord(srcChunk, getDstChunk, fileBase.Offset, fileBase.Md5)
+		if subErr != nil {
+			return result, subErr
+		}
+		if copied > 0 {
+			result.Objects++
+			result.CopiedBytes += copied
+			f.throttleCompact(result.CopiedBytes, beginTime)
+		}
+	}
+
+	//check no file base or info point to this chunk
+	fileBases, infoRefs, err = f.listChunkRefs(chunkId)
+	if err != nil {
+		return result, err
+	}
+	if len(fileBases) > 0 || infoRefs > 0 {
+		return result, fmt.Errorf("chunk %v still referenced by %v file base and %v file info",
+			chunkId, len(fileBases), infoRefs)
+	}
+
+	//remove compacted chunk
+	f.compactLocker.Lock()
+	defer f.compactLocker.Unlock()
+	f.chunkMap.Delete(chunkId)
+	f.archiveMap.Delete(chunkId)
+	f.chunk.PurgeRemovedOfChunk(chunkId)
+	atomic.AddInt32(&f.chunks, -1)
+	err = f.meta.RemoveChunk(chunkId)
+	if err != nil {
+		return result, err
+	}
+	err = f.removeChunkFile(srcChunk) //pinned by opened reader, removed when closed
+	f.checkpointWal() //wal entries may point to removed chunk
+	result.FreedBytes = chunkSize - result.CopiedBytes
+	return result, err
+}
+
+//copy one live record into dest chunk
+//record is live only when file base still point to it
+//data copied with read locker, location swapped with locker
+//record changed when copy, copied again with locker
+//return copied bytes, zero means dead record
+func (f *Manager) compactRecord(
+		srcChunk *chunk.Chunk,
+		getDstChunk func() (*chunk.Chunk, error),
+		offset int64,
+		md5 string,
+	) (int64, error) {
+	//copy with read locker
+	f.compactLocker.RLock()
+	oldBase, _ := f.chunk.getFileBase(md5)
+	if !f.isLiveRecord(oldBase, srcChunk.GetFileId(), offset) {
+		f.compactLocker.RUnlock()
+		return 0, nil
+	}
+	dstChunk, err := getDstChunk()
+	if err != nil {
+		f.compactLocker.RUnlock()
+		return 0, err
+	}
+	resp := dstChunk.CopyRecord(srcChunk, offset)
+	f.compactLocker.RUnlock()
+	if resp.Err != nil {
+		return 0, resp.Err
+	}
+
+	//swap location with locker
+	f.compactLocker.Lock()
+	defer f.compactLocker.Unlock()
+	fileBase, _ := f.chunk.getFileBase(md5)
+	if !f.isLiveRecord(fileBase, srcChunk.GetFileId(), offset) {
+		//record removed or moved when copy
+		f.chunk.FreeSpace(dstChunk.GetFileId(), resp.NewOffSet, f.chunk.getSpaceSize(resp.BlockSize))
+		return 0, nil
+	}
+	if fileBase.Size != oldBase.Size || fileBase.Blocks != oldBase.Blocks ||
+		fileBase.Crc32 != oldBase.Crc32 {
+		//record changed in place when copy, copy again
+		f.chunk.FreeSpace(dstChunk.GetFileId(), resp.NewOffSet, f.chunk.getSpaceSize(resp.BlockSize))
+		resp = dstChunk.CopyRecord(srcChunk, offset)
+		if resp.Err != nil {
+			return 0, resp.Err
+		}
+	}
+
+	//update file base location
+	fileBase.ChunkFileId = dstChunk.GetFileId()
+	fileBase.Offset = resp.NewOffSet
+	fileBase.Blocks = resp.BlockSize
+	err = f.chunk.saveFileBase(fileBase)
+	if err != nil {
+		return 0, err
+	}
+	return f.chunk.getSpaceSize(resp.BlockSize), nil
+}
+
+//list live file base and file info reference of chunk
+//file base found by file info, upload part and file version
+//file info without live file base referenced by its own location
+//return file bases sorted by offset, file info reference count, error
+func (f *Manager) listChunkRefs(chunkId int64) ([]*json.FileBaseJson, int64, error) {
+	var (
+		infoRefs int64
+	)
+	fileBases := make([]*json.FileBaseJson, 0)
+	checkedMd5s := map[string]bool{}
+
+	//check file base and its parts
+	var checkBase func(md5 string) (bool, error)
+	checkBase = func(md5 string) (bool, error) {
+		if checkedMd5s[md5] {
+			return true, nil
+		}
+		fileBase, err := f.store.GetBase(md5)
+		if err != nil || fileBase == nil || fileBase.Removed {
+			return false, err
+		}
+		checkedMd5s[md5] = true
+		for _, partMd5 := range fileBase.Parts {
+			_, err = checkBase(partMd5)
+			if err != nil {
+				return true, err
+			}
+		}
+		if len(fileBase.Parts) <= 0 && fileBase.ChunkFileId == chunkId {
+			fileBases = append(fileBases, fileBase)
+		}
+		return true, nil
+	}
+
+	//check file info and its file base
+	checkInfo := func(fileInfo *json.FileInfoJson) error {
+		isLive, err := checkBase(fileInfo.Md5)
+		if err != nil {
+			return err
+		}
+		if !isLive && fileInfo.Parts <= 0 && fileInfo.ChunkFileId == chunkId {
+			infoRefs++
+		}
+		return nil
+	}
+
+	//scan all file info
+	var (
+		createAt int64
+		shortUrl string
+	)
+	for {
+		_, filesInfo, err := f.store.ListInfoByCursor(createAt, shortUrl, define.FileQueryScanSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, fileInfo := range filesInfo {
+			err = checkInfo(fileInfo)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		if len(filesInfo) < define.FileQueryScanSize {
+			break
+		}
+		lastInfo := filesInfo[len(filesInfo)-1]
+		createAt, shortUrl = lastInfo.CreateAt, lastInfo.ShortUrl
+	}
+
+	//scan parts of upload session
+	uploadStore, ok := f.store.(face.IMetaUploadStore)
+	for page := 1; ok; page++ {
+		_, uploads, err := uploadStore.ListUploads(page, define.FileQueryScanSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, upload := range uploads {
+			for _, part := range upload.Parts {
+				_, err = checkBase(part.Md5)
+				if err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+		if len(uploads) < define.FileQueryScanSize {
+			break
+		}
+	}
+
+	//scan file info of versions
+	versionStore, ok := f.store.(face.IMetaVersionStore)
+	for page := 1; ok; page++ {
+		_, versionsList, err := versionStore.ListVersions(page, define.FileQueryScanSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, versions := range versionsList {
+			for _, version := range versions.Versions {
+				if version.Info == nil {
+					continue
+				}
+				err = checkInfo(version.Info)
+				if err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+		if len(versionsList) < define.FileQueryScanSize {
+			break
+		}
+	}
+	sort.Slice(fileBases, func(i, j int) bool {
+		return fileBases[i].Offset < fileBases[j].Offset
+	})
+	return fileBases, infoRefs, nil
+}
+
+//check record is live or not
+//file base still point to record of chunk
+func (f *Manager) isLiveRecord(
+		fileBase *json.FileBaseJson,
+		chunkId, offset int64,
+	) bool {
+	return fileBase != nil && !fileBase.Removed &&
+		fileBase.ChunkFileId == chunkId &&
+		fileBase.Offset == offset
+}
+
+//throttle compact copy speed
+func (f *Manager) throttleCompact(copiedBytes int64, beginTime time.Time) {
+	if f.cfg.CompactRate <= 0 {
+		return
+	}
+	expected := time.Duration(float64(copiedBytes) / float64(f.cfg.CompactRate) * float64(time.Second))
+	elapsed := time.Since(beginTime)
+	if expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+}
+
+//cb for auto compact
+func (f *Manager) cbForAutoCompact(inputs ...interface{}) error {
+	results, err := f.Compact()
+	if err != nil {
+		log.Printf("manager.cbForAutoCompact failed, err:%v\n", err.Error())
+		return err
+	}
+	for _, result := range results {
+		log.Printf("manager.cbForAutoCompact, chunk:%v, objects:%v, freed:%v\n",
+			result.ChunkId, result.Objects, result.FreedBytes)
+	}
+	return nil
+}
+
+//start auto compact ticker
+func (f *Manager) startCompactTicker() {
+	f.compactTicker = queue.NewTicker(define.CompactTickerSeconds)
+	f.compactTicker.SetCheckerCallback(f.cbForAutoCompact)
+}
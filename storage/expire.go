@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/tinylib/queue"
+)
+
+/*
+ * file expire face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - file expire time assigned by write option
+ * - expired file not readable, treated as not found
+ * - expired file deleted by sweeper in manager ticker
+ * - expired file picked by expire index of meta store if support, else by query
+ * - expired upload session aborted by sweeper
+ */
+
+//set expired file sweeper
+//called in manager ticker
+func (f *Manager) SetExpireSweeper(cb func() (int, error)) {
+	f.expireSweeper = cb
+}
+
+//sweep expired files and upload sessions
+//return deleted files and aborted sessions, error
+func (f *Storage) SweepExpired() (int, error) {
+	var (
+		deleted int
+	)
+	//check
+	if !f.initDone {
+		return 0, errors.New("config didn't setup")
+	}
+
+	//loop pick expired files and delete
+	//failed files kept in first page, page size grown to skip them
+	failedUrls := map[string]bool{}
+	query := json.NewFileQueryJson()
+	query.ExpireAtMax = time.Now().Unix()
+	query.OrderBy = define.FileQueryOrderByCreateAt
+	query.Asc = true
+	query.Page = define.DefaultPage
+	for {
+		query.PageSize = define.FileQueryScanSize + len(failedUrls)
+		filesInfo, err := f.getExpiredFiles(query)
+		if err != nil {
+			return deleted, err
+		}
+		picked := 0
+		for _, fileInfo := range filesInfo {
+			if failedUrls[fileInfo.ShortUrl] {
+				continue
+			}
+			picked++
+			err = f.DeleteData(fileInfo.ShortUrl)
+			if err != nil {
+				log.Printf("storage.SweepExpired, delete %v failed, err:%v\n", fileInfo.ShortUrl, err.Error())
+				failedUrls[fileInfo.ShortUrl] = true
+				continue
+			}
+			deleted++
+		}
+		if len(filesInfo) < query.PageSize || picked <= 0 {
+			break
+		}
+	}
+
+	//abort expired upload sessions
+	aborted, err := f.sweepUploads()
+	return deleted + aborted, err
+}
+
+//check file expired or not
+func (f *Storage) isFileExpired(fileInfo *json.FileInfoJson) bool {
+	return fileInfo != nil && fileInfo.ExpireAt > 0 && fileInfo.ExpireAt <= time.Now().Unix()
+}
+
+////////////////
+//private func
+////////////////
+
+//get expired files of query page
+func (f *Storage) getExpiredFiles(query *json.FileQueryJson) ([]*json.FileInfoJson, error) {
+	if expireStore, ok := f.store.(face.IMetaExpireStore); ok {
+		return expireStore.ListInfoByExpire(query.ExpireAtMax, query.Page, query.PageSize)
+	}
+	_, filesInfo, err := f.GetFilesInfoByQuery(query)
+	return filesInfo, err
+}
+
+//cb for expire sweep
+func (f *Manager) cbForExpireSweep(inputs ...interface{}) error {
+	if f.expireSweeper == nil {
+		return nil
+	}
+	deleted, err := f.expireSweeper()
+	if err != nil {
+		log.Printf("manager.cbForExpireSweep failed, err:%v\n", err.Error())
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("manager.cbForExpireSweep, deleted:%v\n", deleted)
+	}
+	return nil
+}
+
+//start expire sweep ticker
+func (f *Manager) startExpireTicker() {
+	f.expireTicker = queue.NewTicker(define.ManagerTickerSeconds)
+	f.expireTicker.SetCheckerCallback(f.cbForExpireSweep)
+}
@@ -0,0 +1,556 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/utils"
+)
+
+/*
+ * chunk free extent allocator
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - free extents of each chunk, sorted by offset
+ * - best-fit allocate, split big extent, coalesce adjacent extents
+ * - removed data kept as extent owner, owner dropped when space allocated
+ * - changes saved into local gob file in batch, at wal checkpoint and quit
+ * - space used after saved occupied again by wal replay
+ * - replace by rename for crash safe
+ */
+
+//face info
+type Extent struct {
+	cfg        *conf.Config //reference
+	extentJson *json.ExtentJson
+	filePath   string
+	dirty      bool //changed but not saved
+	utils.Utils
+	sync.RWMutex
+}
+
+//init
+func init() {
+	gob.Register(&json.ExtentJson{})
+}
+
+//construct
+func NewExtent() *Extent {
+	this := &Extent{
+		extentJson: json.NewExtentJson(),
+	}
+	return this
+}
+
+//allocate space by best-fit
+//skip func used for filter chunks
+//return chunkId(zero means no space), offset, dropped owner md5s, error
+func (f *Extent) Alloc(
+		size int64,
+		skip func(chunkId int64) bool,
+	) (int64, int64, []string, error) {
+	var (
+		bestChunkId int64
+		bestIdx = -1
+		bestSize int64
+	)
+	//check
+	if size <= 0 {
+		return 0, 0, nil, errors.New("invalid parameter")
+	}
+
+	//pick best-fit extent with locker
+	f.Lock()
+	defer f.Unlock()
+	for chunkId, extents := range f.extentJson.Chunks {
+		if skip != nil && skip(chunkId) {
+			continue
+		}
+		for idx, extent := range extents {
+			if extent.Size < size {
+				continue
+			}
+			if bestIdx < 0 || extent.Size < bestSize ||
+				(extent.Size == bestSize && chunkId < bestChunkId) {
+				bestChunkId = chunkId
+				bestIdx = idx
+				bestSize = extent.Size
+			}
+		}
+	}
+	if bestIdx < 0 {
+		return 0, 0, nil, nil
+	}
+
+	//split extent, allocate from head
+	extents := f.extentJson.Chunks[bestChunkId]
+	extent := extents[bestIdx]
+	offset := extent.Offset
+	left := &json.FreeExtentJson{
+		Offset: offset + size,
+		Size: extent.Size - size,
+	}
+	dropped := make([]string, 0)
+	for _, owner := range extent.Owners {
+		if owner.Offset < left.Offset {
+			//owner data will be overwritten
+			dropped = append(dropped, owner.Md5)
+		}else{
+			left.Owners = append(left.Owners, owner)
+		}
+	}
+	if left.Size > 0 {
+		extents[bestIdx] = left
+	}else{
+		extents = append(extents[0:bestIdx], extents[bestIdx+1:]...)
+	}
+	f.setChunkExtents(bestChunkId, extents)
+	return bestChunkId, offset, dropped, nil
+}
+
+//free space, coalesce with adjacent extents
+//if md5 assigned, removed data kept as owner
+func (f *Extent) Free(chunkId, offset, size int64, md5 string) error {
+	//check
+	if chunkId <= 0 || offset < 0 || size <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//free with locker
+	f.Lock()
+	defer f.Unlock()
+	return f.free(chunkId, offset, size, md5)
+}
+
+//free batch space
+//used for init extents from removed data
+func (f *Extent) FreeBatch(extents map[int64][]*json.ExtentOwnerJson) error {
+	f.Lock()
+	defer f.Unlock()
+	for chunkId, owners := range extents {
+		for _, owner := range owners {
+			err := f.free(chunkId, owner.Offset, owner.Size, owner.Md5)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//take back space of removed data
+//only success when owner data still kept
+func (f *Extent) Take(chunkId, offset, size int64, md5 string) bool {
+	//check
+	if chunkId <= 0 || size <= 0 || md5 == "" {
+		return false
+	}
+
+	//find extent contain the owner with locker
+	f.Lock()
+	defer f.Unlock()
+	extents := f.extentJson.Chunks[chunkId]
+	idx := sort.Search(len(extents), func(i int) bool {
+		return extents[i].Offset + extents[i].Size > offset
+	})
+	if idx >= len(extents) {
+		return false
+	}
+	extent := extents[idx]
+	ownerIdx := -1
+	for i, owner := range extent.Owners {
+		if owner.Md5 == md5 && owner.Offset == offset && owner.Size == size {
+			ownerIdx = i
+			break
+		}
+	}
+	if ownerIdx < 0 {
+		return false
+	}
+
+	//split extent into two sides
+	head := &json.FreeExtentJson{
+		Offset: extent.Offset,
+		Size: offset - extent.Offset,
+	}
+	tail := &json.FreeExtentJson{
+		Offset: offset + size,
+		Size: extent.Offset + extent.Size - offset - size,
+	}
+	for i, owner := range extent.Owners {
+		if i == ownerIdx {
+			continue
+		}
+		if owner.Offset < offset {
+			head.Owners = append(head.Owners, owner)
+		}else{
+			tail.Owners = append(tail.Owners, owner)
+		}
+	}
+	parts := make([]*json.FreeExtentJson, 0)
+	if head.Size > 0 {
+		parts = append(parts, head)
+	}
+	if tail.Size > 0 {
+		parts = append(parts, tail)
+	}
+	newExtents := make([]*json.FreeExtentJson, 0, len(extents) + 1)
+	newExtents = append(newExtents, extents[:idx]...)
+	newExtents = append(newExtents, parts...)
+	newExtents = append(newExtents, extents[idx+1:]...)
+	f.setChunkExtents(chunkId, newExtents)
+	return true
+}
+
+//claim assigned space if it's free
+//used for extend data in place
+//return claimed or not, dropped owner md5s, error
+func (f *Extent) Claim(
+		chunkId, offset, size int64,
+	) (bool, []string, error) {
+	//check
+	if chunkId <= 0 || offset < 0 || size <= 0 {
+		return false, nil, errors.New("invalid parameter")
+	}
+
+	//find extent contain the space with locker
+	f.Lock()
+	defer f.Unlock()
+	extents := f.extentJson.Chunks[chunkId]
+	idx := sort.Search(len(extents), func(i int) bool {
+		return extents[i].Offset + extents[i].Size > offset
+	})
+	if idx >= len(extents) {
+		return false, nil, nil
+	}
+	extent := extents[idx]
+	if extent.Offset > offset || extent.Offset + extent.Size < offset + size {
+		return false, nil, nil
+	}
+
+	//split extent into two sides
+	head := &json.FreeExtentJson{
+		Offset: extent.Offset,
+		Size: offset - extent.Offset,
+	}
+	tail := &json.FreeExtentJson{
+		Offset: offset + size,
+		Size: extent.Offset + extent.Size - offset - size,
+	}
+	dropped := make([]string, 0)
+	for _, owner := range extent.Owners {
+		if owner.Offset + owner.Size <= offset {
+			head.Owners = append(head.Owners, owner)
+		}else if owner.Offset >= tail.Offset {
+			tail.Owners = append(tail.Owners, owner)
+		}else{
+			//owner data will be overwritten
+			dropped = append(dropped, owner.Md5)
+		}
+	}
+	parts := make([]*json.FreeExtentJson, 0)
+	if head.Size > 0 {
+		parts = append(parts, head)
+	}
+	if tail.Size > 0 {
+		parts = append(parts, tail)
+	}
+	newExtents := make([]*json.FreeExtentJson, 0, len(extents) + 1)
+	newExtents = append(newExtents, extents[:idx]...)
+	newExtents = append(newExtents, parts...)
+	newExtents = append(newExtents, extents[idx+1:]...)
+	f.setChunkExtents(chunkId, newExtents)
+	return true, dropped, nil
+}
+
+//remove all extents of chunk
+//return owner md5s
+func (f *Extent) RemoveChunk(chunkId int64) ([]string, error) {
+	f.Lock()
+	defer f.Unlock()
+	extents, ok := f.extentJson.Chunks[chunkId]
+	if !ok {
+		return nil, nil
+	}
+	owners := make([]string, 0)
+	for _, extent := range extents {
+		for _, owner := range extent.Owners {
+			owners = append(owners, owner.Md5)
+		}
+	}
+	delete(f.extentJson.Chunks, chunkId)
+	f.dirty = true
+	return owners, nil
+}
+
+//occupy assigned space, free part of space removed
+//used for wal replay, space used after extent file saved
+//return dropped owner md5s
+func (f *Extent) Occupy(chunkId, offset, size int64) ([]string, error) {
+	//check
+	if chunkId <= 0 || offset < 0 || size <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//cut space from overlapped extents with locker
+	f.Lock()
+	defer f.Unlock()
+	end := offset + size
+	dropped := make([]string, 0)
+	extents := f.extentJson.Chunks[chunkId]
+	newExtents := make([]*json.FreeExtentJson, 0, len(extents) + 1)
+	for _, extent := range extents {
+		extentEnd := extent.Offset + extent.Size
+		if extentEnd <= offset || extent.Offset >= end {
+			newExtents = append(newExtents, extent)
+			continue
+		}
+		head := &json.FreeExtentJson{
+			Offset: extent.Offset,
+			Size: offset - extent.Offset,
+		}
+		tail := &json.FreeExtentJson{
+			Offset: end,
+			Size: extentEnd - end,
+		}
+		for _, owner := range extent.Owners {
+			if owner.Offset + owner.Size <= offset {
+				head.Owners = append(head.Owners, owner)
+			}else if owner.Offset >= end {
+				tail.Owners = append(tail.Owners, owner)
+			}else{
+				//owner data overwritten
+				dropped = append(dropped, owner.Md5)
+			}
+		}
+		if head.Size > 0 {
+			newExtents = append(newExtents, head)
+		}
+		if tail.Size > 0 {
+			newExtents = append(newExtents, tail)
+		}
+	}
+	f.setChunkExtents(chunkId, newExtents)
+	return dropped, nil
+}
+
+//save extent file if changed
+func (f *Extent) Save() error {
+	f.Lock()
+	defer f.Unlock()
+	if !f.dirty {
+		return nil
+	}
+	err := f.save()
+	if err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+//check space is free or not
+func (f *Extent) IsFree(chunkId, offset int64) bool {
+	return f.FreeEnd(chunkId, offset) > 0
+}
+
+//get end offset of free extent contain offset
+//return zero if space not free
+func (f *Extent) FreeEnd(chunkId, offset int64) int64 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.freeEnd(f.extentJson.Chunks[chunkId], offset)
+}
+
+//snapshot free extents of chunk
+//return end offset checker, not changed by later allocate and free
+func (f *Extent) SnapshotFreeEnd(chunkId int64) func(offset int64) int64 {
+	f.RLock()
+	extents := make([]*json.FreeExtentJson, 0, len(f.extentJson.Chunks[chunkId]))
+	for _, extent := range f.extentJson.Chunks[chunkId] {
+		extents = append(extents, &json.FreeExtentJson{
+			Offset: extent.Offset,
+			Size: extent.Size,
+		})
+	}
+	f.RUnlock()
+	return func(offset int64) int64 {
+		return f.freeEnd(extents, offset)
+	}
+}
+
+//get free size of all chunks
+//return map[chunkId]freeSize
+func (f *Extent) GetFreeSize() map[int64]int64 {
+	f.RLock()
+	defer f.RUnlock()
+	result := map[int64]int64{}
+	for chunkId, extents := range f.extentJson.Chunks {
+		for _, extent := range extents {
+			result[chunkId] += extent.Size
+		}
+	}
+	return result
+}
+
+//get free extents of chunk
+func (f *Extent) GetExtents(chunkId int64) []*json.FreeExtentJson {
+	f.RLock()
+	defer f.RUnlock()
+	extents := f.extentJson.Chunks[chunkId]
+	result := make([]*json.FreeExtentJson, len(extents))
+	copy(result, extents)
+	return result
+}
+
+//set config
+//return extent file loaded or not
+func (f *Extent) SetConfig(cfg *conf.Config) (bool, error) {
+	//check
+	if cfg == nil {
+		return false, errors.New("invalid parameter")
+	}
+	f.cfg = cfg
+
+	//format file root path
+	rootPath := fmt.Sprintf("%v/%v", cfg.DataPath, define.SubDirOfFile)
+	err := f.CheckDir(rootPath)
+	if err != nil {
+		return false, err
+	}
+	f.filePath = fmt.Sprintf("%v/%v", rootPath, define.ChunksExtentFile)
+
+	//load extent file
+	data, err := os.ReadFile(f.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	extentJson := json.NewExtentJson()
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&extentJson)
+	if err != nil {
+		return false, err
+	}
+	if extentJson.Chunks == nil {
+		extentJson.Chunks = map[int64][]*json.FreeExtentJson{}
+	}
+	f.Lock()
+	f.extentJson = extentJson
+	f.Unlock()
+	return true, nil
+}
+
+////////////////
+//private func
+////////////////
+
+//set extents of chunk
+func (f *Extent) setChunkExtents(chunkId int64, extents []*json.FreeExtentJson) {
+	f.dirty = true
+	if len(extents) <= 0 {
+		delete(f.extentJson.Chunks, chunkId)
+		return
+	}
+	f.extentJson.Chunks[chunkId] = extents
+}
+
+//get end offset of free extent contain offset without locker
+func (f *Extent) freeEnd(extents []*json.FreeExtentJson, offset int64) int64 {
+	idx := sort.Search(len(extents), func(i int) bool {
+		return extents[i].Offset + extents[i].Size > offset
+	})
+	if idx >= len(extents) || extents[idx].Offset > offset {
+		return 0
+	}
+	return extents[idx].Offset + extents[idx].Size
+}
+
+//free space without locker
+func (f *Extent) free(chunkId, offset, size int64, md5 string) error {
+	extent := &json.FreeExtentJson{
+		Offset: offset,
+		Size: size,
+	}
+	if md5 != "" {
+		extent.Owners = []*json.ExtentOwnerJson{
+			{Md5: md5, Offset: offset, Size: size},
+		}
+	}
+
+	//insert into sorted extents
+	extents := f.extentJson.Chunks[chunkId]
+	idx := sort.Search(len(extents), func(i int) bool {
+		return extents[i].Offset >= offset
+	})
+	if idx > 0 && extents[idx-1].Offset + extents[idx-1].Size > offset {
+		return fmt.Errorf("space %v:%v already free", chunkId, offset)
+	}
+	if idx < len(extents) && offset + size > extents[idx].Offset {
+		return fmt.Errorf("space %v:%v already free", chunkId, offset)
+	}
+
+	//coalesce with next extent
+	if idx < len(extents) && offset + size == extents[idx].Offset {
+		next := extents[idx]
+		extent.Size += next.Size
+		extent.Owners = append(extent.Owners, next.Owners...)
+		extents = append(extents[0:idx], extents[idx+1:]...)
+	}
+
+	//coalesce with prev extent
+	if idx > 0 && extents[idx-1].Offset + extents[idx-1].Size == offset {
+		prev := extents[idx-1]
+		prev.Size += extent.Size
+		prev.Owners = append(prev.Owners, extent.Owners...)
+	}else{
+		extents = append(extents, nil)
+		copy(extents[idx+1:], extents[idx:])
+		extents[idx] = extent
+	}
+	f.setChunkExtents(chunkId, extents)
+	return nil
+}
+
+//save extent file without locker
+//write temp file first, then rename
+func (f *Extent) save() error {
+	//check
+	if f.filePath == "" {
+		return errors.New("extent file not setup")
+	}
+
+	//encode data
+	buff := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buff).Encode(f.extentJson)
+	if err != nil {
+		return err
+	}
+
+	//write and sync temp file
+	tempFile := f.filePath + ".tmp"
+	file, err := os.OpenFile(tempFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, define.FilePerm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(buff.Bytes())
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	//replace extent file
+	return os.Rename(tempFile, f.filePath)
+}
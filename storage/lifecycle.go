@@ -0,0 +1,438 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/tinylib/queue"
+)
+
+/*
+ * file lifecycle face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - rules assigned by config, evaluated by inter ticker
+ * - rule files picked by meta store query, then checked by access time
+ * - delete action use the normal delete opt
+ * - archive action move data into archive chunks, no new data written into them
+ * - old data space of archived files freed after wal checkpoint, once per run
+ * - file access time updated when read, at most once an hour
+ */
+
+//lifecycle action
+type LifecycleAction struct {
+	Rule        string //rule name
+	Action      string //delete or archive
+	ShortUrl    string
+	ChunkFileId int64  //archive chunk id of archived data
+	Err         string //empty means success
+}
+
+//lifecycle report
+type LifecycleReport struct {
+	BeginAt  int64
+	EndAt    int64
+	Matched  int64 //matched files of all rules
+	Deleted  int64
+	Archived int64
+	Failed   int64
+	Actions  []*LifecycleAction //action details, max define.LifecycleReportMaxActions
+}
+
+//check chunk is archive chunk or not
+func (f *Manager) IsArchive(chunkId int64) bool {
+	_, ok := f.archiveMap.Load(chunkId)
+	return ok
+}
+
+//move file data into archive chunk
+//file base location updated, old data space kept in old spaces
+//old spaces should be freed by ReleaseArchived
+//return archive chunk id, moved or not, error
+func (f *Manager) ArchiveFile(
+		md5 string,
+		oldSpaces map[int64][]*json.ExtentOwnerJson,
+	) (int64, bool, error) {
+	//check
+	if md5 == "" || oldSpaces == nil {
+		return 0, false, errors.New("invalid parameter")
+	}
+
+	//opt with locker
+	f.compactLocker.Lock()
+	defer f.compactLocker.Unlock()
+	return f.archiveFile(md5, oldSpaces)
+}
+
+//free old data space of archived files
+//wal checkpoint first, old wal entries may point to old data
+//space not freed if checkpoint failed
+func (f *Manager) ReleaseArchived(oldSpaces map[int64][]*json.ExtentOwnerJson) error {
+	//check
+	if len(oldSpaces) <= 0 {
+		return nil
+	}
+
+	//opt with locker
+	f.compactLocker.Lock()
+	defer f.compactLocker.Unlock()
+	err := f.checkpointWal()
+	if err != nil {
+		return err
+	}
+	return f.chunk.FreeSpaces(oldSpaces)
+}
+
+//set lifecycle runner
+//called in manager ticker
+func (f *Manager) SetLifecycleRunner(cb func() (*LifecycleReport, error)) {
+	f.lifecycleRunner = cb
+}
+
+//get latest lifecycle report
+func (f *Manager) GetLifecycleReport() *LifecycleReport {
+	f.RLock()
+	defer f.RUnlock()
+	return f.lifecycleReport
+}
+
+//get latest lifecycle report
+func (f *Storage) GetLifecycleReport() *LifecycleReport {
+	return f.manager.GetLifecycleReport()
+}
+
+//run lifecycle rules of config
+//return report, error
+func (f *Storage) RunLifecycle() (*LifecycleReport, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	if !atomic.CompareAndSwapInt32(&f.lifecycleRunning, 0, 1) {
+		return nil, errors.New("lifecycle is running")
+	}
+	defer atomic.StoreInt32(&f.lifecycleRunning, 0)
+
+	//run rules one by one
+	report := &LifecycleReport{
+		BeginAt: time.Now().Unix(),
+		Actions: []*LifecycleAction{},
+	}
+	acted := map[string]bool{}
+	oldSpaces := map[int64][]*json.ExtentOwnerJson{}
+	for _, rule := range f.cfg.LifecycleRules {
+		filesInfo, err := f.getLifecycleFiles(rule)
+		if err != nil {
+			f.manager.ReleaseArchived(oldSpaces)
+			return report, err
+		}
+		for _, fileInfo := range filesInfo {
+			if acted[fileInfo.ShortUrl] {
+				continue
+			}
+			acted[fileInfo.ShortUrl] = true
+			f.runLifecycleAction(rule, fileInfo, oldSpaces, report)
+		}
+	}
+
+	//free old data space of archived files
+	err := f.manager.ReleaseArchived(oldSpaces)
+	report.EndAt = time.Now().Unix()
+	f.manager.setLifecycleReport(report)
+	return report, err
+}
+
+////////////////
+//private func
+////////////////
+
+//move file data into archive chunk
+//parts of multipart file archived one by one
+//data already in archive chunk not moved
+//should be called with compact locker
+func (f *Manager) archiveFile(
+		md5 string,
+		oldSpaces map[int64][]*json.ExtentOwnerJson,
+	) (int64, bool, error) {
+	var (
+		chunkId int64
+		moved, partMoved bool
+		err error
+	)
+	//get file base
+	fileBase, _ := f.chunk.getFileBase(md5)
+	if fileBase == nil || fileBase.Removed {
+		return 0, false, define.ErrFileNotFound
+	}
+	if len(fileBase.Parts) > 0 {
+		for _, partMd5 := range fileBase.Parts {
+			chunkId, partMoved, err = f.archiveFile(partMd5, oldSpaces)
+			moved = moved || partMoved
+			if err != nil {
+				return chunkId, moved, err
+			}
+		}
+		return chunkId, moved, nil
+	}
+	if f.IsArchive(fileBase.ChunkFileId) {
+		return fileBase.ChunkFileId, false, nil
+	}
+	srcChunk, err := f.GetChunkById(fileBase.ChunkFileId)
+	if err != nil {
+		return 0, false, err
+	}
+
+	//copy record data
+	dstChunk, err := f.getArchiveChunk()
+	if err != nil {
+		return 0, false, err
+	}
+	resp := dstChunk.CopyRecord(srcChunk, fileBase.Offset)
+	if resp.Err != nil {
+		return 0, false, resp.Err
+	}
+
+	//update file base location
+	oldSpace := &json.ExtentOwnerJson{
+		Offset: fileBase.Offset,
+		Size: f.chunk.getSpaceSize(fileBase.Blocks),
+	}
+	oldChunkId := fileBase.ChunkFileId
+	fileBase.ChunkFileId = dstChunk.GetFileId()
+	fileBase.Offset = resp.NewOffSet
+	fileBase.Blocks = resp.BlockSize
+	err = f.chunk.saveFileBase(fileBase)
+	if err != nil {
+		return 0, false, err
+	}
+
+	//old data space freed after wal checkpoint
+	oldSpaces[oldChunkId] = append(oldSpaces[oldChunkId], oldSpace)
+	return fileBase.ChunkFileId, true, nil
+}
+
+//check file data all in archive chunks or not
+//file base location checked, file info location may be stale
+func (f *Manager) isArchivedFile(md5 string) bool {
+	fileBase, _ := f.chunk.getFileBase(md5)
+	if fileBase == nil || fileBase.Removed {
+		return false
+	}
+	if len(fileBase.Parts) > 0 {
+		for _, partMd5 := range fileBase.Parts {
+			if !f.isArchivedFile(partMd5) {
+				return false
+			}
+		}
+		return true
+	}
+	return f.IsArchive(fileBase.ChunkFileId)
+}
+
+//check lifecycle rules
+func (f *Storage) checkLifecycleRules(rules []*conf.LifecycleRule) error {
+	for _, rule := range rules {
+		if rule == nil {
+			return errors.New("invalid lifecycle rule")
+		}
+		if rule.Action != define.LifecycleActionOfDelete &&
+			rule.Action != define.LifecycleActionOfArchive {
+			return fmt.Errorf("invalid lifecycle rule %v action %v", rule.Name, rule.Action)
+		}
+		if rule.AgeSeconds <= 0 && rule.IdleSeconds <= 0 {
+			return fmt.Errorf("lifecycle rule %v without age or idle condition", rule.Name)
+		}
+	}
+	return nil
+}
+
+//get matched files of rule
+func (f *Storage) getLifecycleFiles(
+		rule *conf.LifecycleRule,
+	) ([]*json.FileInfoJson, error) {
+	now := time.Now().Unix()
+
+	//pick files by meta store query
+	query := json.NewFileQueryJson()
+	query.ContentType = rule.ContentType
+	query.NamePrefix = rule.NamePrefix
+	if rule.AgeSeconds > 0 {
+		query.CreateAtMax = now - rule.AgeSeconds
+	}
+	query.OrderBy = define.FileQueryOrderByCreateAt
+	query.Asc = true
+	query.PageSize = define.FileQueryScanSize
+	result := make([]*json.FileInfoJson, 0)
+	for page := define.DefaultPage; ; page++ {
+		query.Page = page
+		_, filesInfo, err := f.GetFilesInfoByQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, fileInfo := range filesInfo {
+			//check access time
+			accessAt := fileInfo.AccessAt
+			if accessAt <= 0 {
+				accessAt = fileInfo.CreateAt
+			}
+			if rule.IdleSeconds > 0 && accessAt > now - rule.IdleSeconds {
+				continue
+			}
+			if rule.Action == define.LifecycleActionOfArchive &&
+				f.manager.isArchivedFile(fileInfo.Md5) {
+				continue
+			}
+			result = append(result, fileInfo)
+		}
+		if len(filesInfo) < query.PageSize {
+			break
+		}
+	}
+	return result, nil
+}
+
+//run action of rule for one file
+func (f *Storage) runLifecycleAction(
+		rule *conf.LifecycleRule,
+		fileInfo *json.FileInfoJson,
+		oldSpaces map[int64][]*json.ExtentOwnerJson,
+		report *LifecycleReport,
+	) {
+	var (
+		moved bool
+		err error
+	)
+	action := &LifecycleAction{
+		Rule: rule.Name,
+		Action: rule.Action,
+		ShortUrl: fileInfo.ShortUrl,
+	}
+	report.Matched++
+	switch rule.Action {
+	case define.LifecycleActionOfDelete:
+		err = f.DeleteData(fileInfo.ShortUrl)
+		if err == nil {
+			report.Deleted++
+		}
+	case define.LifecycleActionOfArchive:
+		action.ChunkFileId, moved, err = f.manager.ArchiveFile(fileInfo.Md5, oldSpaces)
+		if err == nil && moved {
+			report.Archived++
+		}
+	}
+	if err != nil {
+		action.Err = err.Error()
+		report.Failed++
+	}
+	if len(report.Actions) < define.LifecycleReportMaxActions {
+		report.Actions = append(report.Actions, action)
+	}
+}
+
+//update file access time, at most once an hour
+//should be called without compact locker
+func (f *Storage) touchFile(fileInfo *json.FileInfoJson) {
+	//check
+	if fileInfo == nil || len(f.cfg.LifecycleRules) <= 0 {
+		return
+	}
+	now := time.Now().Unix()
+	if fileInfo.AccessAt > now - define.FileAccessUpdateSeconds {
+		return
+	}
+	if _, loaded := f.manager.accessingMap.LoadOrStore(fileInfo.ShortUrl, true); loaded {
+		return
+	}
+	defer f.manager.accessingMap.Delete(fileInfo.ShortUrl)
+
+	//update with locker, file info may be changed by other opt
+	f.manager.compactLocker.Lock()
+	defer f.manager.compactLocker.Unlock()
+	newInfo, _ := f.getFileInfo(fileInfo.ShortUrl)
+	if newInfo == nil || newInfo.AccessAt > now - define.FileAccessUpdateSeconds {
+		return
+	}
+	newInfo.AccessAt = now
+	f.saveFileInfo(newInfo)
+}
+
+//set latest lifecycle report
+func (f *Manager) setLifecycleReport(report *LifecycleReport) {
+	f.Lock()
+	defer f.Unlock()
+	f.lifecycleReport = report
+}
+
+//add chunk into archive chunk set
+func (f *Manager) addArchive(chunkId int64) error {
+	err := f.meta.AddArchive(chunkId)
+	if err != nil {
+		return err
+	}
+	f.archiveMap.Store(chunkId, true)
+	return nil
+}
+
+//get available archive chunk or create new
+//should be called with compact locker
+func (f *Manager) getArchiveChunk() (*chunk.Chunk, error) {
+	var (
+		target *chunk.Chunk
+	)
+	sf := func(k, v interface{}) bool {
+		chunkId, _ := k.(int64)
+		chunkObj, _ := f.GetChunkById(chunkId)
+		if chunkObj != nil && chunkObj.IsAvailable() && !f.IsCompacting(chunkId) {
+			target = chunkObj
+			return false
+		}
+		return true
+	}
+	f.archiveMap.Range(sf)
+	if target != nil {
+		return target, nil
+	}
+
+	//create new archive chunk
+	newChunkId := f.InitNewChunk()
+	atomic.AddInt32(&f.chunks, 1)
+	err := f.addArchive(newChunkId)
+	if err != nil {
+		return nil, err
+	}
+	return f.GetChunkById(newChunkId)
+}
+
+//cb for lifecycle
+func (f *Manager) cbForLifecycle(inputs ...interface{}) error {
+	if f.lifecycleRunner == nil {
+		return nil
+	}
+	report, err := f.lifecycleRunner()
+	if err != nil {
+		log.Printf("manager.cbForLifecycle failed, err:%v\n", err.Error())
+		return err
+	}
+	if report.Matched > 0 {
+		log.Printf("manager.cbForLifecycle, matched:%v, deleted:%v, archived:%v, failed:%v\n",
+			report.Matched, report.Deleted, report.Archived, report.Failed)
+	}
+	return nil
+}
+
+//start lifecycle ticker
+func (f *Manager) startLifecycleTicker() {
+	seconds := f.cfg.LifecycleSeconds
+	if seconds <= 0 {
+		seconds = define.DefaultLifecycleSeconds
+	}
+	f.lifecycleTicker = queue.NewTicker(float64(seconds))
+	f.lifecycleTicker.SetCheckerCallback(f.cbForLifecycle)
+}
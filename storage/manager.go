@@ -8,8 +8,9 @@ import (
 
 	"github.com/andyzhou/pond/chunk"
 	"github.com/andyzhou/pond/conf"
-	"github.com/andyzhou/pond/data"
 	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/tinylib/queue"
 )
 
 /*
@@ -21,17 +22,37 @@ import (
 
 //face info
 type Manager struct {
-	wg           *sync.WaitGroup      //reference
-	cfg          *conf.Config         //reference
-	data         *data.InterRedisData //reference
+	wg           *sync.WaitGroup //reference
+	cfg          *conf.Config    //reference
 	chunk        *Chunk
 	meta         *Meta
+	wal          *Wal
+	store        face.IMetaStore //reference
 	chunkMap     sync.Map //chunkId -> *Chunk, active chunk file map
 	chunkMaxSize int64
 	chunks       int32 //atomic count
-	useRedis     bool
 	initDone     bool
 	lazyMode     bool
+
+	//compact
+	compactTicker  *queue.Ticker
+	compactingMap  sync.Map     //chunkId -> bool, compacting chunk map
+	compactLocker  sync.RWMutex //write lock for compact, read lock for data opt
+	compactRunning int32        //atomic switcher
+	pinMap         map[int64]int          //chunkId -> pinned count of opened reader
+	removingMap    map[int64]*chunk.Chunk //chunkId -> compacted chunk, removed when unpinned
+	pinLocker      sync.Mutex
+
+	//expire
+	expireTicker  *queue.Ticker
+	expireSweeper func() (int, error)
+
+	//lifecycle
+	archiveMap      sync.Map //chunkId -> bool, archive chunk map
+	lifecycleTicker *queue.Ticker
+	lifecycleRunner func() (*LifecycleReport, error)
+	lifecycleReport *LifecycleReport
+	accessingMap    sync.Map //shortUrl -> bool, access time updating map
 	sync.RWMutex
 }
 
@@ -41,8 +62,11 @@ func NewManager(wg *sync.WaitGroup) *Manager {
 		wg: wg,
 		chunk: NewChunk(wg),
 		meta: NewMeta(wg),
+		wal: NewWal(),
 		chunkMap: sync.Map{},
 		chunkMaxSize: define.DefaultChunkMaxSize,
+		pinMap: map[int64]int{},
+		removingMap: map[int64]*chunk.Chunk{},
 	}
 	this.interInit()
 	return this
@@ -50,9 +74,26 @@ func NewManager(wg *sync.WaitGroup) *Manager {
 
 //quit
 func (f *Manager) Quit() {
+	//stop compact ticker
+	if f.compactTicker != nil {
+		f.compactTicker.Quit()
+	}
+
+	//stop expire ticker
+	if f.expireTicker != nil {
+		f.expireTicker.Quit()
+	}
+
+	//stop lifecycle ticker
+	if f.lifecycleTicker != nil {
+		f.lifecycleTicker.Quit()
+	}
+
 	//inter obj quit
 	f.meta.Quit()
+	f.CheckpointWal()
 	f.chunk.Quit()
+	f.wal.Quit()
 
 	//clean chunk map
 	sf := func(k, v interface{}) bool {
@@ -64,6 +105,9 @@ func (f *Manager) Quit() {
 	}
 	f.chunkMap.Range(sf)
 
+	//remove compacted chunk files of opened reader
+	f.removeChunkFiles()
+
 	//wait group done
 	if f.wg != nil {
 		f.wg.Done()
@@ -130,8 +174,9 @@ func (f *Manager) GetActiveChunk() (*chunk.Chunk, error) {
 	if f.chunks > 0 {
 		//get active chunk
 		sf := func(k, v interface{}) bool {
+			chunkId, _ := k.(int64)
 			chunkObj, _ := v.(*chunk.Chunk)
-			if chunkObj != nil && chunkObj.IsAvailable() {
+			if chunkObj != nil && chunkObj.IsAvailable() && !f.IsWriteSkipped(chunkId) {
 				//found it
 				target = chunkObj
 				return false
@@ -158,6 +203,31 @@ func (f *Manager) GetActiveChunk() (*chunk.Chunk, error) {
 	return target, nil
 }
 
+//load chunk obj by id
+//chunk not in meta will be added, used for recover chunk files
+func (f *Manager) LoadChunk(chunkId int64) (*chunk.Chunk, error) {
+	//check
+	if chunkId <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	chunkObj, _ := f.GetChunkById(chunkId)
+	if chunkObj != nil {
+		return chunkObj, nil
+	}
+
+	//add into meta
+	err := f.meta.AddChunk(chunkId)
+	if err != nil {
+		return nil, err
+	}
+
+	//init chunk face
+	chunkObj = chunk.NewChunk(chunkId, f.cfg)
+	f.chunkMap.Store(chunkId, chunkObj)
+	atomic.AddInt32(&f.chunks, 1)
+	return chunkObj, nil
+}
+
 //init new chunk info
 //return newChunkId
 func (f *Manager) InitNewChunk() int64 {
@@ -179,10 +249,7 @@ func (f *Manager) InitNewChunk() int64 {
 }
 
 //set config
-func (f *Manager) SetConfig(cfg *conf.Config, userRedis ...bool) error {
-	var (
-		needUserRedis bool
-	)
+func (f *Manager) SetConfig(cfg *conf.Config) error {
 	//check
 	if cfg == nil || cfg.DataPath == "" {
 		return errors.New("invalid parameter")
@@ -190,15 +257,10 @@ func (f *Manager) SetConfig(cfg *conf.Config, userRedis ...bool) error {
 	if f.initDone {
 		return nil
 	}
-	if userRedis != nil && len(userRedis) > 0 {
-		needUserRedis = userRedis[0]
-	}
 
 	//sync env
 	f.cfg = cfg
 	f.chunkMaxSize = cfg.ChunkSizeMax
-	f.useRedis = needUserRedis
-	f.chunk.SetUseRedis(needUserRedis)
 
 	//init meta
 	err := f.meta.SetConfig(cfg)
@@ -209,6 +271,12 @@ func (f *Manager) SetConfig(cfg *conf.Config, userRedis ...bool) error {
 	//set chunk config
 	f.chunk.SetConfig(cfg)
 
+	//open wal file
+	err = f.wal.SetConfig(cfg)
+	if err != nil {
+		return err
+	}
+
 	//defer
 	defer func() {
 		f.initDone = true
@@ -229,6 +297,11 @@ func (f *Manager) SetConfig(cfg *conf.Config, userRedis ...bool) error {
 		}
 		//update chunk count
 		atomic.StoreInt32(&f.chunks, chunks)
+
+		//load archive chunks
+		for _, chunkId := range metaObj.Archives {
+			f.archiveMap.Store(chunkId, true)
+		}
 	}else{
 		//pre-create batch empty chunk files
 		for i := 1; i <= cfg.MinChunkFiles; i++ {
@@ -249,19 +322,73 @@ func (f *Manager) SetConfig(cfg *conf.Config, userRedis ...bool) error {
 		//force save meta data
 		f.meta.SaveMeta(true)
 	}
+
+	//start auto compact ticker
+	if cfg.CompactRatio > 0 {
+		f.startCompactTicker()
+	}
+
+	//start expire sweep ticker
+	f.startExpireTicker()
+
+	//start lifecycle ticker
+	if len(cfg.LifecycleRules) > 0 {
+		f.startLifecycleTicker()
+	}
 	return err
 }
 
-//set data obj
-func (f *Manager) SetData(data *data.InterRedisData) {
-	f.data = data
-	f.chunk.SetData(data)
+//get wal obj
+func (f *Manager) GetWal() *Wal {
+	return f.wal
+}
+
+//save snapshots and checkpoint wal
+//wait for running data operate by compact locker
+func (f *Manager) CheckpointWal() error {
+	if f.wal.GetSize() <= 0 {
+		return nil
+	}
+	f.compactLocker.Lock()
+	defer f.compactLocker.Unlock()
+	return f.checkpointWal()
+}
+
+//set meta store
+func (f *Manager) SetStore(store face.IMetaStore) {
+	f.store = store
+	f.chunk.SetStore(store)
 }
 
 ////////////////
 //private func
 ////////////////
 
+//save snapshots and checkpoint wal without locker
+//queued meta write flushed first, skip checkpoint if failed
+//should be called with compact locker
+func (f *Manager) checkpointWal() error {
+	if flushStore, ok := f.store.(face.IMetaFlushStore); ok {
+		err := flushStore.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	err := f.meta.SaveMeta(true)
+	if err != nil {
+		return err
+	}
+	err = f.chunk.SaveRemoved()
+	if err != nil {
+		return err
+	}
+	err = f.chunk.SaveExtent()
+	if err != nil {
+		return err
+	}
+	return f.wal.Checkpoint()
+}
+
 //check un-active chunk files
 func (f *Manager) checkUnActiveChunkFiles() error {
 	//check
@@ -304,6 +431,14 @@ func (f *Manager) interInit() {
 	//start chunk files checker
 	//f.startChunkFilesChecker()
 
+	//checkpoint wal when meta saved
+	//only if wal size or age exceed threshold
+	f.meta.SetSavedCallback(func() {
+		if f.wal.IsCheckpointDue() {
+			f.CheckpointWal()
+		}
+	})
+
 	//wait group add count
 	if f.wg != nil {
 		f.wg.Add(1)
@@ -34,6 +34,7 @@ type Meta struct {
 	metaFile    string
 	metaJson    *json.MetaJson //running data
 	metaUpdated bool
+	cbForSaved  func() //called after auto saved, used for wal checkpoint
 	objLocker   sync.RWMutex
 	util.Util
 	utils.Utils
@@ -73,6 +74,11 @@ func (f *Meta) Quit() {
 	}
 }
 
+//set callback for meta auto saved
+func (f *Meta) SetSavedCallback(cb func()) {
+	f.cbForSaved = cb
+}
+
 //gen new data short url
 func (f *Meta) GenNewShortUrl() (string, error) {
 	newDataId := f.genNewFileDataId()
@@ -108,6 +114,85 @@ func (f *Meta) CreateNewChunk() *json.ChunkFileJson {
 	return newChunkFileObj
 }
 
+//add exists chunk file data
+//used for recover chunk files not in meta
+func (f *Meta) AddChunk(chunkId int64) error {
+	//check
+	if chunkId <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//sync into meta obj with locker
+	f.objLocker.Lock()
+	for _, v := range f.metaJson.Chunks {
+		if v == chunkId {
+			f.objLocker.Unlock()
+			return nil
+		}
+	}
+	f.metaJson.Chunks = append(f.metaJson.Chunks, chunkId)
+	if atomic.LoadInt64(&f.metaJson.ChunkId) < chunkId {
+		atomic.StoreInt64(&f.metaJson.ChunkId, chunkId)
+	}
+	f.objLocker.Unlock()
+
+	//save meta file
+	return f.SaveMeta(true)
+}
+
+//add chunk into archive chunk set
+//used for lifecycle archive
+func (f *Meta) AddArchive(chunkId int64) error {
+	//check
+	if chunkId <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//sync into meta obj with locker
+	f.objLocker.Lock()
+	for _, v := range f.metaJson.Archives {
+		if v == chunkId {
+			f.objLocker.Unlock()
+			return nil
+		}
+	}
+	f.metaJson.Archives = append(f.metaJson.Archives, chunkId)
+	f.objLocker.Unlock()
+
+	//save meta file
+	return f.SaveMeta(true)
+}
+
+//remove chunk file data
+//used for compacted chunk
+func (f *Meta) RemoveChunk(chunkId int64) error {
+	//check
+	if chunkId <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//remove from meta obj with locker
+	f.objLocker.Lock()
+	chunks := make([]int64, 0)
+	for _, v := range f.metaJson.Chunks {
+		if v != chunkId {
+			chunks = append(chunks, v)
+		}
+	}
+	f.metaJson.Chunks = chunks
+	archives := make([]int64, 0)
+	for _, v := range f.metaJson.Archives {
+		if v != chunkId {
+			archives = append(archives, v)
+		}
+	}
+	f.metaJson.Archives = archives
+	f.objLocker.Unlock()
+
+	//save meta file
+	return f.SaveMeta(true)
+}
+
 //save meta data
 func (f *Meta) SaveMeta(
 	isForces ...bool) error {
@@ -203,8 +288,11 @@ func (f *Meta) cbForAutoSaveMeta(inputs ...interface{}) error {
 		//has updated, do nothing
 		return errors.New("meta had updated")
 	}
-	f.saveMetaData()
+	err := f.saveMetaData()
 	f.metaUpdated = true
+	if err == nil && f.cbForSaved != nil {
+		f.cbForSaved()
+	}
 	return nil
 }
 
@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * meta data migrate face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - stream file info and base page by page from one meta store to another
+ * - file base of each file info copied with info, removed file base copied at last
+ * - file base copied as it is, appoints and removed status kept
+ * - part file base copied with multipart file base or upload session
+ * - upload session copied if both store support
+ * - file versions copied with file base of versions if both store support
+ * - source store only read, can be the store of running pond
+ * - records changed during migrate may be missed, verify and run again
+ */
+
+//migrate result
+type MigrateResult struct {
+	Infos      int64 //copied file info
+	Bases      int64 //copied file base of file info
+	Removed    int64 //copied removed file base
+	Uploads    int64 //copied upload session
+	Versions   int64 //copied file versions
+	SrcInfos   int64 //file info total of source, zero means unknown
+	SrcRemoved int64 //removed file base total of source, zero means unknown
+	Mismatched int64 //mismatched records when verify
+}
+
+//migrate meta data into dest store from running storage
+//storage meta store only read
+func (f *Storage) MigrateMeta(
+		dst face.IMetaStore,
+		opts ...*conf.MigrateOption,
+	) (*MigrateResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	if dst == nil || dst == f.store {
+		return nil, errors.New("invalid dest meta store")
+	}
+	return MigrateMeta(f.store, dst, opts...)
+}
+
+//migrate meta data into named store from running storage
+//dest store created from config, closed when done
+func (f *Storage) MigrateMetaTo(
+		to string,
+		redisCfg *conf.RedisConfig,
+		opts ...*conf.MigrateOption,
+	) (*MigrateResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	if to == "" || to == f.storeName {
+		return nil, errors.New("invalid dest meta store")
+	}
+	dst, err := CreateMetaStore(to, f.cfg, redisCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer dst.Quit()
+	return f.MigrateMeta(dst, opts...)
+}
+
+//migrate all meta data between stores
+func MigrateMeta(
+		src, dst face.IMetaStore,
+		opts ...*conf.MigrateOption,
+	) (*MigrateResult, error) {
+	var (
+		opt *conf.MigrateOption
+	)
+	//check
+	if src == nil || dst == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	if opts != nil && len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt == nil {
+		opt = &conf.MigrateOption{}
+	}
+	pageSize := opt.PageSize
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSizeMax
+	}
+	result := &MigrateResult{}
+	copiedInfos := map[string]bool{}
+	copiedBases := map[string]*json.FileBaseJson{}
+
+	//copy file base and its parts
+	var copyBase func(md5 string) error
+	copyBase = func(md5 string) error {
+		if copiedBases[md5] != nil {
+			return nil
+		}
+		fileBase, err := src.GetBase(md5)
+		if err != nil || fileBase == nil {
+			return err
+		}
+		err = dst.PutBase(fileBase)
+		if err != nil {
+			return err
+		}
+		copiedBases[md5] = fileBase
+		result.Bases++
+		for _, partMd5 := range fileBase.Parts {
+			err = copyBase(partMd5)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	//copy file info and base page by page
+	for page := 1; ; page++ {
+		total, filesInfo, err := src.ListInfoByTime(page, pageSize)
+		if err != nil {
+			return result, err
+		}
+		if page == 1 {
+			result.SrcInfos = total
+		}
+		for _, fileInfo := range filesInfo {
+			if copiedInfos[fileInfo.ShortUrl] {
+				//page shifted by new data
+				continue
+			}
+			err = copyBase(fileInfo.Md5)
+			if err != nil {
+				return result, err
+			}
+			err = dst.PutInfo(fileInfo)
+			if err != nil {
+				return result, err
+			}
+			copiedInfos[fileInfo.ShortUrl] = true
+			result.Infos++
+		}
+		if len(filesInfo) < pageSize {
+			break
+		}
+	}
+
+	//copy removed file base page by page
+	for page := 1; ; page++ {
+		total, fileBases, err := src.ListRemovedBase(page, pageSize)
+		if err != nil {
+			return result, err
+		}
+		if page == 1 {
+			result.SrcRemoved = total
+		}
+		for _, fileBase := range fileBases {
+			if copiedBases[fileBase.Md5] != nil {
+				continue
+			}
+			err = dst.PutBase(fileBase)
+			if err != nil {
+				return result, err
+			}
+			copiedBases[fileBase.Md5] = fileBase
+			result.Removed++
+		}
+		if len(fileBases) < pageSize {
+			break
+		}
+	}
+
+	//copy upload session with parts page by page
+	srcUpload, isSrcOk := src.(face.IMetaUploadStore)
+	dstUpload, isDstOk := dst.(face.IMetaUploadStore)
+	for page := 1; isSrcOk && isDstOk; page++ {
+		_, uploads, err := srcUpload.ListUploads(page, pageSize)
+		if err != nil {
+			return result, err
+		}
+		for _, upload := range uploads {
+			for _, part := range upload.Parts {
+				err = copyBase(part.Md5)
+				if err != nil {
+					return result, err
+				}
+			}
+			err = dstUpload.PutUpload(upload)
+			if err != nil {
+				return result, err
+			}
+			result.Uploads++
+		}
+		if len(uploads) < pageSize {
+			break
+		}
+	}
+
+	//copy file versions page by page
+	srcVersion, isSrcOk := src.(face.IMetaVersionStore)
+	dstVersion, isDstOk := dst.(face.IMetaVersionStore)
+	for page := 1; isSrcOk && isDstOk; page++ {
+		_, versionsList, err := srcVersion.ListVersions(page, pageSize)
+		if err != nil {
+			return result, err
+		}
+		for _, versions := range versionsList {
+			for _, version := range versions.Versions {
+				if version.Info == nil {
+					continue
+				}
+				err = copyBase(version.Info.Md5)
+				if err != nil {
+					return result, err
+				}
+			}
+			err = dstVersion.PutVersions(versions)
+			if err != nil {
+				return result, err
+			}
+			result.Versions++
+		}
+		if len(versionsList) < pageSize {
+			break
+		}
+	}
+	if !opt.Verify {
+		return result, nil
+	}
+
+	//verify migrated records
+	for shortUrl := range copiedInfos {
+		isExists, err := dst.IsInfoExists(shortUrl)
+		if err != nil {
+			return result, err
+		}
+		if !isExists {
+			result.Mismatched++
+		}
+	}
+	for md5, srcBase := range copiedBases {
+		dstBase, err := dst.GetBase(md5)
+		if err != nil {
+			return result, err
+		}
+		if dstBase == nil || dstBase.Appoints != srcBase.Appoints ||
+			dstBase.Removed != srcBase.Removed ||
+			dstBase.ChunkFileId != srcBase.ChunkFileId ||
+			dstBase.Offset != srcBase.Offset {
+			result.Mismatched++
+		}
+	}
+	if result.Mismatched > 0 {
+		return result, fmt.Errorf("%v records mismatched", result.Mismatched)
+	}
+	if result.SrcInfos > 0 && result.SrcInfos != result.Infos {
+		return result, fmt.Errorf("file info count mismatched, source:%v, copied:%v",
+			result.SrcInfos, result.Infos)
+	}
+	if result.SrcRemoved > 0 && result.SrcRemoved != result.Removed {
+		return result, fmt.Errorf("removed file base count mismatched, source:%v, copied:%v",
+			result.SrcRemoved, result.Removed)
+	}
+	return result, nil
+}
+
+//migrate meta data between named stores
+//stores created from config, closed when done
+func MigrateMetaByName(
+		from, to string,
+		cfg *conf.Config,
+		redisCfg *conf.RedisConfig,
+		opts ...*conf.MigrateOption,
+	) (*MigrateResult, error) {
+	//check
+	if from == "" || to == "" || from == to || cfg == nil {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//create source and dest store
+	src, err := CreateMetaStore(from, cfg, redisCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Quit()
+	dst, err := CreateMetaStore(to, cfg, redisCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer dst.Quit()
+	return MigrateMeta(src, dst, opts...)
+}
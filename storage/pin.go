@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"io"
+	"sync"
+
+	"github.com/andyzhou/pond/chunk"
+)
+
+/*
+ * chunk pin face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - chunk pinned by opened data reader, unpinned when reader closed
+ * - pinned chunk file not removed by compact, removed when last reader closed
+ * - chunk should be pinned with compact read locker
+ * - removing chunk files removed when manager quit
+ */
+
+//pinned data reader
+//chunks unpinned when reader closed
+type pinnedReader struct {
+	io.ReadSeekCloser
+	unpin func()
+}
+
+//close reader and unpin chunks
+func (r *pinnedReader) Close() error {
+	err := r.ReadSeekCloser.Close()
+	r.unpin()
+	return err
+}
+
+////////////////
+//private func
+////////////////
+
+//pin chunks, should be called with compact read locker
+//return unpin func, only run once
+func (f *Manager) pinChunks(chunkIds ...int64) func() {
+	f.pinLocker.Lock()
+	defer f.pinLocker.Unlock()
+	for _, chunkId := range chunkIds {
+		f.pinMap[chunkId]++
+	}
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			f.unpinChunks(chunkIds...)
+		})
+	}
+}
+
+//wrap reader with pinned chunks
+func (f *Manager) wrapPinnedReader(
+		reader io.ReadSeekCloser,
+		chunkIds ...int64,
+	) io.ReadSeekCloser {
+	return &pinnedReader{
+		ReadSeekCloser: reader,
+		unpin: f.pinChunks(chunkIds...),
+	}
+}
+
+//unpin chunks
+//removing chunk file removed when not pinned
+func (f *Manager) unpinChunks(chunkIds ...int64) {
+	f.pinLocker.Lock()
+	defer f.pinLocker.Unlock()
+	for _, chunkId := range chunkIds {
+		f.pinMap[chunkId]--
+		if f.pinMap[chunkId] > 0 {
+			continue
+		}
+		delete(f.pinMap, chunkId)
+		if chunkObj, ok := f.removingMap[chunkId]; ok {
+			delete(f.removingMap, chunkId)
+			chunkObj.Remove()
+		}
+	}
+}
+
+//remove compacted chunk file
+//pinned chunk file removed when unpinned
+func (f *Manager) removeChunkFile(chunkObj *chunk.Chunk) error {
+	f.pinLocker.Lock()
+	defer f.pinLocker.Unlock()
+	chunkId := chunkObj.GetFileId()
+	if f.pinMap[chunkId] > 0 {
+		f.removingMap[chunkId] = chunkObj
+		return nil
+	}
+	return chunkObj.Remove()
+}
+
+//remove all removing chunk files
+//used for manager quit, opened reader failed
+func (f *Manager) removeChunkFiles() {
+	f.pinLocker.Lock()
+	defer f.pinLocker.Unlock()
+	for chunkId, chunkObj := range f.removingMap {
+		delete(f.removingMap, chunkId)
+		chunkObj.Remove()
+	}
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * meta data recover face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - walk all chunk files header by header
+ * - known free space skipped, record with file base is live
+ * - record without file base is orphan, rebuild file base from header
+ * - orphan saved with new short url if repopulate
+ */
+
+//chunk recover result
+type ChunkRecoverResult struct {
+	ChunkId   int64
+	FilePath  string
+	Walk      *chunk.WalkResult
+	Live      int64                 //records of file base
+	Stale     int64                 //records of file base point to other place
+	Orphans   []*json.FileBaseJson  //rebuilt file base of orphan records
+	Recovered map[string]string     //md5 -> new short url, only for repopulate
+}
+
+//recover result
+type RecoverResult struct {
+	Chunks []*ChunkRecoverResult
+}
+
+//recover meta data from chunk files
+//chunk size and meta fixed if repopulate
+func (f *Storage) Recover(opts ...*conf.RecoverOption) (*RecoverResult, error) {
+	var (
+		opt *conf.RecoverOption
+	)
+	//check
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	if opts != nil && len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt == nil {
+		opt = &conf.RecoverOption{}
+	}
+
+	//find all chunk data files
+	filePattern := fmt.Sprintf("%v/%v/%v", f.cfg.DataPath, define.SubDirOfFile,
+		fmt.Sprintf(define.ChunkDataFilePara, "*"))
+	filePaths, err := filepath.Glob(filePattern)
+	if err != nil {
+		return nil, err
+	}
+
+	//no compact during recover
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
+	//recover chunk one by one
+	result := &RecoverResult{
+		Chunks: make([]*ChunkRecoverResult, 0),
+	}
+	for _, filePath := range filePaths {
+		chunkResult, subErr := f.recoverChunkFile(filePath, opt)
+		if chunkResult != nil {
+			result.Chunks = append(result.Chunks, chunkResult)
+		}
+		if subErr != nil {
+			return result, subErr
+		}
+	}
+	sort.Slice(result.Chunks, func(i, j int) bool {
+		return result.Chunks[i].ChunkId < result.Chunks[j].ChunkId
+	})
+	return result, nil
+}
+
+///////////////
+//private func
+///////////////
+
+//recover one chunk data file
+func (f *Storage) recoverChunkFile(
+		filePath string,
+		opt *conf.RecoverOption,
+	) (*ChunkRecoverResult, error) {
+	var (
+		chunkId int64
+	)
+	//get chunk id from file name
+	_, err := fmt.Sscanf(filepath.Base(filePath), define.ChunkDataFilePara, &chunkId)
+	if err != nil || chunkId <= 0 {
+		return nil, nil
+	}
+	result := &ChunkRecoverResult{
+		ChunkId: chunkId,
+		FilePath: filePath,
+		Orphans: make([]*json.FileBaseJson, 0),
+		Recovered: map[string]string{},
+	}
+
+	//walk all record headers
+	file, err := os.Open(filePath)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	runningChunk := f.manager.GetRunningChunk()
+	orphanMd5s := map[string]bool{}
+	cb := func(offset int64, msg face.IMessage) error {
+		fileBase, _ := f.getFileBase(msg.GetMd5())
+		if fileBase == nil && orphanMd5s[msg.GetMd5()] {
+			//same orphan data found before
+			result.Stale++
+			return nil
+		}
+		if fileBase == nil {
+			//orphan record, rebuild file base
+			orphanMd5s[msg.GetMd5()] = true
+			result.Orphans = append(result.Orphans, f.genRecoverFileBase(chunkId, offset, msg))
+			return nil
+		}
+		if fileBase.Removed || fileBase.ChunkFileId != chunkId || fileBase.Offset != offset {
+			result.Stale++
+			return nil
+		}
+		result.Live++
+		return nil
+	}
+	walkSkip := runningChunk.GenWalkSkip(chunkId)
+	result.Walk, err = chunk.WalkDataFile(file, f.cfg.ChunkBlockSize, cb, walkSkip)
+	if err != nil || !opt.Repopulate {
+		return result, err
+	}
+
+	//load chunk and fix chunk size
+	chunkObj, err := f.manager.LoadChunk(chunkId)
+	if err != nil {
+		return result, err
+	}
+	chunkObj.FixChunkSize(result.Walk.TailOffset)
+
+	//save orphan file base and info
+	for _, fileBase := range result.Orphans {
+		shortUrl, subErr := f.repopulateFileBase(chunkObj, fileBase)
+		if subErr != nil {
+			return result, subErr
+		}
+		result.Recovered[fileBase.Md5] = shortUrl
+	}
+	return result, nil
+}
+
+//gen file base of orphan record
+func (f *Storage) genRecoverFileBase(
+		chunkId, offset int64,
+		msg face.IMessage,
+	) *json.FileBaseJson {
+	fileBase := json.NewFileBaseJson()
+	fileBase.Md5 = msg.GetMd5()
+	fileBase.ChunkFileId = chunkId
+	fileBase.Offset = offset
+	fileBase.Blocks = msg.GetBlocks()
+	fileBase.Size = msg.GetRawLen()
+	fileBase.Appoints = define.DefaultFileAppoint
+	fileBase.CreateAt = time.Now().Unix()
+	return fileBase
+}
+
+//save orphan file base with new file info
+//crc32 and content type detected by read data
+//return shortUrl, error
+func (f *Storage) repopulateFileBase(
+		chunkObj *chunk.Chunk,
+		fileBase *json.FileBaseJson,
+	) (string, error) {
+	//read data for checksum and content type
+	reader, err := chunkObj.OpenReader(fileBase.Offset)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	bufReader := bufio.NewReaderSize(reader, define.ContentTypeSniffSize)
+	sniffData, _ := bufReader.Peek(define.ContentTypeSniffSize)
+	contentType := http.DetectContentType(sniffData)
+	crcHash := f.NewCrc32Hash()
+	_, err = io.Copy(crcHash, bufReader)
+	if err != nil {
+		return "", err
+	}
+	fileBase.Crc32 = crcHash.Sum32()
+
+	//save file base and info
+	err = f.saveFileBase(fileBase)
+	if err != nil {
+		return "", err
+	}
+	return f.createFileInfo(fileBase, contentType, nil)
+}
@@ -11,12 +11,13 @@ import (
 	"github.com/andyzhou/tinylib/queue"
 	"github.com/andyzhou/tinylib/util"
 	"log"
-	"sort"
 	"sync"
 )
 
 /*
  * removed chunk base file info
+ * - md5 -> blocks, used for re-use same removed data
+ * - removed space managed by extent allocator
  * - storage into local gob file
  * - cached in run env and auto sync into local
  */
@@ -25,7 +26,6 @@ import (
 type Removed struct {
 	wg            *sync.WaitGroup     //reference
 	cfg           *conf.Config        //reference
-	removedSorter removedBaseFileSort //internal sorter
 	removedJson   *json.RemovedJson
 	gobFile       string
 	gob           *util.Gob
@@ -69,47 +69,34 @@ func (f *Removed) Quit() {
 	}
 }
 
-//get available removed file base info
-//return md5, error
-func (f *Removed) GetAvailableRemovedFileBase(dataSize int64) (string, error) {
-	var (
-		matchedMd5 string
-		matchedIdx = -1
-		diff int
-	)
+//take out assigned removed base file
+//return true if found and removed
+func (f *Removed) TakeRemoved(md5 string) bool {
 	//check
-	if dataSize <= 0 {
-		return matchedMd5, errors.New("invalid parameter")
-	}
-	if f.removedSorter == nil || len(f.removedSorter) <= 0 {
-		return matchedMd5, nil
+	if md5 == "" {
+		return false
 	}
 
-	//pick matched obj with locker
+	//remove with locker
 	f.Lock()
 	defer f.Unlock()
-	for idx, info := range f.removedSorter {
-		if info.blocks >= dataSize {
-			diff = int(info.blocks - dataSize)
-			if diff <= define.DefaultChunkBlockSize {
-				//matched
-				matchedIdx = idx
-				matchedMd5 = info.md5
-				break
-			}
-		}
-	}
-	if matchedIdx < 0 {
-		return matchedMd5, nil
+	_, ok := f.removedJson.BaseInfo[md5]
+	if !ok {
+		return false
 	}
+	delete(f.removedJson.BaseInfo, md5)
+	return true
+}
 
-	//remove from obj
-	delete(f.removedJson.BaseInfo, matchedMd5)
-
-	//update sorter
-	f.removedSorter = append(f.removedSorter[0:matchedIdx], f.removedSorter[matchedIdx+1:]...)
-
-	return matchedMd5, nil
+//get all removed base file md5
+func (f *Removed) GetRemovedMd5List() []string {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]string, 0, len(f.removedJson.BaseInfo))
+	for k := range f.removedJson.BaseInfo {
+		result = append(result, k)
+	}
+	return result
 }
 
 //add new removed base file
@@ -129,19 +116,6 @@ func (f *Removed) AddRemoved(md5 string, blocks int64) error {
 
 	//sync into run data
 	f.removedJson.BaseInfo[md5] = blocks
-
-	//add into sorter
-	if f.removedSorter == nil {
-		f.removedSorter = make([]*removedBaseFile, 0)
-	}
-	rf := &removedBaseFile{
-		md5: md5,
-		blocks: blocks,
-	}
-	f.removedSorter = append(f.removedSorter, rf)
-
-	//sorter data
-	sort.Sort(f.removedSorter)
 	return nil
 }
 
@@ -173,18 +147,6 @@ func (f *Removed) SetConfig(cfg *conf.Config) error {
 	if err != nil {
 		f.removedJson = json.NewRemovedJson()
 	}
-
-	//fill inter sorter
-	for k, v := range f.removedJson.BaseInfo {
-		rf := &removedBaseFile{
-			md5: k,
-			blocks: v,
-		}
-		f.removedSorter = append(f.removedSorter, rf)
-	}
-
-	//re-sort
-	sort.Sort(f.removedSorter)
 	return nil
 }
 
@@ -3,17 +3,19 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/andyzhou/pond/chunk"
 	"github.com/andyzhou/pond/conf"
-	"github.com/andyzhou/pond/data"
 	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
 	"github.com/andyzhou/pond/json"
-	"github.com/andyzhou/pond/search"
 	"github.com/andyzhou/pond/utils"
 )
 
@@ -31,10 +33,13 @@ type Storage struct {
 	cfg          *conf.Config      //reference
 	redisCfg     *conf.RedisConfig //reference
 	manager      *Manager
-	data         *data.InterRedisData
-	useRedis     bool
+	store        face.IMetaStore
+	storeName    string
 	initDone     bool
 	searchLocker sync.RWMutex
+	uploadLocker sync.Mutex //upload session locker
+	versionLocker sync.Mutex //file versions locker
+	lifecycleRunning int32 //atomic switcher
 	Base
 	utils.Utils
 }
@@ -44,7 +49,6 @@ func NewStorage(wg *sync.WaitGroup) *Storage {
 	this := &Storage{
 		wg: wg,
 		manager: NewManager(wg),
-		data: data.NewInterRedisData(),
 	}
 	return this
 }
@@ -52,8 +56,8 @@ func NewStorage(wg *sync.WaitGroup) *Storage {
 //quit
 func (f *Storage) Quit() {
 	f.manager.Quit()
-	if !f.useRedis {
-		search.GetSearch().Quit()
+	if f.store != nil {
+		f.store.Quit()
 	}
 }
 
@@ -62,6 +66,16 @@ func (f *Storage) GetAllChunkActiveSize() map[int64]int64 {
 	return f.manager.GetAllChunkSize()
 }
 
+//compact chunks
+//if no chunk id assigned, pick chunks by dead space ratio
+func (f *Storage) Compact(chunkIds ...int64) ([]*CompactResult, error) {
+	//check
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	return f.manager.Compact(chunkIds...)
+}
+
 //get file info list from search
 //sync opt
 func (f *Storage) GetFilesInfo(
@@ -71,15 +85,161 @@ func (f *Storage) GetFilesInfo(
 	if !f.initDone {
 		return 0, nil, errors.New("config didn't setup")
 	}
-	if f.useRedis {
-		//get from redis
-		filesInfo, err := f.data.GetFile().GetInfoList(page, pageSize)
-		return 0, filesInfo, err
-	}else{
-		//get from search file info
-		fileInfoSearch := search.GetSearch().GetFileInfo()
-		return fileInfoSearch.GetBathByTime(page, pageSize)
+	total, result, err := f.store.ListInfoByTime(page, pageSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	f.syncFilesLocation(result)
+	return total, result, nil
+}
+
+//get batch file info after cursor by create time
+//cursor format: createAt:shortUrl, empty means from latest
+//return total, []*FileInfoJson, next cursor, error
+func (f *Storage) GetFilesInfoByCursor(
+		cursor string,
+		pageSize int,
+	) (int64, []*json.FileInfoJson, string, error) {
+	var (
+		createAt int64
+		shortUrl string
+		nextCursor string
+	)
+	//check
+	if !f.initDone {
+		return 0, nil, nextCursor, errors.New("config didn't setup")
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	if cursor != "" {
+		cursorSlice := strings.SplitN(cursor, define.FileCursorSplit, 2)
+		if len(cursorSlice) != 2 || cursorSlice[1] == "" {
+			return 0, nil, nextCursor, errors.New("invalid cursor")
+		}
+		createAt, _ = strconv.ParseInt(cursorSlice[0], 10, 64)
+		shortUrl = cursorSlice[1]
+	}
+
+	//get batch file info
+	total, result, err := f.store.ListInfoByCursor(createAt, shortUrl, pageSize)
+	if err != nil {
+		return 0, nil, nextCursor, err
+	}
+	f.syncFilesLocation(result)
+	if len(result) >= pageSize {
+		lastInfo := result[len(result)-1]
+		nextCursor = fmt.Sprintf(define.FileCursorPara, lastInfo.CreateAt, define.FileCursorSplit, lastInfo.ShortUrl)
+	}
+	return total, result, nextCursor, nil
+}
+
+//get batch file info by query
+//removed file data listed from removed file base
+//if meta store not support query, scan all file info
+//return total, []*FileInfoJson, error
+func (f *Storage) GetFilesInfoByQuery(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	//check
+	if query == nil {
+		return 0, nil, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return 0, nil, errors.New("config didn't setup")
+	}
+	if query.Page <= 0 {
+		query.Page = define.DefaultPage
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = define.DefaultPageSize
+	}
+
+	//list removed file data
+	if query.Removed {
+		return f.getRemovedFilesByQuery(query)
+	}
+
+	//query by meta store
+	//chunk of stored file info may be stale, chunk filter checked by scan
+	queryStore, ok := f.store.(face.IMetaQueryStore)
+	if ok && query.ChunkFileId <= 0 {
+		total, result, err := queryStore.ListInfoByQuery(query)
+		if err != nil {
+			return 0, nil, err
+		}
+		f.syncFilesLocation(result)
+		return total, result, nil
 	}
+
+	//scan all file info
+	//location synced from file base before matched
+	var (
+		createAt int64
+		shortUrl string
+	)
+	result := make([]*json.FileInfoJson, 0)
+	for {
+		_, filesInfo, err := f.store.ListInfoByCursor(createAt, shortUrl, define.FileQueryScanSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		f.syncFilesLocation(filesInfo)
+		for _, v := range filesInfo {
+			if f.IsFileMatched(query, v) {
+				result = append(result, v)
+			}
+		}
+		if len(filesInfo) < define.FileQueryScanSize {
+			break
+		}
+		lastInfo := filesInfo[len(filesInfo)-1]
+		createAt, shortUrl = lastInfo.CreateAt, lastInfo.ShortUrl
+	}
+	f.SortFiles(query, result)
+	return int64(len(result)), f.PageFiles(query, result), nil
+}
+
+//get file info
+//only opt meta data, not read chunk data
+func (f *Storage) GetFileInfo(
+	shortUrl string) (*json.FileInfoJson, error) {
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+
+	//get file info
+	fileInfo, err := f.getFileInfo(shortUrl)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo == nil || f.isFileExpired(fileInfo) {
+		return nil, define.ErrFileNotFound
+	}
+	return fileInfo, nil
+}
+
+//check file exists or not
+//only opt meta data, not read chunk data
+func (f *Storage) IsFileExists(shortUrl string) (bool, error) {
+	//check
+	if shortUrl == "" {
+		return false, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return false, errors.New("config didn't setup")
+	}
+
+	//get file info, expired file not exists
+	fileInfo, err := f.getFileInfo(shortUrl)
+	if err != nil || fileInfo == nil {
+		return false, err
+	}
+	return !f.isFileExpired(fileInfo), nil
 }
 
 //delete data
@@ -94,10 +254,14 @@ func (f *Storage) DeleteData(
 		return errors.New("config didn't setup")
 	}
 
+	//delete with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
 	//get file info
 	fileInfo, _ := f.getFileInfo(shortUrl)
 	if fileInfo == nil {
-		return errors.New("can't get file info by short url")
+		return define.ErrFileNotFound
 	}
 
 	//get file base
@@ -106,28 +270,13 @@ func (f *Storage) DeleteData(
 		return errors.New("can't get file base info")
 	}
 
-	//decr appoint value
-	fileBase.Appoints--
-	if fileBase.Appoints <= 0 {
-		//update removed status
-		fileBase.Removed = true
-		fileBase.Appoints = 0
+	//file base kept by version
+	if f.cfg.Versioning {
+		return f.deleteVersioned(fileInfo, fileBase)
 	}
 
-	//update file base info
-	err := f.saveFileBase(fileBase)
-	if err != nil {
-		return err
-	}
-
-	//del file info
-	err = f.delFileInfo(shortUrl)
-
-	//add removed info into run env
-	if fileBase.Removed && err == nil {
-		f.manager.GetRunningChunk().AddRemovedBaseInfo(fileBase)
-	}
-	return err
+	//release file base and del file info
+	return f.releaseFileBase(shortUrl, fileBase)
 }
 
 //read data
@@ -139,7 +288,6 @@ func (f *Storage) ReadData(
 	) ([]byte, error) {
 	var (
 		assignedOffset, assignedEnd int64
-		realEnd int64
 	)
 	//check
 	if shortUrl == "" {
@@ -149,20 +297,24 @@ func (f *Storage) ReadData(
 		return nil, errors.New("config didn't setup")
 	}
 
+	//read with compact read locker
+	//access time updated after locker released
+	var accessInfo *json.FileInfoJson
+	defer func() {
+		f.touchFile(accessInfo)
+	}()
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
 	//get file info
 	fileInfo, err := f.getFileInfo(shortUrl)
 	if err != nil {
 		return nil, err
 	}
-	if fileInfo == nil {
-		return nil, errors.New("can't get file info")
-	}
-
-	//get relate chunk data
-	chunkObj, subErr := f.manager.GetChunkById(fileInfo.ChunkFileId)
-	if subErr != nil || chunkObj == nil {
-		return nil, subErr
+	if fileInfo == nil || f.isFileExpired(fileInfo) {
+		return nil, define.ErrFileNotFound
 	}
+	accessInfo = fileInfo
 
 	//detect assigned offset and length
 	if offsetAndEnds != nil {
@@ -180,34 +332,26 @@ func (f *Storage) ReadData(
 		}
 	}
 
-	//setup real offset and length
-	realOffset := fileInfo.Offset
-	skipHeader := false
-	if assignedOffset >= 0 {
-		realOffset += assignedOffset
-		skipHeader = true
+	//setup logical offset and length
+	if assignedOffset < 0 {
+		assignedOffset = 0
 	}
-	if assignedEnd > 0 {
-		if assignedEnd < fileInfo.Size {
-			realEnd = realOffset + assignedEnd
-		}else{
-			realEnd = fileInfo.Offset + fileInfo.Size
-		}
-	}else{
-		realEnd = realOffset + fileInfo.Size
+	realLen := fileInfo.Size - assignedOffset
+	if assignedEnd > 0 && assignedEnd < realLen {
+		realLen = assignedEnd
 	}
 
-	//read chunk file data
-	fileData, subErrTwo := chunkObj.ReadFile(realOffset, realEnd, skipHeader)
-	return fileData, subErrTwo
+	//read file data
+	return f.readFileData(fileInfo, assignedOffset, realLen)
 }
 
 //write new or old data
 //if assigned short url means overwrite old data
-//if overwrite data, fix chunk size config should be true
+//if overwrite data, grown data relocated into new space
 //return shortUrl, error
 func (f *Storage) WriteData(
 		data []byte,
+		opt *conf.WriteOption,
 		shortUrls ...string,
 	) (string, error) {
 	var (
@@ -227,16 +371,12 @@ func (f *Storage) WriteData(
 		shortUrl = shortUrls[0]
 	}
 
-	//overwrite data should setup fix chunk size config
-	if shortUrl != "" && !f.cfg.FixedBlockSize {
-		return shortUrl, errors.New("config need set fix chunk size as true")
-	}
 	if shortUrl != "" {
 		//over write data
-		err = f.overwriteData(shortUrl, data)
+		err = f.overwriteData(shortUrl, data, opt)
 	}else{
 		//write new data
-		shortUrl, err = f.writeNewData(data)
+		shortUrl, err = f.writeNewData(data, opt)
 	}
 	return shortUrl, err
 }
@@ -252,52 +392,238 @@ func (f *Storage) SetConfig(
 	if cfg == nil || cfg.DataPath == "" {
 		return errors.New("invalid parameter")
 	}
+	_, err := face.GetPacket(cfg.HeaderVersion)
+	if err != nil {
+		return err
+	}
+	codec, err := chunk.GetCodec(cfg.Compress)
+	if err != nil {
+		return err
+	}
+	if cfg.HeaderVersion == face.PacketVersionOne &&
+		(codec != define.CodecOfNone || cfg.KeyProvider != nil) {
+		return errors.New("legacy header version not support compress or encrypt")
+	}
+	if cfg.KeyProvider != nil {
+		_, _, err = cfg.KeyProvider.CurrentKey()
+		if err != nil {
+			return err
+		}
+	}
+	err = f.checkLifecycleRules(cfg.LifecycleRules)
+	if err != nil {
+		return err
+	}
 
-	//init redis data
+	//init meta store
+	//use redis store if redis config assigned
 	if redisCfg != nil && len(redisCfg) > 0 {
 		oneRedisCfg = redisCfg[0]
+	}
+	storeName := cfg.MetaStore
+	if storeName == "" {
+		storeName = define.MetaStoreOfSearch
 		if oneRedisCfg != nil {
-			if oneRedisCfg.GroupTag == "" {
-				oneRedisCfg.GroupTag = define.DefaultRedisGroup
-			}
-		}
-		f.setRedisConfig(oneRedisCfg)
-		f.SetBaseUseRedis(true)
-		f.SetBaseData(f.data)
-		f.manager.SetData(f.data)
-	}else{
-		//search setup
-		err := search.GetSearch().SetCore(cfg.DataPath, cfg.InterQueueSize)
-		if err != nil {
-			return err
+			storeName = define.MetaStoreOfRedis
 		}
 	}
+	store, err := CreateMetaStore(storeName, cfg, oneRedisCfg)
+	if err != nil {
+		return err
+	}
+	if _, ok := store.(face.IMetaVersionStore); cfg.Versioning && !ok {
+		store.Quit()
+		return fmt.Errorf("meta store %v not support version", storeName)
+	}
+	f.redisCfg = oneRedisCfg
+	f.store = store
+	f.storeName = storeName
+	f.SetBaseStore(store)
+	f.manager.SetStore(store)
 
 	//manager setup
 	f.cfg = cfg
-	err := f.manager.SetConfig(cfg, f.useRedis)
+	err = f.manager.SetConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	//replay wal entries not checkpoint
+	//storage not usable if replay failed
+	err = f.replayWal()
+	if err != nil {
+		return err
+	}
+	f.manager.SetExpireSweeper(f.SweepExpired)
+	f.manager.SetLifecycleRunner(f.RunLifecycle)
 	f.initDone = true
-	return err
+	return nil
 }
 
 ///////////////
 //private func
 ///////////////
 
-//set redis config
-func (f *Storage) setRedisConfig(cfg *conf.RedisConfig) error {
-	if cfg == nil {
-		return errors.New("invalid parameter")
+//get removed file data by query
+//file base converted as file info without short url
+func (f *Storage) getRemovedFilesByQuery(
+		query *json.FileQueryJson,
+	) (int64, []*json.FileInfoJson, error) {
+	result := make([]*json.FileInfoJson, 0)
+	for page := define.DefaultPage; ; page++ {
+		_, filesBase, err := f.store.ListRemovedBase(page, define.FileQueryScanSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, v := range filesBase {
+			if v == nil || !v.Removed {
+				continue
+			}
+			fileInfo := json.NewFileInfoJson()
+			fileInfo.Md5 = v.Md5
+			fileInfo.Size = v.Size
+			fileInfo.ChunkFileId = v.ChunkFileId
+			fileInfo.Offset = v.Offset
+			fileInfo.Blocks = v.Blocks
+			fileInfo.Crc32 = v.Crc32
+			fileInfo.CreateAt = v.CreateAt
+			if f.IsFileMatched(query, fileInfo) {
+				result = append(result, fileInfo)
+			}
+		}
+		if len(filesBase) < define.FileQueryScanSize {
+			break
+		}
+	}
+	f.SortFiles(query, result)
+	return int64(len(result)), f.PageFiles(query, result), nil
+}
+
+//read data of file info
+//should be called with compact read locker
+func (f *Storage) readFileData(
+		fileInfo *json.FileInfoJson,
+		assignedOffset, realLen int64,
+	) ([]byte, error) {
+	//multipart file read by parts
+	if fileInfo.Parts > 0 {
+		return f.readParts(fileInfo, assignedOffset, realLen)
+	}
+
+	//get relate chunk data
+	chunkObj, err := f.manager.GetChunkById(fileInfo.ChunkFileId)
+	if err != nil || chunkObj == nil {
+		return nil, err
+	}
+
+	//read chunk file data
+	//compressed data decoded by chunk
+	fileData, err := chunkObj.ReadRange(fileInfo.Offset, assignedOffset, realLen)
+	if err != nil {
+		return nil, err
+	}
+
+	//verify whole data checksum
+	if f.cfg.VerifyOnRead && assignedOffset == 0 && int64(len(fileData)) == fileInfo.Size {
+		err = f.verifyData(fileInfo, fileData)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fileData, nil
+}
+
+//replay wal entries
+//file base and info saved again, removed data synced
+//space of replayed file base synced after all entries done
+func (f *Storage) replayWal() error {
+	runningChunk := f.manager.GetRunningChunk()
+	replayedMd5s := map[string]bool{}
+	cb := func(entry *json.WalEntryJson) error {
+		fileBase := entry.FileBase
+		replayedMd5s[fileBase.Md5] = true
+		switch entry.Op {
+		case define.WalOpOfReuseRemoved:
+			runningChunk.DropRemovedBaseInfo(fileBase.Md5)
+		case define.WalOpOfDelete:
+			if fileBase.Removed && len(fileBase.Parts) > 0 {
+				//multipart file base removed directly
+				f.delFileBase(fileBase.Md5)
+				f.delFileInfo(entry.ShortUrl)
+				return nil
+			}
+			if fileBase.Removed {
+				runningChunk.KeepRemovedBaseInfo(fileBase)
+			}
+		}
+		err := f.saveFileBase(fileBase)
+		if err != nil {
+			return err
+		}
+		if entry.Op == define.WalOpOfDelete {
+			//file info may be deleted before
+			//upload part without file info
+			if entry.ShortUrl != "" {
+				f.delFileInfo(entry.ShortUrl)
+			}
+			return nil
+		}
+		if entry.FileInfo == nil {
+			return nil
+		}
+		return f.saveFileInfo(entry.FileInfo)
+	}
+	replayed, err := f.manager.GetWal().Replay(cb)
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		log.Printf("storage.replayWal, replayed %v entries\n", replayed)
+	}
+	err = f.syncReplayedSpace(replayedMd5s)
+	if err != nil {
+		return err
+	}
+	return f.manager.CheckpointWal()
+}
+
+//sync space of replayed file base
+//removed data space freed first, delete may break before space freed
+//then live data space occupied, it may be freed by replayed delete or not saved in extent
+func (f *Storage) syncReplayedSpace(md5s map[string]bool) error {
+	runningChunk := f.manager.GetRunningChunk()
+	liveBases := make([]*json.FileBaseJson, 0)
+	for md5 := range md5s {
+		fileBase, _ := f.getFileBase(md5)
+		if fileBase == nil || len(fileBase.Parts) > 0 || fileBase.ChunkFileId <= 0 {
+			continue
+		}
+		if !fileBase.Removed {
+			liveBases = append(liveBases, fileBase)
+			continue
+		}
+		err := runningChunk.FreeRemovedSpace(fileBase)
+		if err != nil {
+			return err
+		}
+	}
+	for _, fileBase := range liveBases {
+		err := runningChunk.OccupySpace(fileBase.ChunkFileId, fileBase.Offset, f.getSpaceSize(fileBase.Blocks))
+		if err != nil {
+			return err
+		}
 	}
-	f.redisCfg = cfg
-	f.useRedis = true
-	f.data.SetRedisConf(cfg)
 	return nil
 }
 
 //overwrite old data
-//fix chunk size config should be true
-func (f *Storage) overwriteData(shortUrl string, fileData[]byte) error {
+//shared data copied on write, see overwriteShared
+//data written in place if old space enough
+//else relocated into new space, old space released
+func (f *Storage) overwriteData(
+	shortUrl string,
+	fileData []byte,
+	opt *conf.WriteOption) error {
 	var (
 		fileInfoObj *json.FileInfoJson
 		fileBaseObj *json.FileBaseJson
@@ -308,95 +634,241 @@ func (f *Storage) overwriteData(shortUrl string, fileData[]byte) error {
 		return errors.New("invalid parameter")
 	}
 
+	//overwrite with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
 	//get file and base info
-	if f.useRedis {
-		//use redis data
-		fileInfoData := f.data.GetFile()
-		fileInfoObj, err = fileInfoData.GetInfo(shortUrl)
-		if err != nil || fileInfoObj == nil {
-			return errors.New("no file info for this short url")
-		}
-		fileBaseObj, err = fileInfoData.GetBase(fileInfoObj.Md5)
-		if err != nil || fileBaseObj == nil {
-			return errors.New("no file base info for this short url")
+	fileInfoObj, err = f.getFileInfo(shortUrl)
+	if err != nil || fileInfoObj == nil {
+		return define.ErrFileNotFound
+	}
+	fileBaseObj, err = f.getFileBase(fileInfoObj.Md5)
+	if err != nil || fileBaseObj == nil {
+		return errors.New("can't get file base info")
+	}
+
+	//get compress codec
+	codec, err := f.getCodec(opt)
+	if err != nil {
+		return err
+	}
+
+	//shared data written as new file base
+	if f.isOverwriteShared(fileBaseObj) {
+		return f.overwriteShared(fileInfoObj, fileBaseObj, fileData, codec, opt)
+	}
+
+	//pick chunk and offset for new data
+	//grown data relocated into new space
+	var (
+		activeChunk *chunk.Chunk
+		offset int64
+		spaceSize int64
+	)
+	dataLen := int64(len(fileData))
+	fileMd5 := fileInfoObj.Md5
+	oldChunkId := fileBaseObj.ChunkFileId
+	oldOffset := fileBaseObj.Offset
+	oldSpace := f.getSpaceSize(fileBaseObj.Blocks)
+	isRelocated := chunk.CalRecordSpace(f.cfg, dataLen) > oldSpace
+	if isRelocated {
+		activeChunk, offset, spaceSize, err = f.pickChunkForWrite(dataLen)
+		if err != nil {
+			return err
 		}
 	}else{
-		//use search data
-		fileInfoSearch := search.GetSearch().GetFileInfo()
-		fileBaseSearch := search.GetSearch().GetFileBase()
-		fileInfoObj, err = fileInfoSearch.GetOne(shortUrl)
-		if err != nil || fileInfoObj == nil {
-			return errors.New("no file info for this short url")
+		activeChunk, err = f.manager.GetChunkById(oldChunkId)
+		if err != nil {
+			return err
 		}
-		fileBaseObj, err = fileBaseSearch.GetOne(fileInfoObj.Md5)
-		if err != nil || fileBaseObj == nil {
-			return errors.New("can't get file base info")
+		if activeChunk == nil {
+			return errors.New("can't get active chunk")
 		}
+		offset = oldOffset
+		spaceSize = oldSpace
 	}
 
-	dataLen := int64(len(fileData))
-	fileMd5 := fileInfoObj.Md5
-	offset := fileInfoObj.Offset
-	if fileInfoObj.Blocks < dataLen {
-		return errors.New("new file data size exceed old data")
+	//update file base and info with locker
+	f.searchLocker.Lock()
+	defer f.searchLocker.Unlock()
+	oldBase, oldInfo := *fileBaseObj, *fileInfoObj
+	updateFile := func(offset, blocks int64) {
+		fileBaseObj.ChunkFileId = activeChunk.GetFileId()
+		fileBaseObj.Offset = offset
+		fileBaseObj.Size = dataLen
+		fileBaseObj.Blocks = blocks
+		fileBaseObj.Crc32 = f.Crc32Sum(fileData)
+
+		//update file info
+		fileInfoObj.ChunkFileId = fileBaseObj.ChunkFileId
+		fileInfoObj.Offset = fileBaseObj.Offset
+		fileInfoObj.Size = dataLen
+		fileInfoObj.Blocks = fileBaseObj.Blocks
+		fileInfoObj.Crc32 = fileBaseObj.Crc32
+		fileInfoObj.UpdateAt = time.Now().Unix()
+		f.applyWriteOption(fileInfoObj, opt)
 	}
 
-	//get assigned chunk
-	activeChunk, subErr := f.manager.GetChunkById(fileInfoObj.ChunkFileId)
-	if subErr != nil {
-		return subErr
+	//write chunk data
+	//relocated data written into new space, then write ahead log
+	//in place data written after write ahead log
+	var (
+		resp *chunk.WriteResp
+		isLogged bool
+	)
+	if isRelocated {
+		resp = activeChunk.WriteCodecFile(fileMd5, fileData, codec, offset)
+	}else{
+		prepare := func(blocks int64) error {
+			updateFile(offset, blocks)
+			err := f.manager.GetWal().Append(define.WalOpOfOverwrite, shortUrl, fileBaseObj, fileInfoObj)
+			isLogged = err == nil
+			return err
+		}
+		resp = activeChunk.OverwriteCodecFile(fileMd5, fileData, codec, offset, prepare)
 	}
-	if activeChunk == nil {
-		return errors.New("can't get active chunk")
+	if resp == nil || resp.Err != nil {
+		if isRelocated {
+			//release picked free space
+			f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, 0)
+		}else{
+			//restore file base and info
+			*fileBaseObj, *fileInfoObj = oldBase, oldInfo
+			if isLogged {
+				f.manager.GetWal().Append(define.WalOpOfOverwrite, shortUrl, fileBaseObj, fileInfoObj)
+			}
+		}
+		if resp == nil {
+			return errors.New("can't get chunk write file response")
+		}
+		return resp.Err
+	}
+
+	//release unused tail of data space
+	f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, f.getSpaceSize(resp.BlockSize))
+
+	//write ahead log of relocated data
+	if isRelocated {
+		updateFile(resp.NewOffSet, resp.BlockSize)
+		err = f.manager.GetWal().Append(define.WalOpOfOverwrite, shortUrl, fileBaseObj, fileInfoObj)
+		if err != nil {
+			return err
+		}
 	}
 
-	//overwrite chunk data
-	resp := activeChunk.WriteFile(fileMd5, fileData, offset)
-	if resp == nil {
-		return errors.New("can't get chunk write file response")
+	//save info and base data
+	err = f.saveFile(fileBaseObj, fileInfoObj)
+	if err != nil || !isRelocated {
+		return err
 	}
-	if resp.Err != nil {
-		return resp.Err
+
+	//release old data space after relocated
+	return f.releaseChunkSpace(oldChunkId, oldOffset, oldSpace)
+}
+
+//check file base should be copied on write or not
+//file base shared by multi files, or content md5 of check same mode
+//multipart file base can't be written in place
+//old file base kept if versioning
+func (f *Storage) isOverwriteShared(fileBase *json.FileBaseJson) bool {
+	return f.cfg.CheckSame || f.cfg.Versioning || fileBase.Appoints > 1 || len(fileBase.Parts) > 0
+}
+
+//overwrite shared data by copy on write
+//new data written as new file base, only file info re-pointed
+//appoint value of old file base decreased, or kept by version if versioning
+func (f *Storage) overwriteShared(
+		fileInfo *json.FileInfoJson,
+		fileBase *json.FileBaseJson,
+		data []byte,
+		codec uint8,
+		opt *conf.WriteOption,
+	) error {
+	var (
+		fileMd5 string
+		err error
+	)
+	if f.cfg.CheckSame {
+		fileMd5, err = f.Md5Sum(data)
+	}else{
+		fileMd5, err = f.genRandMd5()
+	}
+	if err != nil {
+		return err
 	}
 
 	//update file base with locker
 	f.searchLocker.Lock()
 	defer f.searchLocker.Unlock()
 
-	fileBaseObj.Size = dataLen
-	fileBaseObj.Blocks = resp.BlockSize
+	//write new file base data
+	newBase, walOp, err := f.writeFileBase(fileMd5, data, codec)
+	if err != nil {
+		return err
+	}
 
+	//re-point file info
+	oldInfo := *fileInfo
+	fileInfo.Md5 = newBase.Md5
+	fileInfo.ChunkFileId = newBase.ChunkFileId
+	fileInfo.Offset = newBase.Offset
+	fileInfo.Size = newBase.Size
+	fileInfo.Blocks = newBase.Blocks
+	fileInfo.Crc32 = newBase.Crc32
+	fileInfo.Parts = len(newBase.Parts)
+	fileInfo.UpdateAt = time.Now().Unix()
+	f.applyWriteOption(fileInfo, opt)
 
-	//update file info
-	fileInfoObj.Size = dataLen
-	fileInfoObj.Offset = resp.NewOffSet
+	//write ahead log
+	err = f.manager.GetWal().Append(walOp, fileInfo.ShortUrl, newBase, fileInfo)
+	if err != nil {
+		return err
+	}
+	err = f.saveFile(newBase, fileInfo)
+	if err != nil {
+		return err
+	}
 
-	//save info and base data
-	if f.useRedis {
-		//save into redis
-		fileInfoData := f.data.GetFile()
-		err = fileInfoData.AddBase(fileBaseObj)
-		err = fileInfoData.AddInfo(fileInfoObj)
-	}else{
-		//save into search
-		fileInfoSearch := search.GetSearch().GetFileInfo()
-		fileBaseSearch := search.GetSearch().GetFileBase()
-		err = fileBaseSearch.AddOne(fileBaseObj)
-		err = fileInfoSearch.AddOne(fileInfoObj)
+	//old file base kept by version
+	if f.cfg.Versioning {
+		return f.addVersion(&oldInfo, fileInfo)
+	}
+
+	//release reference of old file base
+	return f.releaseParts([]string{fileBase.Md5})
+}
+
+//del file info and leave delete marker
+//file base not changed, kept by version
+//should be called with compact read locker
+func (f *Storage) deleteVersioned(
+		fileInfo *json.FileInfoJson,
+		fileBase *json.FileBaseJson,
+	) error {
+	//write ahead log
+	err := f.manager.GetWal().Append(define.WalOpOfDelete, fileInfo.ShortUrl, fileBase, nil)
+	if err != nil {
+		return err
+	}
+	err = f.delFileInfo(fileInfo.ShortUrl)
+	if err != nil {
+		return err
 	}
-	return err
+
+	//add delete marker
+	return f.addVersion(fileInfo, nil)
 }
 
 //write new data
 //support removed data re-use
 //use locker for atomic opt
-func (f *Storage) writeNewData(data []byte) (string, error) {
+func (f *Storage) writeNewData(
+	data []byte,
+	opt *conf.WriteOption) (string, error) {
 	var (
 		fileMd5 string
 		shortUrl string
-		fileBaseObj *json.FileBaseJson
-		activeChunk *chunk.Chunk
-		offset int64 = -1
 		err error
 	)
 	//check
@@ -404,6 +876,12 @@ func (f *Storage) writeNewData(data []byte) (string, error) {
 		return shortUrl, errors.New("invalid parameter")
 	}
 
+	//get compress codec
+	codec, err := f.getCodec(opt)
+	if err != nil {
+		return shortUrl, err
+	}
+
 	//gen and check base file by md5
 	if f.cfg.CheckSame {
 		//check same data, use data as md5 base value
@@ -411,111 +889,332 @@ func (f *Storage) writeNewData(data []byte) (string, error) {
 	}else{
 		//not check same data
 		//use rand num + time stamp as md5 base value
-		now := time.Now().UnixNano()
-		rand.Seed(now)
-		randInt := rand.Int63n(now)
-		md5ValBase := fmt.Sprintf("%v:%v", randInt, now)
-		fileMd5, err = f.Md5Sum([]byte(md5ValBase))
+		fileMd5, err = f.genRandMd5()
 	}
 	if err != nil || fileMd5 == "" {
 		return shortUrl, err
 	}
 
-	dataSize := int64(len(data))
-	needWriteChunkData := true
+	//write with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
+	//write file base data
+	fileBaseObj, walOp, err := f.writeFileBase(fileMd5, data, codec)
+	if err != nil {
+		return shortUrl, err
+	}
+
+	//save file base and info
+	contentType := http.DetectContentType(data)
+	return f.saveNewFile(walOp, fileBaseObj, contentType, opt)
+}
+
+//write file base data into chunk
+//same file base re-used if check same data
+//should be called with compact read locker
+//return file base, wal operate, error
+func (f *Storage) writeFileBase(
+		fileMd5 string,
+		data []byte,
+		codec uint8,
+	) (*json.FileBaseJson, int, error) {
+	var (
+		fileBaseObj *json.FileBaseJson
+		walOp = define.WalOpOfWrite
+	)
 	if f.cfg.CheckSame {
 		//need check same, check file base info
-		fileBaseObj, _ = f.getFileBase(fileMd5)
+		fileBaseObj, walOp = f.getSameFileBase(fileMd5)
 		if fileBaseObj != nil {
-			if !fileBaseObj.Removed {
-				//inc appoint value of file base info
-				fileBaseObj.Appoints++
-			}
-			needWriteChunkData = false
+			return fileBaseObj, walOp, nil
 		}
 	}
 
-	if needWriteChunkData {
-		//get removed chunk block data
-		removedFileBase, _ := f.manager.GetRunningChunk().GetAvailableRemovedFileBase(dataSize)
-		if removedFileBase != nil {
-			//set file base obj
-			fileBaseObj = removedFileBase
-			fileBaseObj.Size = dataSize
-			fileBaseObj.Removed = false
-			fileBaseObj.Appoints = define.DefaultFileAppoint
+	//pick chunk and offset for new data
+	dataSize := int64(len(data))
+	activeChunk, offset, spaceSize, err := f.pickChunkForWrite(dataSize)
+	if err != nil {
+		return nil, walOp, err
+	}
 
-			//others setup
-			offset = fileBaseObj.Offset
+	//write file base byte data
+	resp := activeChunk.WriteCodecFile(fileMd5, data, codec, offset)
+	if resp == nil || resp.Err != nil {
+		//release picked free space
+		f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, 0)
+		if resp == nil {
+			return nil, walOp, errors.New("can't get chunk write file response")
+		}
+		return nil, walOp, resp.Err
+	}
 
-			//get active chunk by file id
-			activeChunk, err = f.manager.GetChunkById(fileBaseObj.ChunkFileId)
+	//release unused tail of picked free space
+	f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, f.getSpaceSize(resp.BlockSize))
 
-			//save removed
-			f.manager.GetRunningChunk().SaveRemoved()
-		}
+	//create new file base info
+	fileBaseObj = json.NewFileBaseJson()
+	fileBaseObj.Md5 = fileMd5
+	fileBaseObj.ChunkFileId = activeChunk.GetFileId()
+	fileBaseObj.Size = dataSize
+	fileBaseObj.Offset = resp.NewOffSet
+	fileBaseObj.Blocks = resp.BlockSize
+	fileBaseObj.Crc32 = f.Crc32Sum(data)
+	fileBaseObj.Appoints = define.DefaultFileAppoint
+	fileBaseObj.CreateAt = time.Now().Unix()
+	return fileBaseObj, walOp, nil
+}
 
-		//check and pick active chunk
-		if activeChunk == nil {
-			activeChunk, err = f.manager.GetActiveChunk()
-		}
+//decr appoint value of file base and del file info
+//file base without short url is upload part
+//multipart file base removed directly, parts released after
+//should be called with compact read locker
+func (f *Storage) releaseFileBase(
+		shortUrl string,
+		fileBase *json.FileBaseJson,
+	) error {
+	var (
+		err error
+	)
+	//decr appoint value
+	fileBase.Appoints--
+	if fileBase.Appoints <= 0 {
+		//update removed status
+		fileBase.Removed = true
+		fileBase.Appoints = 0
+	}
+
+	//write ahead log
+	err = f.manager.GetWal().Append(define.WalOpOfDelete, shortUrl, fileBase, nil)
+	if err != nil {
+		return err
+	}
+
+	//update file base info
+	isParts := len(fileBase.Parts) > 0
+	if fileBase.Removed && isParts {
+		err = f.delFileBase(fileBase.Md5)
+	}else{
+		err = f.saveFileBase(fileBase)
+	}
+	if err != nil {
+		return err
+	}
+
+	//del file info
+	if shortUrl != "" {
+		err = f.delFileInfo(shortUrl)
 		if err != nil {
-			return shortUrl, err
-		}
-		if activeChunk == nil {
-			return shortUrl, errors.New("can't get active chunk")
+			return err
 		}
 	}
+	if !fileBase.Removed {
+		return nil
+	}
+	if isParts {
+		//release parts of multipart file
+		return f.releaseParts(fileBase.Parts)
+	}
 
-	//check or update file base info
-	if fileBaseObj == nil {
-		//create new file base info
-		fileBaseObj = json.NewFileBaseJson()
-		fileBaseObj.Md5 = fileMd5
-		fileBaseObj.ChunkFileId = activeChunk.GetFileId()
-		fileBaseObj.Size = dataSize
-		fileBaseObj.Appoints = define.DefaultFileAppoint
-		fileBaseObj.CreateAt = time.Now().Unix()
+	//add removed info into run env
+	f.manager.GetRunningChunk().AddRemovedBaseInfo(fileBase)
+	return nil
+}
+
+//save file base and new file info of written data
+//wal entry appended before save
+//return shortUrl, error
+func (f *Storage) saveNewFile(
+		walOp int,
+		fileBaseObj *json.FileBaseJson,
+		contentType string,
+		opt *conf.WriteOption,
+	) (string, error) {
+	//gen new file info
+	fileInfoObj, err := f.genFileInfo(fileBaseObj, contentType, opt)
+	if err != nil {
+		return "", err
 	}
 
-	if needWriteChunkData && activeChunk != nil {
-		//write file base byte data
-		resp := activeChunk.WriteFile(fileBaseObj.Md5, data, offset)
-		if resp == nil {
-			return shortUrl, errors.New("can't get chunk write file response")
-		}
-		if resp.Err != nil {
-			return shortUrl, resp.Err
-		}
-		//update file base
-		fileBaseObj.Offset = resp.NewOffSet
-		fileBaseObj.Blocks = resp.BlockSize
+	//write ahead log
+	err = f.manager.GetWal().Append(walOp, fileInfoObj.ShortUrl, fileBaseObj, fileInfoObj)
+	if err != nil {
+		return "", err
 	}
 
-	//gen new data short url
-	shortUrl, err = f.manager.GenNewShortUrl()
+	//save file base and info
+	err = f.saveFile(fileBaseObj, fileInfoObj)
+	return fileInfoObj.ShortUrl, err
+}
+
+//create and save new file info for file base
+//return shortUrl, error
+func (f *Storage) createFileInfo(
+		fileBaseObj *json.FileBaseJson,
+		contentType string,
+		opt *conf.WriteOption,
+	) (string, error) {
+	//gen new file info
+	fileInfoObj, err := f.genFileInfo(fileBaseObj, contentType, opt)
 	if err != nil {
-		return shortUrl, err
+		return "", err
 	}
 
-	//save file base
-	err = f.saveFileBase(fileBaseObj)
+	//save file info
+	err = f.saveFileInfo(fileInfoObj)
+	return fileInfoObj.ShortUrl, err
+}
+
+//gen new file info for file base
+func (f *Storage) genFileInfo(
+		fileBaseObj *json.FileBaseJson,
+		contentType string,
+		opt *conf.WriteOption,
+	) (*json.FileInfoJson, error) {
+	//gen new data short url
+	shortUrl, err := f.manager.GenNewShortUrl()
 	if err != nil {
-		return shortUrl, err
+		return nil, err
 	}
 
 	//create new file info
 	fileInfoObj := json.NewFileInfoJson()
 	fileInfoObj.ShortUrl = shortUrl
-	fileInfoObj.Md5 = fileMd5
-	fileInfoObj.ContentType = http.DetectContentType(data)
-	fileInfoObj.Size = int64(len(data))
+	fileInfoObj.Md5 = fileBaseObj.Md5
+	fileInfoObj.ContentType = contentType
+	fileInfoObj.Size = fileBaseObj.Size
 	fileInfoObj.ChunkFileId = fileBaseObj.ChunkFileId
 	fileInfoObj.Offset = fileBaseObj.Offset
 	fileInfoObj.Blocks = fileBaseObj.Blocks
+	fileInfoObj.Crc32 = fileBaseObj.Crc32
 	fileInfoObj.CreateAt = time.Now().Unix()
+	fileInfoObj.UpdateAt = fileInfoObj.CreateAt
+	f.applyWriteOption(fileInfoObj, opt)
+	return fileInfoObj, nil
+}
 
-	//save file info
-	err = f.saveFileInfo(fileInfoObj)
-	return shortUrl, err
-}
\ No newline at end of file
+//apply write option into file info
+//only not empty option value will be applied
+func (f *Storage) applyWriteOption(
+	fileInfoObj *json.FileInfoJson,
+	opt *conf.WriteOption) {
+	//check
+	if fileInfoObj == nil || opt == nil {
+		return
+	}
+	if opt.Name != "" {
+		fileInfoObj.Name = opt.Name
+	}
+	if opt.ContentType != "" {
+		fileInfoObj.ContentType = opt.ContentType
+	}
+	if opt.Metadata != nil && len(opt.Metadata) > 0 {
+		fileInfoObj.Metadata = opt.Metadata
+	}
+	if opt.ExpireAt > 0 {
+		fileInfoObj.ExpireAt = opt.ExpireAt
+	}else if opt.TTL > 0 {
+		fileInfoObj.ExpireAt = time.Now().Unix() + opt.TTL
+	}
+}
+
+//get compress codec of write
+//option value first, then config value
+func (f *Storage) getCodec(opt *conf.WriteOption) (uint8, error) {
+	if opt != nil && opt.Compress != "" {
+		return chunk.GetCodec(opt.Compress)
+	}
+	return chunk.GetCodec(f.cfg.Compress)
+}
+
+//get same file base for new data
+//removed file base will be re-used if data still kept
+//return file base, wal operate
+func (f *Storage) getSameFileBase(md5 string) (*json.FileBaseJson, int) {
+	fileBaseObj, _ := f.getFileBase(md5)
+	if fileBaseObj == nil {
+		return nil, define.WalOpOfWrite
+	}
+	if !fileBaseObj.Removed {
+		//inc appoint value of file base info
+		fileBaseObj.Appoints++
+		return fileBaseObj, define.WalOpOfWrite
+	}
+
+	//take back removed data
+	if !f.manager.GetRunningChunk().TakeRemovedBaseInfo(fileBaseObj) {
+		return nil, define.WalOpOfWrite
+	}
+	fileBaseObj.Removed = false
+	fileBaseObj.Appoints = define.DefaultFileAppoint
+	return fileBaseObj, define.WalOpOfReuseRemoved
+}
+
+//pick chunk and offset for new data
+//free space re-use first
+//should be called with compact read locker
+//return chunk, offset(-1 means append), picked free space size, error
+func (f *Storage) pickChunkForWrite(
+		dataSize int64,
+	) (*chunk.Chunk, int64, int64, error) {
+	var (
+		activeChunk *chunk.Chunk
+		offset int64 = -1
+		spaceSize int64
+		err error
+	)
+
+	//allocate free space, skip compacting and archive chunk
+	needSize := chunk.CalRecordSpace(f.cfg, dataSize)
+	chunkId, freeOffset, _ := f.manager.GetRunningChunk().AllocSpace(needSize, f.manager.IsWriteSkipped)
+	if chunkId > 0 {
+		//get active chunk by file id
+		activeChunk, _ = f.manager.GetChunkById(chunkId)
+		if activeChunk != nil {
+			offset = freeOffset
+			spaceSize = needSize
+		}
+	}
+
+	//check and pick active chunk
+	if activeChunk == nil {
+		activeChunk, err = f.manager.GetActiveChunk()
+	}
+	if err != nil {
+		return nil, offset, spaceSize, err
+	}
+	if activeChunk == nil {
+		return nil, offset, spaceSize, errors.New("can't get active chunk")
+	}
+	return activeChunk, offset, spaceSize, nil
+}
+
+//release unused space of written data
+//spaceSize is whole space of data, usedSize is space kept for data
+func (f *Storage) releaseWriteSpace(
+	chunkFileId, offset, spaceSize, usedSize int64) error {
+	if offset < 0 || spaceSize <= usedSize {
+		return nil
+	}
+	return f.releaseChunkSpace(chunkFileId, offset + usedSize, spaceSize - usedSize)
+}
+
+//release chunk space as free space
+//used for failed or useless written data
+func (f *Storage) releaseChunkSpace(
+	chunkFileId, offset, size int64) error {
+	//check
+	if chunkFileId <= 0 || offset < 0 || size <= 0 {
+		return errors.New("invalid parameter")
+	}
+	return f.manager.GetRunningChunk().FreeSpace(chunkFileId, offset, size)
+}
+
+//gen rand md5 value
+//use rand num + time stamp as md5 base value
+func (f *Storage) genRandMd5() (string, error) {
+	now := time.Now().UnixNano()
+	randInt := rand.Int63n(now)
+	md5ValBase := fmt.Sprintf("%v:%v", randInt, now)
+	return f.Md5Sum([]byte(md5ValBase))
+}
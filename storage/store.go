@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/data"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/kv"
+	"github.com/andyzhou/pond/search"
+)
+
+/*
+ * meta store register face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - local search, redis and bolt store registered by default
+ * - custom store registered by name, selected by config
+ */
+
+//meta store creator
+type MetaStoreCreator func(cfg *conf.Config, redisCfg *conf.RedisConfig) (face.IMetaStore, error)
+
+//global variable
+var (
+	_metaStores       = map[string]MetaStoreCreator{}
+	_metaStoresLocker sync.RWMutex
+)
+
+//init
+func init() {
+	RegisterMetaStore(define.MetaStoreOfSearch, newSearchStore)
+	RegisterMetaStore(define.MetaStoreOfRedis, newRedisStore)
+	RegisterMetaStore(define.MetaStoreOfBolt, newBoltStore)
+}
+
+//register meta store creator
+//same name store will be replaced
+func RegisterMetaStore(name string, creator MetaStoreCreator) error {
+	//check
+	if name == "" || creator == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//register with locker
+	_metaStoresLocker.Lock()
+	defer _metaStoresLocker.Unlock()
+	_metaStores[name] = creator
+	return nil
+}
+
+//create meta store by name
+func CreateMetaStore(
+		name string,
+		cfg *conf.Config,
+		redisCfg *conf.RedisConfig,
+	) (face.IMetaStore, error) {
+	//get creator with locker
+	_metaStoresLocker.RLock()
+	creator, ok := _metaStores[name]
+	_metaStoresLocker.RUnlock()
+	if !ok || creator == nil {
+		return nil, fmt.Errorf("meta store %v not registered", name)
+	}
+	return creator(cfg, redisCfg)
+}
+
+///////////////
+//private func
+///////////////
+
+//create local search store
+func newSearchStore(cfg *conf.Config, redisCfg *conf.RedisConfig) (face.IMetaStore, error) {
+	store, err := search.NewStore(cfg.DataPath, cfg.InterQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+//create redis store
+func newRedisStore(cfg *conf.Config, redisCfg *conf.RedisConfig) (face.IMetaStore, error) {
+	//check
+	if redisCfg == nil {
+		return nil, errors.New("redis config not assigned")
+	}
+	if redisCfg.GroupTag == "" {
+		redisCfg.GroupTag = define.DefaultRedisGroup
+	}
+	store, err := data.NewStore(redisCfg)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+//create local bolt store
+func newBoltStore(cfg *conf.Config, redisCfg *conf.RedisConfig) (face.IMetaStore, error) {
+	store, err := kv.NewStore(cfg.DataPath)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * storage stream data face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - write data from io.Reader without whole data buffer
+ * - content md5 calculated during write
+ * - read data by chunk reader
+ */
+
+//open data reader
+//used for read data without whole data buffer
+//if verify on read, checksum verified when read to the end
+func (f *Storage) OpenData(shortUrl string) (io.ReadSeekCloser, error) {
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+
+	//open with compact read locker
+	//chunk pinned until reader closed, not removed by compact
+	//access time updated after locker released
+	var accessInfo *json.FileInfoJson
+	defer func() {
+		f.touchFile(accessInfo)
+	}()
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
+	//get file info
+	fileInfo, err := f.getFileInfo(shortUrl)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo == nil || f.isFileExpired(fileInfo) {
+		return nil, define.ErrFileNotFound
+	}
+	accessInfo = fileInfo
+
+	//multipart file read by parts reader
+	if fileInfo.Parts > 0 {
+		return f.openParts(fileInfo)
+	}
+
+	//get relate chunk data
+	chunkObj, subErr := f.manager.GetChunkById(fileInfo.ChunkFileId)
+	if subErr != nil || chunkObj == nil {
+		return nil, subErr
+	}
+
+	//open chunk data reader
+	reader, subErrTwo := chunkObj.OpenReader(fileInfo.Offset)
+	if subErrTwo != nil {
+		return nil, subErrTwo
+	}
+	if f.cfg.VerifyOnRead {
+		return f.manager.wrapPinnedReader(f.wrapVerifyReader(fileInfo, reader), chunkObj.GetFileId()), nil
+	}
+	return f.manager.wrapPinnedReader(reader, chunkObj.GetFileId()), nil
+}
+
+//write new data from stream
+//return shortUrl, error
+func (f *Storage) WriteStream(
+		reader io.Reader,
+		size int64,
+		opts ...*conf.WriteOption,
+	) (string, error) {
+	var (
+		opt *conf.WriteOption
+		fileMd5 string
+		fileBaseObj *json.FileBaseJson
+		walOp = define.WalOpOfWrite
+		err error
+	)
+	//check
+	if reader == nil || size <= 0 {
+		return "", errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return "", errors.New("config didn't setup")
+	}
+	if opts != nil && len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	//detect content type by header data
+	bufReader := bufio.NewReaderSize(reader, define.ContentTypeSniffSize)
+	contentType := ""
+	if opt == nil || opt.ContentType == "" {
+		sniffSize := define.ContentTypeSniffSize
+		if size < int64(sniffSize) {
+			sniffSize = int(size)
+		}
+		sniffData, _ := bufReader.Peek(sniffSize)
+		contentType = http.DetectContentType(sniffData)
+	}
+
+	//not check same data, use rand md5 value
+	if !f.cfg.CheckSame {
+		fileMd5, err = f.genRandMd5()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	codec, err := f.getCodec(opt)
+	if err != nil {
+		return "", err
+	}
+
+	//write with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
+	//pick chunk and offset for new data
+	activeChunk, offset, spaceSize, err := f.pickChunkForWrite(size)
+	if err != nil {
+		return "", err
+	}
+
+	//write stream data into chunk
+	//calculate crc32 during write
+	crcHash := f.NewCrc32Hash()
+	teeReader := io.TeeReader(bufReader, crcHash)
+	resp := activeChunk.WriteCodecStream(fileMd5, teeReader, size, codec, offset)
+	if resp == nil {
+		return "", errors.New("can't get chunk write file response")
+	}
+	if spaceSize <= 0 && resp.BlockSize > 0 {
+		//appended at chunk tail
+		offset = resp.NewOffSet
+		spaceSize = f.getSpaceSize(resp.BlockSize)
+	}
+	if resp.Err != nil {
+		//release reserved chunk space
+		f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, 0)
+		return "", resp.Err
+	}
+
+	if f.cfg.CheckSame {
+		//check same data by content md5
+		fileMd5 = resp.Md5
+		fileBaseObj, walOp = f.getSameFileBase(fileMd5)
+		if fileBaseObj != nil {
+			//same data exists, release new written data
+			f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, 0)
+		}
+	}
+	if fileBaseObj == nil {
+		//release unused tail of picked free space
+		f.releaseWriteSpace(activeChunk.GetFileId(), offset, spaceSize, f.getSpaceSize(resp.BlockSize))
+	}
+
+	if fileBaseObj == nil {
+		//create new file base info
+		fileBaseObj = json.NewFileBaseJson()
+		fileBaseObj.Md5 = fileMd5
+		fileBaseObj.ChunkFileId = activeChunk.GetFileId()
+		fileBaseObj.Size = size
+		fileBaseObj.Offset = resp.NewOffSet
+		fileBaseObj.Blocks = resp.BlockSize
+		fileBaseObj.Crc32 = crcHash.Sum32()
+		fileBaseObj.Appoints = define.DefaultFileAppoint
+		fileBaseObj.CreateAt = time.Now().Unix()
+	}
+
+	//save file base and info
+	return f.saveNewFile(walOp, fileBaseObj, contentType, opt)
+}
@@ -0,0 +1,664 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * multipart upload face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - upload session persisted in meta store
+ * - part data written as file base without file info
+ * - completed file base point to parts, part data not copied
+ * - incomplete session expired and aborted by expire sweeper
+ */
+
+//part of multipart file reader
+type partItem struct {
+	chunk  *chunk.Chunk
+	info   *json.FileInfoJson //used for verify part data
+	offset int64 //data offset of chunk
+	begin  int64 //logical begin of whole file
+	size   int64
+}
+
+//multipart file reader
+//part reader opened when read reach it
+type partsReader struct {
+	storage *Storage //reference
+	parts   []*partItem
+	size    int64
+	pos     int64
+	reader  io.ReadSeekCloser //reader of current part
+	closed  bool
+	sync.Mutex
+}
+
+//read data
+func (r *partsReader) Read(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.closed {
+		return 0, errors.New("reader had closed")
+	}
+	for {
+		if r.pos >= r.size {
+			return 0, io.EOF
+		}
+		if r.reader == nil {
+			err := r.openPart()
+			if err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.reader.Read(p)
+		r.pos += int64(n)
+		if err == io.EOF {
+			//reach the end of current part
+			r.reader.Close()
+			r.reader = nil
+			if r.pos < r.size {
+				if n <= 0 {
+					return 0, io.ErrUnexpectedEOF
+				}
+				err = nil
+			}
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+//seek read position
+func (r *partsReader) Seek(offset int64, whence int) (int64, error) {
+	var (
+		pos int64
+	)
+	r.Lock()
+	defer r.Unlock()
+	if r.closed {
+		return 0, errors.New("reader had closed")
+	}
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("invalid whence parameter")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	if r.reader != nil {
+		//part reader opened again when read
+		r.reader.Close()
+		r.reader = nil
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+//close reader
+func (r *partsReader) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	r.closed = true
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+	return nil
+}
+
+//open part reader of current position
+func (r *partsReader) openPart() error {
+	for _, part := range r.parts {
+		if r.pos >= part.begin + part.size {
+			continue
+		}
+		reader, err := part.chunk.OpenReader(part.offset)
+		if err != nil {
+			return err
+		}
+		var partReader io.ReadSeekCloser = reader
+		if r.storage.cfg.VerifyOnRead {
+			partReader = r.storage.wrapVerifyReader(part.info, reader)
+		}
+		if r.pos > part.begin {
+			_, err = partReader.Seek(r.pos - part.begin, io.SeekStart)
+			if err != nil {
+				partReader.Close()
+				return err
+			}
+		}
+		r.reader = partReader
+		return nil
+	}
+	return io.EOF
+}
+
+//init upload session
+//return upload id, it's the short url of completed file
+func (f *Storage) InitUpload(opts ...*conf.WriteOption) (string, error) {
+	var (
+		opt *conf.WriteOption
+	)
+	//check
+	if !f.initDone {
+		return "", errors.New("config didn't setup")
+	}
+	uploadStore, err := f.getUploadStore()
+	if err != nil {
+		return "", err
+	}
+	if opts != nil && len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt != nil && opt.Compress != "" {
+		_, err = chunk.GetCodec(opt.Compress)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	//gen upload id as short url
+	uploadId, err := f.manager.GenNewShortUrl()
+	if err != nil {
+		return "", err
+	}
+
+	//create upload session
+	now := time.Now().Unix()
+	upload := json.NewUploadJson()
+	upload.UploadId = uploadId
+	if opt != nil {
+		upload.Name = opt.Name
+		upload.ContentType = opt.ContentType
+		upload.TTL = opt.TTL
+		upload.ExpireAt = opt.ExpireAt
+		upload.Compress = opt.Compress
+		if opt.Metadata != nil {
+			upload.Metadata = opt.Metadata
+		}
+	}
+	upload.CreateAt = now
+	upload.UpdateAt = now
+	err = uploadStore.PutUpload(upload)
+	return uploadId, err
+}
+
+//upload one part data
+//part number begin with 1, old data of same number replaced
+func (f *Storage) UploadPart(
+		uploadId string,
+		number int,
+		data []byte,
+	) error {
+	var (
+		partMd5 string
+		oldMd5 string
+	)
+	//check
+	if uploadId == "" || number <= 0 || number > define.UploadPartMax || len(data) <= 0 {
+		return errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return errors.New("config didn't setup")
+	}
+	uploadStore, err := f.getUploadStore()
+	if err != nil {
+		return err
+	}
+	upload, err := uploadStore.GetUpload(uploadId)
+	if err != nil {
+		return err
+	}
+	if upload == nil {
+		return define.ErrUploadNotFound
+	}
+
+	//gen part md5
+	if f.cfg.CheckSame {
+		partMd5, err = f.Md5Sum(data)
+	}else{
+		partMd5, err = f.genRandMd5()
+	}
+	if err != nil {
+		return err
+	}
+	codec, err := f.getCodec(&conf.WriteOption{Compress: upload.Compress})
+	if err != nil {
+		return err
+	}
+
+	//write with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
+	//write part data as file base
+	partBase, walOp, err := f.writeFileBase(partMd5, data, codec)
+	if err != nil {
+		return err
+	}
+	err = f.manager.GetWal().Append(walOp, "", partBase, nil)
+	if err != nil {
+		return err
+	}
+	err = f.saveFileBase(partBase)
+	if err != nil {
+		return err
+	}
+
+	//update upload session with locker
+	f.uploadLocker.Lock()
+	defer f.uploadLocker.Unlock()
+	upload, _ = uploadStore.GetUpload(uploadId)
+	if upload == nil {
+		//session aborted during write
+		f.releaseFileBase("", partBase)
+		return define.ErrUploadNotFound
+	}
+	part := json.NewUploadPartJson()
+	part.Number = number
+	part.Md5 = partBase.Md5
+	part.Size = partBase.Size
+	part.CreateAt = time.Now().Unix()
+	idx := sort.Search(len(upload.Parts), func(i int) bool {
+		return upload.Parts[i].Number >= number
+	})
+	if idx < len(upload.Parts) && upload.Parts[idx].Number == number {
+		//replace old part
+		oldMd5 = upload.Parts[idx].Md5
+		upload.Parts[idx] = part
+	}else{
+		upload.Parts = append(upload.Parts, nil)
+		copy(upload.Parts[idx + 1:], upload.Parts[idx:])
+		upload.Parts[idx] = part
+	}
+	upload.UpdateAt = part.CreateAt
+	err = uploadStore.PutUpload(upload)
+	if err != nil {
+		return err
+	}
+	if oldMd5 != "" {
+		return f.releaseParts([]string{oldMd5})
+	}
+	return nil
+}
+
+//complete upload session
+//parts should be continuous from 1
+//return short url of new file, error
+func (f *Storage) CompleteUpload(uploadId string) (string, error) {
+	//check
+	if uploadId == "" {
+		return "", errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return "", errors.New("config didn't setup")
+	}
+	uploadStore, err := f.getUploadStore()
+	if err != nil {
+		return "", err
+	}
+
+	//complete with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+	f.uploadLocker.Lock()
+	defer f.uploadLocker.Unlock()
+
+	//get upload session
+	upload, err := uploadStore.GetUpload(uploadId)
+	if err != nil {
+		return "", err
+	}
+	if upload == nil {
+		return "", define.ErrUploadNotFound
+	}
+	if len(upload.Parts) <= 0 {
+		return "", errors.New("upload without parts")
+	}
+
+	//check parts
+	size := int64(0)
+	partsMd5 := make([]string, 0)
+	for idx, part := range upload.Parts {
+		if part.Number != idx + 1 {
+			return "", fmt.Errorf("upload part %v missed", idx + 1)
+		}
+		partsMd5 = append(partsMd5, part.Md5)
+		size += part.Size
+	}
+	fileMd5, err := f.genPartsMd5(partsMd5)
+	if err != nil {
+		return "", err
+	}
+
+	//get same or create new file base
+	fileBase, _ := f.getFileBase(fileMd5)
+	isReused := fileBase != nil && !fileBase.Removed
+	if isReused {
+		fileBase.Appoints++
+	}else{
+		fileBase = json.NewFileBaseJson()
+		fileBase.Md5 = fileMd5
+		fileBase.Size = size
+		fileBase.Parts = partsMd5
+		fileBase.Appoints = define.DefaultFileAppoint
+		fileBase.CreateAt = time.Now().Unix()
+	}
+
+	//create file info
+	fileInfo := json.NewFileInfoJson()
+	fileInfo.ShortUrl = uploadId
+	fileInfo.Md5 = fileMd5
+	fileInfo.Size = size
+	fileInfo.Parts = len(partsMd5)
+	fileInfo.ContentType = f.detectPartsType(partsMd5[0])
+	fileInfo.CreateAt = time.Now().Unix()
+	fileInfo.UpdateAt = fileInfo.CreateAt
+	f.applyWriteOption(fileInfo, &conf.WriteOption{
+		Name: upload.Name,
+		ContentType: upload.ContentType,
+		Metadata: upload.Metadata,
+		TTL: upload.TTL,
+		ExpireAt: upload.ExpireAt,
+	})
+
+	//write ahead log
+	err = f.manager.GetWal().Append(define.WalOpOfWrite, uploadId, fileBase, fileInfo)
+	if err != nil {
+		return "", err
+	}
+
+	//save file base and info, del session
+	err = f.saveFile(fileBase, fileInfo)
+	if err != nil {
+		return "", err
+	}
+	err = uploadStore.DelUpload(uploadId)
+	if err != nil {
+		return uploadId, err
+	}
+	if isReused {
+		//parts kept by same file base
+		err = f.releaseParts(partsMd5)
+	}
+	return uploadId, err
+}
+
+//abort upload session
+//uploaded parts released
+func (f *Storage) AbortUpload(uploadId string) error {
+	//check
+	if uploadId == "" {
+		return errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return errors.New("config didn't setup")
+	}
+	uploadStore, err := f.getUploadStore()
+	if err != nil {
+		return err
+	}
+
+	//abort with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+	f.uploadLocker.Lock()
+	defer f.uploadLocker.Unlock()
+
+	//get upload session
+	upload, err := uploadStore.GetUpload(uploadId)
+	if err != nil {
+		return err
+	}
+	if upload == nil {
+		return define.ErrUploadNotFound
+	}
+
+	//del session and release parts
+	err = uploadStore.DelUpload(uploadId)
+	if err != nil {
+		return err
+	}
+	partsMd5 := make([]string, 0)
+	for _, part := range upload.Parts {
+		partsMd5 = append(partsMd5, part.Md5)
+	}
+	return f.releaseParts(partsMd5)
+}
+
+/////////////////
+//private func
+/////////////////
+
+//abort expired upload sessions
+//return aborted sessions, error
+func (f *Storage) sweepUploads() (int, error) {
+	var (
+		aborted int
+	)
+	uploadStore, ok := f.store.(face.IMetaUploadStore)
+	if !ok {
+		return 0, nil
+	}
+	expireSeconds := f.cfg.UploadExpireSeconds
+	if expireSeconds <= 0 {
+		expireSeconds = define.DefaultUploadExpireSeconds
+	}
+
+	//pick expired sessions
+	expireAt := time.Now().Unix() - expireSeconds
+	uploadIds := make([]string, 0)
+	for page := define.DefaultPage; ; page++ {
+		_, uploads, err := uploadStore.ListUploads(page, define.FileQueryScanSize)
+		if err != nil {
+			return 0, err
+		}
+		for _, upload := range uploads {
+			if upload.UpdateAt <= expireAt {
+				uploadIds = append(uploadIds, upload.UploadId)
+			}
+		}
+		if len(uploads) < define.FileQueryScanSize {
+			break
+		}
+	}
+
+	//abort sessions
+	for _, uploadId := range uploadIds {
+		err := f.AbortUpload(uploadId)
+		if err != nil {
+			log.Printf("storage.sweepUploads, abort %v failed, err:%v\n", uploadId, err.Error())
+			continue
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+//get meta store with upload session
+func (f *Storage) getUploadStore() (face.IMetaUploadStore, error) {
+	uploadStore, ok := f.store.(face.IMetaUploadStore)
+	if !ok {
+		return nil, fmt.Errorf("meta store %v not support upload", f.storeName)
+	}
+	return uploadStore, nil
+}
+
+//release part file base
+//should be called with compact read locker
+func (f *Storage) releaseParts(partsMd5 []string) error {
+	var (
+		err error
+	)
+	for _, partMd5 := range partsMd5 {
+		partBase, _ := f.getFileBase(partMd5)
+		if partBase == nil || partBase.Removed {
+			continue
+		}
+		subErr := f.releaseFileBase("", partBase)
+		if subErr != nil && err == nil {
+			err = subErr
+		}
+	}
+	return err
+}
+
+//gen md5 of multipart file
+//format: {md5 of parts md5}-{parts}
+func (f *Storage) genPartsMd5(partsMd5 []string) (string, error) {
+	md5Val, err := f.Md5Sum([]byte(strings.Join(partsMd5, "")))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(define.UploadMd5Para, md5Val, len(partsMd5)), nil
+}
+
+//detect content type by header data of first part
+func (f *Storage) detectPartsType(partMd5 string) string {
+	var (
+		sniffData []byte
+	)
+	partBase, _ := f.getFileBase(partMd5)
+	if partBase != nil {
+		chunkObj, _ := f.manager.GetChunkById(partBase.ChunkFileId)
+		if chunkObj != nil {
+			sniffData, _ = chunkObj.ReadRange(partBase.Offset, 0, define.ContentTypeSniffSize)
+		}
+	}
+	return http.DetectContentType(sniffData)
+}
+
+//get parts file base of multipart file
+func (f *Storage) getPartsBase(
+		fileInfo *json.FileInfoJson,
+	) ([]*json.FileBaseJson, error) {
+	fileBase, _ := f.getFileBase(fileInfo.Md5)
+	if fileBase == nil || fileBase.Removed {
+		return nil, errors.New("can't get file base info")
+	}
+	result := make([]*json.FileBaseJson, 0)
+	for _, partMd5 := range fileBase.Parts {
+		partBase, _ := f.getFileBase(partMd5)
+		if partBase == nil || partBase.Removed {
+			return nil, fmt.Errorf("can't get part %v file base info", partMd5)
+		}
+		result = append(result, partBase)
+	}
+	return result, nil
+}
+
+//get part info used for verify
+func (f *Storage) genPartInfo(
+		fileInfo *json.FileInfoJson,
+		partBase *json.FileBaseJson,
+	) *json.FileInfoJson {
+	partInfo := json.NewFileInfoJson()
+	partInfo.ShortUrl = fileInfo.ShortUrl
+	partInfo.Md5 = partBase.Md5
+	partInfo.Crc32 = partBase.Crc32
+	return partInfo
+}
+
+//read data range of multipart file
+//should be called with compact read locker
+func (f *Storage) readParts(
+		fileInfo *json.FileInfoJson,
+		offset, length int64,
+	) ([]byte, error) {
+	//check
+	if offset >= fileInfo.Size || length <= 0 {
+		return nil, errors.New("offset exceed data size")
+	}
+	partsBase, err := f.getPartsBase(fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	//read data of parts in range
+	result := make([]byte, 0, length)
+	begin := int64(0)
+	for _, partBase := range partsBase {
+		end := begin + partBase.Size
+		if length > 0 && offset < end {
+			start := offset - begin
+			readLen := partBase.Size - start
+			if readLen > length {
+				readLen = length
+			}
+			chunkObj, subErr := f.manager.GetChunkById(partBase.ChunkFileId)
+			if subErr != nil || chunkObj == nil {
+				return nil, errors.New("can't get part chunk")
+			}
+			data, subErr := chunkObj.ReadRange(partBase.Offset, start, readLen)
+			if subErr != nil {
+				return nil, subErr
+			}
+			if f.cfg.VerifyOnRead && start == 0 && int64(len(data)) == partBase.Size {
+				subErr = f.verifyData(f.genPartInfo(fileInfo, partBase), data)
+				if subErr != nil {
+					return nil, subErr
+				}
+			}
+			result = append(result, data...)
+			offset += int64(len(data))
+			length -= int64(len(data))
+		}
+		begin = end
+	}
+	return result, nil
+}
+
+//open reader of multipart file
+//should be called with compact read locker
+func (f *Storage) openParts(
+		fileInfo *json.FileInfoJson,
+	) (io.ReadSeekCloser, error) {
+	partsBase, err := f.getPartsBase(fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	reader := &partsReader{
+		storage: f,
+		parts: []*partItem{},
+	}
+	chunkIds := make([]int64, 0)
+	for _, partBase := range partsBase {
+		chunkObj, subErr := f.manager.GetChunkById(partBase.ChunkFileId)
+		if subErr != nil || chunkObj == nil {
+			return nil, errors.New("can't get part chunk")
+		}
+		reader.parts = append(reader.parts, &partItem{
+			chunk: chunkObj,
+			info: f.genPartInfo(fileInfo, partBase),
+			offset: partBase.Offset,
+			begin: reader.size,
+			size: partBase.Size,
+		})
+		reader.size += partBase.Size
+		chunkIds = append(chunkIds, partBase.ChunkFileId)
+	}
+	return f.manager.wrapPinnedReader(reader, chunkIds...), nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * data checksum verify face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - use crc32c of file info first
+ * - use content md5 if check same data
+ * - only verify whole data read
+ * - multipart file verify every part
+ */
+
+//verify reader
+//verify checksum when sequential read reach the end
+type verifyReader struct {
+	*chunk.Reader
+	shortUrl  string
+	expect    string
+	hash      hash.Hash
+	verifying bool
+}
+
+//read data
+func (r *verifyReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if !r.verifying {
+		return n, err
+	}
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		//reach the end, check checksum
+		r.verifying = false
+		actual := fmt.Sprintf("%x", r.hash.Sum(nil))
+		if actual != r.expect {
+			return n, &define.DataCorruptedError{
+				ShortUrl: r.shortUrl,
+				Expect: r.expect,
+				Actual: actual,
+			}
+		}
+	}
+	return n, err
+}
+
+//seek read position
+//only verify when read from the beginning
+func (r *verifyReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.Reader.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	r.hash.Reset()
+	r.verifying = pos == 0
+	return pos, nil
+}
+
+/////////////////
+//private func
+/////////////////
+
+//wrap chunk reader with checksum verify
+func (f *Storage) wrapVerifyReader(
+		fileInfo *json.FileInfoJson,
+		reader *chunk.Reader,
+	) io.ReadSeekCloser {
+	checkHash, expect := f.getChecksumHash(fileInfo)
+	if checkHash == nil {
+		return reader
+	}
+	return &verifyReader{
+		Reader: reader,
+		shortUrl: fileInfo.ShortUrl,
+		expect: expect,
+		hash: checkHash,
+		verifying: true,
+	}
+}
+
+//verify whole file data
+func (f *Storage) verifyData(
+		fileInfo *json.FileInfoJson,
+		data []byte,
+	) error {
+	checkHash, expect := f.getChecksumHash(fileInfo)
+	if checkHash == nil {
+		return nil
+	}
+	checkHash.Write(data)
+	actual := fmt.Sprintf("%x", checkHash.Sum(nil))
+	if actual != expect {
+		return &define.DataCorruptedError{
+			ShortUrl: fileInfo.ShortUrl,
+			Expect: expect,
+			Actual: actual,
+		}
+	}
+	return nil
+}
+
+//get checksum hash and expect value
+//return nil if no checksum can be used
+func (f *Storage) getChecksumHash(
+		fileInfo *json.FileInfoJson,
+	) (hash.Hash, string) {
+	if fileInfo.Parts > 0 {
+		//multipart file verified by parts
+		return nil, ""
+	}
+	if fileInfo.Crc32 > 0 {
+		//use crc32c
+		return f.NewCrc32Hash(), fmt.Sprintf("%08x", fileInfo.Crc32)
+	}
+	if f.cfg.CheckSame {
+		//use content md5
+		return md5.New(), fileInfo.Md5
+	}
+	return nil, ""
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * file versioning face
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - versions persisted in meta store, created when the first data replaced
+ * - overwrite data copied on write, old file info kept as noncurrent version
+ * - delete file leave delete marker, file info kept as noncurrent version
+ * - file base of noncurrent version kept by appoint value, not removed until versions purged
+ */
+
+//list versions of file
+//sort by create time desc, the first is latest
+func (f *Storage) ListVersions(shortUrl string) ([]*json.FileVersionJson, error) {
+	//check
+	if shortUrl == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	versionStore, err := f.getVersionStore()
+	if err != nil {
+		return nil, err
+	}
+
+	//list with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+	f.versionLocker.Lock()
+	defer f.versionLocker.Unlock()
+
+	//get file versions
+	fileInfo, _ := f.getFileInfo(shortUrl)
+	versions, err := f.loadVersions(versionStore, shortUrl, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	if versions == nil {
+		return nil, define.ErrFileNotFound
+	}
+
+	//format result
+	result := make([]*json.FileVersionJson, 0, len(versions.Versions))
+	for i := len(versions.Versions) - 1; i >= 0; i-- {
+		result = append(result, versions.Versions[i])
+	}
+	result[0].IsLatest = true
+	return result, nil
+}
+
+//read data of assigned version
+func (f *Storage) ReadVersion(
+		shortUrl string,
+		versionId string,
+	) ([]byte, error) {
+	//check
+	if shortUrl == "" || versionId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return nil, errors.New("config didn't setup")
+	}
+	versionStore, err := f.getVersionStore()
+	if err != nil {
+		return nil, err
+	}
+
+	//read with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+
+	//get file info of version
+	f.versionLocker.Lock()
+	fileInfo, _ := f.getFileInfo(shortUrl)
+	versions, err := f.loadVersions(versionStore, shortUrl, fileInfo)
+	f.versionLocker.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	version := f.findVersion(versions, versionId)
+	if version == nil {
+		return nil, define.ErrVersionNotFound
+	}
+	if version.DeleteMarker || version.Info == nil {
+		return nil, define.ErrFileNotFound
+	}
+
+	//read version data
+	//location synced, file base may be compacted
+	f.syncFileLocation(version.Info)
+	return f.readFileData(version.Info, 0, version.Info.Size)
+}
+
+//restore assigned version as current file
+//current file kept as noncurrent version
+func (f *Storage) RestoreVersion(
+		shortUrl string,
+		versionId string,
+	) error {
+	//check
+	if shortUrl == "" || versionId == "" {
+		return errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return errors.New("config didn't setup")
+	}
+	versionStore, err := f.getVersionStore()
+	if err != nil {
+		return err
+	}
+
+	//restore with compact read locker
+	//file base updated with locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+	f.searchLocker.Lock()
+	defer f.searchLocker.Unlock()
+	f.versionLocker.Lock()
+	defer f.versionLocker.Unlock()
+
+	//get file versions
+	fileInfo, _ := f.getFileInfo(shortUrl)
+	versions, err := f.loadVersions(versionStore, shortUrl, fileInfo)
+	if err != nil {
+		return err
+	}
+	version := f.findVersion(versions, versionId)
+	if version == nil {
+		return define.ErrVersionNotFound
+	}
+	if version.DeleteMarker || version.Info == nil {
+		return errors.New("delete marker can't be restored")
+	}
+	if fileInfo != nil && version == versions.Versions[len(versions.Versions) - 1] {
+		//already the latest
+		return nil
+	}
+
+	//file base shared by restored file info
+	fileBase, _ := f.getFileBase(version.Info.Md5)
+	if fileBase == nil || fileBase.Removed {
+		return errors.New("can't get file base info")
+	}
+	fileBase.Appoints++
+
+	//restore file info of version
+	restoreInfo := *version.Info
+	restoreInfo.UpdateAt = time.Now().Unix()
+	f.syncFileLocation(&restoreInfo)
+
+	//write ahead log
+	err = f.manager.GetWal().Append(define.WalOpOfWrite, shortUrl, fileBase, &restoreInfo)
+	if err != nil {
+		return err
+	}
+	err = f.saveFile(fileBase, &restoreInfo)
+	if err != nil {
+		return err
+	}
+
+	//add restored version
+	//old file base kept by noncurrent version
+	f.appendVersion(versions, &restoreInfo)
+	return versionStore.PutVersions(versions)
+}
+
+//purge noncurrent versions of file
+//file base of purged versions released
+//return purged count, error
+func (f *Storage) PurgeVersions(shortUrl string) (int, error) {
+	//check
+	if shortUrl == "" {
+		return 0, errors.New("invalid parameter")
+	}
+	if !f.initDone {
+		return 0, errors.New("config didn't setup")
+	}
+	versionStore, err := f.getVersionStore()
+	if err != nil {
+		return 0, err
+	}
+
+	//purge with compact read locker
+	f.manager.compactLocker.RLock()
+	defer f.manager.compactLocker.RUnlock()
+	f.versionLocker.Lock()
+	defer f.versionLocker.Unlock()
+
+	//get file versions
+	fileInfo, _ := f.getFileInfo(shortUrl)
+	versions, err := f.loadVersions(versionStore, shortUrl, fileInfo)
+	if err != nil {
+		return 0, err
+	}
+	if versions == nil {
+		return 0, define.ErrFileNotFound
+	}
+
+	//pick noncurrent versions
+	//the latest is current file info if file not deleted
+	latest := versions.Versions[len(versions.Versions) - 1]
+	isLive := fileInfo != nil && !latest.DeleteMarker
+	purged := len(versions.Versions)
+	if isLive {
+		purged--
+	}
+	if purged <= 0 {
+		return 0, nil
+	}
+	releaseMd5s := make([]string, 0)
+	for _, version := range versions.Versions {
+		if isLive && version == latest {
+			continue
+		}
+		if version.DeleteMarker || version.Info == nil {
+			continue
+		}
+		releaseMd5s = append(releaseMd5s, version.Info.Md5)
+	}
+
+	//update versions before release
+	//space leaked is better than data lost
+	if isLive {
+		versions.Versions = []*json.FileVersionJson{latest}
+		err = versionStore.PutVersions(versions)
+	}else{
+		err = versionStore.DelVersions(shortUrl)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return purged, f.releaseParts(releaseMd5s)
+}
+
+/////////////////
+//private func
+/////////////////
+
+//get meta store with file versions
+func (f *Storage) getVersionStore() (face.IMetaVersionStore, error) {
+	versionStore, ok := f.store.(face.IMetaVersionStore)
+	if !ok {
+		return nil, fmt.Errorf("meta store %v not support version", f.storeName)
+	}
+	return versionStore, nil
+}
+
+//add new version when file replaced or deleted
+//file base of old file info kept by version
+//new file info nil means delete marker
+//should be called with compact read locker
+func (f *Storage) addVersion(
+		oldInfo *json.FileInfoJson,
+		newInfo *json.FileInfoJson,
+	) error {
+	versionStore, err := f.getVersionStore()
+	if err != nil {
+		return err
+	}
+
+	//update with locker
+	f.versionLocker.Lock()
+	defer f.versionLocker.Unlock()
+	versions, err := f.loadVersions(versionStore, oldInfo.ShortUrl, oldInfo)
+	if err != nil {
+		return err
+	}
+	f.appendVersion(versions, newInfo)
+	return versionStore.PutVersions(versions)
+}
+
+//append new version
+//new file info nil means delete marker
+func (f *Storage) appendVersion(
+		versions *json.FileVersionsJson,
+		fileInfo *json.FileInfoJson,
+	) {
+	version := json.NewFileVersionJson()
+	version.VersionId = strconv.FormatInt(versions.NextId, 10)
+	version.Info = fileInfo
+	version.DeleteMarker = fileInfo == nil
+	version.CreateAt = time.Now().Unix()
+	versions.Versions = append(versions.Versions, version)
+	versions.NextId++
+}
+
+//load versions of file
+//current file info synced into the latest version
+//file without versions got the first version of current file info
+//return nil if file not found
+func (f *Storage) loadVersions(
+		versionStore face.IMetaVersionStore,
+		shortUrl string,
+		fileInfo *json.FileInfoJson,
+	) (*json.FileVersionsJson, error) {
+	versions, err := versionStore.GetVersions(shortUrl)
+	if err != nil {
+		return nil, err
+	}
+	if versions == nil || len(versions.Versions) <= 0 {
+		if fileInfo == nil {
+			return nil, nil
+		}
+		//the first version of file
+		version := json.NewFileVersionJson()
+		version.VersionId = strconv.Itoa(define.VersionIdOfFirst)
+		version.Info = fileInfo
+		version.CreateAt = fileInfo.CreateAt
+		versions = json.NewFileVersionsJson()
+		versions.ShortUrl = shortUrl
+		versions.NextId = define.VersionIdOfFirst + 1
+		versions.Versions = append(versions.Versions, version)
+		versions.CreateAt = time.Now().Unix()
+		return versions, nil
+	}
+
+	//sync current file info
+	latest := versions.Versions[len(versions.Versions) - 1]
+	if fileInfo != nil && !latest.DeleteMarker {
+		latest.Info = fileInfo
+	}
+	return versions, nil
+}
+
+//find assigned version
+func (f *Storage) findVersion(
+		versions *json.FileVersionsJson,
+		versionId string,
+	) *json.FileVersionJson {
+	if versions == nil {
+		return nil
+	}
+	for _, version := range versions.Versions {
+		if version.VersionId == versionId {
+			return version
+		}
+	}
+	return nil
+}
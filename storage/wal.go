@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/utils"
+)
+
+/*
+ * meta data write-ahead log
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - append one entry before apply write/overwrite/delete/reuse-removed
+ * - entry keep file base and info after operate, replay is redo
+ * - entry format: data size(4 bytes) + crc32(4 bytes) + gob data
+ * - torn tail entry dropped when replay
+ * - truncated when gob snapshots saved, by size or age threshold
+ */
+
+//face info
+type Wal struct {
+	cfg      *conf.Config //reference
+	file     *os.File
+	filePath string
+	seq      int64
+	size     int64
+	firstAt  int64 //time of first entry after checkpoint
+	utils.Utils
+	sync.Mutex
+}
+
+//init
+func init() {
+	gob.Register(&json.WalEntryJson{})
+}
+
+//construct
+func NewWal() *Wal {
+	this := &Wal{}
+	return this
+}
+
+//quit
+func (f *Wal) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+//append one entry, synced into disk
+func (f *Wal) Append(
+		op int,
+		shortUrl string,
+		fileBase *json.FileBaseJson,
+		fileInfo *json.FileInfoJson,
+	) error {
+	//check
+	if op <= 0 || fileBase == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//append with locker
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil {
+		return errors.New("wal file not opened")
+	}
+	f.seq++
+	entry := json.NewWalEntryJson()
+	entry.Seq = f.seq
+	entry.Op = op
+	entry.ShortUrl = shortUrl
+	entry.FileBase = fileBase
+	entry.FileInfo = fileInfo
+	entry.CreateAt = time.Now().Unix()
+
+	//encode entry data
+	buff := bytes.NewBuffer(make([]byte, define.WalEntryHeadSize))
+	err := gob.NewEncoder(buff).Encode(entry)
+	if err != nil {
+		return err
+	}
+	entryData := buff.Bytes()
+	dataSize := len(entryData) - define.WalEntryHeadSize
+	if dataSize > define.WalEntryMaxSize {
+		return errors.New("wal entry size exceed max size")
+	}
+	binary.BigEndian.PutUint32(entryData[0:4], uint32(dataSize))
+	binary.BigEndian.PutUint32(entryData[4:8], crc32.ChecksumIEEE(entryData[define.WalEntryHeadSize:]))
+
+	//write and sync
+	_, err = f.file.WriteAt(entryData, f.size)
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if err != nil {
+		return err
+	}
+	if f.size <= 0 {
+		f.firstAt = entry.CreateAt
+	}
+	f.size += int64(len(entryData))
+	return nil
+}
+
+//replay all entries in order
+//torn tail entry dropped
+//return replayed entries, error
+func (f *Wal) Replay(cb func(entry *json.WalEntryJson) error) (int, error) {
+	//check
+	if cb == nil {
+		return 0, errors.New("invalid parameter")
+	}
+
+	//replay with locker
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil {
+		return 0, errors.New("wal file not opened")
+	}
+	var (
+		offset int64
+		replayed int
+	)
+	head := make([]byte, define.WalEntryHeadSize)
+	for {
+		_, err := f.file.ReadAt(head, offset)
+		if err != nil {
+			break
+		}
+		dataSize := int64(binary.BigEndian.Uint32(head[0:4]))
+		if dataSize <= 0 || dataSize > define.WalEntryMaxSize {
+			break
+		}
+		entryData := make([]byte, dataSize)
+		_, err = f.file.ReadAt(entryData, offset + define.WalEntryHeadSize)
+		if err != nil || crc32.ChecksumIEEE(entryData) != binary.BigEndian.Uint32(head[4:8]) {
+			break
+		}
+		entry := json.NewWalEntryJson()
+		err = gob.NewDecoder(bytes.NewReader(entryData)).Decode(&entry)
+		if err != nil {
+			break
+		}
+
+		//redo entry
+		err = cb(entry)
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+		offset += define.WalEntryHeadSize + dataSize
+		if entry.Seq > f.seq {
+			f.seq = entry.Seq
+		}
+	}
+
+	//drop torn tail
+	if offset < f.size {
+		err := f.truncate(offset)
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+//checkpoint, remove all entries
+//should be called when snapshots saved and no operate running
+func (f *Wal) Checkpoint() error {
+	f.Lock()
+	defer f.Unlock()
+	if f.file == nil || f.size <= 0 {
+		return nil
+	}
+	return f.truncate(0)
+}
+
+//get wal size
+func (f *Wal) GetSize() int64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.size
+}
+
+//check checkpoint is due or not
+//wal size or age of first entry exceed threshold
+func (f *Wal) IsCheckpointDue() bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.size <= 0 {
+		return false
+	}
+	return f.size >= define.WalCheckpointSize ||
+		time.Now().Unix() - f.firstAt >= define.WalCheckpointSeconds
+}
+
+//set config
+func (f *Wal) SetConfig(cfg *conf.Config) error {
+	//check
+	if cfg == nil {
+		return errors.New("invalid parameter")
+	}
+	if f.file != nil {
+		return errors.New("wal file had opened")
+	}
+	f.cfg = cfg
+
+	//format file root path
+	rootPath := fmt.Sprintf("%v/%v", cfg.DataPath, define.SubDirOfFile)
+	err := f.CheckDir(rootPath)
+	if err != nil {
+		return err
+	}
+	f.filePath = fmt.Sprintf("%v/%v", rootPath, define.ChunksWalFile)
+
+	//open wal file
+	file, err := os.OpenFile(f.filePath, os.O_RDWR|os.O_CREATE, define.FilePerm)
+	if err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.Lock()
+	f.file = file
+	f.size = size
+	f.firstAt = time.Now().Unix()
+	f.Unlock()
+	return nil
+}
+
+////////////////
+//private func
+////////////////
+
+//truncate wal file without locker
+func (f *Wal) truncate(size int64) error {
+	err := f.file.Truncate(size)
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if err != nil {
+		return err
+	}
+	f.size = size
+	return nil
+}
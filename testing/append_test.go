@@ -0,0 +1,153 @@
+package testing
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * append data testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test append in place and linked
+func TestAppend(t *testing.T) {
+	p := OpenBoltPond(t, t.TempDir(), nil)
+
+	//check file data and parts
+	checkData := func(shortUrl string, expect []byte, parts int) {
+		fileInfo, subErr := p.Stat(shortUrl)
+		if subErr != nil || fileInfo.Size != int64(len(expect)) || fileInfo.Parts != parts {
+			t.Fatalf("file info not matched, info:%+v, err:%v", fileInfo, subErr)
+		}
+		data, subErr := p.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(data, expect) {
+			t.Fatalf("read data not matched, data:%s, err:%v", data, subErr)
+		}
+		reader, subErr := p.Open(shortUrl)
+		if subErr != nil {
+			t.Fatalf("open data failed, err:%v", subErr)
+		}
+		data, subErr = io.ReadAll(reader)
+		reader.Close()
+		if subErr != nil || !bytes.Equal(data, expect) {
+			t.Fatalf("reader data not matched, data:%s, err:%v", data, subErr)
+		}
+	}
+
+	//append at chunk tail, extended in place
+	expect := []byte("log line one\n")
+	shortUrl, err := p.WriteData(expect)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	oldInfo, _ := p.Stat(shortUrl)
+	bigLine := bytes.Repeat([]byte("log line two\n"), 20)
+	size, err := p.Append(shortUrl, bigLine)
+	expect = append(expect, bigLine...)
+	if err != nil || size != int64(len(expect)) {
+		t.Fatalf("append data failed, size:%v, err:%v", size, err)
+	}
+	checkData(shortUrl, expect, 0)
+	newInfo, _ := p.Stat(shortUrl)
+	if newInfo.ChunkFileId != oldInfo.ChunkFileId || newInfo.Offset != oldInfo.Offset {
+		t.Fatalf("data should be extended in place, old:%+v, new:%+v", oldInfo, newInfo)
+	}
+
+	//append not at chunk tail, linked as parts
+	_, err = p.WriteData([]byte("other data"))
+	if err != nil {
+		t.Fatalf("write other data failed, err:%v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = p.Append(shortUrl, bigLine)
+		if err != nil {
+			t.Fatalf("append linked data failed, err:%v", err)
+		}
+		expect = append(expect, bigLine...)
+		checkData(shortUrl, expect, i + 2)
+	}
+
+	//append with free space after data, extended in place
+	freeUrl, _ := p.WriteData([]byte("free line one\n"))
+	nextUrl, _ := p.WriteData(bytes.Repeat([]byte("next data"), 100))
+	p.WriteData([]byte("tail data"))
+	err = p.DelData(nextUrl)
+	if err != nil {
+		t.Fatalf("delete next data failed, err:%v", err)
+	}
+	_, err = p.Append(freeUrl, bigLine)
+	if err != nil {
+		t.Fatalf("append data with free space failed, err:%v", err)
+	}
+	checkData(freeUrl, append([]byte("free line one\n"), bigLine...), 0)
+
+	//delete appended file
+	err = p.DelData(shortUrl)
+	if err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+	_, err = p.Append(shortUrl, bigLine)
+	if err != define.ErrFileNotFound {
+		t.Fatalf("append deleted file should failed, err:%v", err)
+	}
+}
+
+//test append prepare run before data written
+//failed prepare keep the old data
+func TestAppendPrepare(t *testing.T) {
+	dataPath := t.TempDir()
+	os.MkdirAll(fmt.Sprintf("%v/%v", dataPath, define.SubDirOfFile), define.FilePerm)
+	cfg := &conf.Config{
+		DataPath: dataPath,
+		ChunkBlockSize: define.DefaultChunkBlockSize,
+	}
+	chunkObj := chunk.NewChunk(1, cfg)
+	defer chunkObj.Quit()
+	data := []byte("append prepare data")
+	resp := chunkObj.WriteFile(fmt.Sprintf("%x", md5.Sum(data)), data)
+	if resp.Err != nil {
+		t.Fatalf("write chunk data failed, err:%v", resp.Err)
+	}
+	readRecord := func() []byte {
+		reader, err := chunkObj.OpenReader(resp.NewOffSet)
+		if err != nil {
+			t.Fatalf("open chunk reader failed, err:%v", err)
+		}
+		defer reader.Close()
+		readData, _ := io.ReadAll(reader)
+		return readData
+	}
+
+	//failed prepare, nothing written
+	more := []byte("|more")
+	appendResp := chunkObj.AppendRecord(resp.NewOffSet, more, 0, func(blocks int64) error {
+		return errors.New("prepare failed")
+	})
+	if appendResp.Err == nil || !bytes.Equal(readRecord(), data) {
+		t.Fatalf("data should not be changed by failed prepare")
+	}
+
+	//prepare with new blocks
+	var prepared int64
+	appendResp = chunkObj.AppendRecord(resp.NewOffSet, more, 0, func(blocks int64) error {
+		prepared = blocks
+		return nil
+	})
+	if appendResp.Err != nil || prepared != appendResp.BlockSize {
+		t.Fatalf("append prepare not matched, blocks:%v, err:%v", prepared, appendResp.Err)
+	}
+	if !bytes.Equal(readRecord(), append(data, more...)) {
+		t.Fatalf("appended data not matched")
+	}
+}
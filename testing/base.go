@@ -1,22 +1,25 @@
 package testing
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"sync"
+	"testing"
 
 	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
 )
 
 const (
 	RedisAddr = "127.0.0.1:6379"
-	DataDir = "../private"
+	DataDirPattern = "pond-testing-*"
 	ShortUrl = "4swdwl"
 )
 
 var (
 	p *pond.Pond
+	dataDir string //data dir of single pond instance
 	_once sync.Once
 )
 
@@ -34,18 +37,58 @@ func GetPond() *pond.Pond {
 	return p
 }
 
+//open local pond of assigned data path
+//file info storage in local search, pond quit when test done
+func OpenLocalPond(t *testing.T, dataPath string) *pond.Pond {
+	pObj := pond.NewPond()
+	cfg := pObj.GenConfig()
+	cfg.DataPath = dataPath
+	cfg.FixedBlockSize = true
+	cfg.CheckSame = true
+	cfg.VerifyOnRead = true
+	err := pObj.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+	t.Cleanup(pObj.Quit)
+	return pObj
+}
+
+//open bolt pond of assigned data path
+//config changed by modifier, pond quit when test done
+func OpenBoltPond(
+		t *testing.T,
+		dataPath string,
+		modifier func(cfg *conf.Config),
+	) *pond.Pond {
+	pObj := pond.NewPond()
+	cfg := pObj.GenConfig()
+	cfg.DataPath = dataPath
+	cfg.MetaStore = define.MetaStoreOfBolt
+	cfg.VerifyOnRead = true
+	if modifier != nil {
+		modifier(cfg)
+	}
+	err := pObj.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+	t.Cleanup(pObj.Quit)
+	return pObj
+}
+
 //init pond
 func initPond() (*pond.Pond, error) {
 	//init face
 	log.Printf("init pond...\n")
 	pObj := pond.NewPond()
 
-	//get current path
-	curPath, err := os.Getwd()
+	//data path in temp dir
+	dataPath, err := os.MkdirTemp("", DataDirPattern)
 	if err != nil {
 		return nil, err
 	}
-	dataPath := fmt.Sprintf("%v/%v", curPath, DataDir)
+	dataDir = dataPath
 
 	//set config
 	cfg := pObj.GenConfig()
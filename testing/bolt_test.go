@@ -0,0 +1,76 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/kv"
+	"github.com/andyzhou/pond/storage"
+)
+
+/*
+ * bolt meta store testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test pond with bolt meta store and migrate
+func TestBoltStore(t *testing.T) {
+	dataPath := t.TempDir()
+	p := pond.NewPond()
+	cfg := p.GenConfig()
+	cfg.DataPath = dataPath
+	cfg.MetaStore = define.MetaStoreOfBolt
+	err := p.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+
+	//read after write
+	shortUrls := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		data := []byte(fmt.Sprintf("bolt-%v-%v", i, time.Now().UnixNano()))
+		shortUrl, subErr := p.WriteData(data)
+		if subErr != nil {
+			t.Fatalf("write data failed, err:%v", subErr)
+		}
+		readData, subErr := p.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(readData, data) {
+			t.Fatalf("read data not matched, err:%v", subErr)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+	err = p.DelData(shortUrls[0])
+	if err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+	total, filesInfo, err := p.GetFiles(1, 10)
+	if err != nil || total != 2 || len(filesInfo) != 2 {
+		t.Fatalf("get files not matched, total:%v, err:%v", total, err)
+	}
+	p.Quit()
+
+	//migrate into another bolt store
+	src, err := kv.NewStore(dataPath)
+	if err != nil {
+		t.Fatalf("open bolt store failed, err:%v", err)
+	}
+	defer src.Quit()
+	dst, err := kv.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("open bolt store failed, err:%v", err)
+	}
+	defer dst.Quit()
+	result, err := storage.MigrateMeta(src, dst)
+	if err != nil || result.Infos != 2 || result.Bases != 2 || result.Removed != 1 {
+		t.Fatalf("migrate not matched, result:%v, err:%v", result, err)
+	}
+	removed, _, _ := dst.ListRemovedBase(1, 10)
+	if removed != 1 {
+		t.Fatalf("migrated removed base not matched, removed:%v", removed)
+	}
+}
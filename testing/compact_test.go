@@ -0,0 +1,236 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * compact testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test compact chunk
+func TestCompact(t *testing.T) {
+	dataPath := t.TempDir()
+	lp := OpenLocalPond(t, dataPath)
+
+	//write data, then delete most of them
+	liveData := map[string][]byte{}
+	for i := 0; i < 20; i++ {
+		data := bytes.Repeat([]byte(fmt.Sprintf("compact-%v-%v;", i, time.Now().UnixNano())), 50 + i)
+		opt := lp.GenWriteOption()
+		if i % 2 == 0 {
+			opt.Compress = define.CompressOfZstd
+		}
+		shortUrl, err := lp.WriteDataWithOption(data, opt)
+		if err != nil {
+			t.Fatalf("write data failed, err:%v", err)
+		}
+		if i % 5 == 0 {
+			liveData[shortUrl] = data
+			continue
+		}
+		err = lp.DelData(shortUrl)
+		if err != nil {
+			t.Fatalf("delete data failed, err:%v", err)
+		}
+	}
+
+	//compact chunk of live data
+	var oldChunkId int64
+	for shortUrl := range liveData {
+		fileInfo, _ := lp.Stat(shortUrl)
+		oldChunkId = fileInfo.ChunkFileId
+	}
+	results, err := lp.Compact(oldChunkId)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("compact chunk failed, err:%v", err)
+	}
+	if results[0].Objects < int64(len(liveData)) || results[0].FreedBytes <= 0 {
+		t.Fatalf("compact result not matched, result:%+v", results[0])
+	}
+	oldChunkFile := fmt.Sprintf("%v/%v/%v", dataPath, define.SubDirOfFile,
+		fmt.Sprintf(define.ChunkDataFilePara, oldChunkId))
+	if _, err = os.Stat(oldChunkFile); !os.IsNotExist(err) {
+		t.Fatalf("compacted chunk file should be removed, err:%v", err)
+	}
+
+	//live data still readable from new chunk
+	for shortUrl, data := range liveData {
+		fileInfo, _ := lp.Stat(shortUrl)
+		if fileInfo == nil || fileInfo.ChunkFileId != results[0].NewChunkId {
+			t.Fatalf("file info location not updated, info:%v", fileInfo)
+		}
+		readData, subErr := lp.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(readData, data) {
+			t.Fatalf("read compacted data not matched, err:%v", subErr)
+		}
+	}
+
+	//listed file info location synced
+	_, filesInfo, err := lp.GetFiles(1, 1000)
+	if err != nil {
+		t.Fatalf("get files failed, err:%v", err)
+	}
+	for _, fileInfo := range filesInfo {
+		if _, ok := liveData[fileInfo.ShortUrl]; ok && fileInfo.ChunkFileId != results[0].NewChunkId {
+			t.Fatalf("listed file info location not updated, info:%+v", fileInfo)
+		}
+	}
+
+	//chunk filter matched by synced location
+	query := lp.GenFileQuery()
+	query.ChunkFileId = results[0].NewChunkId
+	query.PageSize = 1000
+	_, filesInfo, err = lp.ListFiles(query)
+	if err != nil {
+		t.Fatalf("list files of chunk failed, err:%v", err)
+	}
+	matched := 0
+	for _, fileInfo := range filesInfo {
+		if _, ok := liveData[fileInfo.ShortUrl]; ok {
+			matched++
+		}
+	}
+	if matched != len(liveData) {
+		t.Fatalf("files of new chunk not matched, matched:%v", matched)
+	}
+	query.ChunkFileId = oldChunkId
+	total, _, err := lp.ListFiles(query)
+	if err != nil || total != 0 {
+		t.Fatalf("files of compacted chunk should be empty, total:%v, err:%v", total, err)
+	}
+
+	//write new data after compact
+	data := []byte(fmt.Sprintf("after-compact-%v", time.Now().UnixNano()))
+	shortUrl, err := lp.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data after compact failed, err:%v", err)
+	}
+	readData, err := lp.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(readData, data) {
+		t.Fatalf("read data after compact not matched, err:%v", err)
+	}
+}
+
+//test compact chunk of multipart file and upload part
+//live data found by file base of file info and upload session
+func TestCompactParts(t *testing.T) {
+	bp := OpenBoltPond(t, t.TempDir(), func(cfg *conf.Config) {
+		cfg.MinChunkFiles = 1
+	})
+	now := time.Now().UnixNano()
+
+	//completed multipart file
+	fileUrl, err := bp.InitUpload()
+	if err != nil {
+		t.Fatalf("init upload failed, err:%v", err)
+	}
+	fileData := make([]byte, 0)
+	for number := 1; number <= 2; number++ {
+		data := []byte(fmt.Sprintf("compact-part-%v-%v|", number, now))
+		if err = bp.UploadPart(fileUrl, number, data); err != nil {
+			t.Fatalf("upload part failed, err:%v", err)
+		}
+		fileData = append(fileData, data...)
+	}
+	if _, err = bp.CompleteUpload(fileUrl); err != nil {
+		t.Fatalf("complete upload failed, err:%v", err)
+	}
+
+	//open upload session, then delete normal data
+	uploadId, err := bp.InitUpload()
+	if err != nil {
+		t.Fatalf("init upload failed, err:%v", err)
+	}
+	partData := []byte(fmt.Sprintf("compact-open-part-%v", now))
+	if err = bp.UploadPart(uploadId, 1, partData); err != nil {
+		t.Fatalf("upload open part failed, err:%v", err)
+	}
+	shortUrl, err := bp.WriteData([]byte(fmt.Sprintf("compact-dead-%v", now)))
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	deadInfo, _ := bp.Stat(shortUrl)
+	if err = bp.DelData(shortUrl); err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+
+	//parts of file and session copied
+	results, err := bp.Compact(deadInfo.ChunkFileId)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("compact chunk failed, err:%v", err)
+	}
+	if results[0].Objects != 3 {
+		t.Fatalf("compact objects not matched, result:%+v", results[0])
+	}
+	readData, err := bp.ReadData(fileUrl)
+	if err != nil || !bytes.Equal(readData, fileData) {
+		t.Fatalf("read multipart data not matched, err:%v", err)
+	}
+	if _, err = bp.CompleteUpload(uploadId); err != nil {
+		t.Fatalf("complete open upload failed, err:%v", err)
+	}
+	readData, err = bp.ReadData(uploadId)
+	if err != nil || !bytes.Equal(readData, partData) {
+		t.Fatalf("read open upload data not matched, err:%v", err)
+	}
+}
+
+//test opened reader not broken by compact
+//compacted chunk file removed when reader closed
+func TestCompactOpenReader(t *testing.T) {
+	dataPath := t.TempDir()
+	lp := OpenLocalPond(t, dataPath)
+
+	//open reader, then delete other data
+	data := bytes.Repeat([]byte(fmt.Sprintf("compact-reader-%v;", time.Now().UnixNano())), 100)
+	shortUrl, err := lp.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	deadUrl, err := lp.WriteData([]byte(fmt.Sprintf("compact-dead-%v", time.Now().UnixNano())))
+	if err != nil {
+		t.Fatalf("write dead data failed, err:%v", err)
+	}
+	fileInfo, _ := lp.Stat(shortUrl)
+	reader, err := lp.Open(shortUrl)
+	if err != nil {
+		t.Fatalf("open reader failed, err:%v", err)
+	}
+	head := make([]byte, 10)
+	if _, err = io.ReadFull(reader, head); err != nil {
+		t.Fatalf("read head data failed, err:%v", err)
+	}
+	if err = lp.DelData(deadUrl); err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+
+	//compact chunk of opened reader
+	results, err := lp.Compact(fileInfo.ChunkFileId)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("compact chunk failed, err:%v", err)
+	}
+	tail, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(append(head, tail...), data) {
+		t.Fatalf("read opened reader after compact not matched, err:%v", err)
+	}
+	chunkFile := fmt.Sprintf("%v/%v/%v", dataPath, define.SubDirOfFile,
+		fmt.Sprintf(define.ChunkDataFilePara, fileInfo.ChunkFileId))
+	if _, err = os.Stat(chunkFile); err != nil {
+		t.Fatalf("pinned chunk file should be kept, err:%v", err)
+	}
+	reader.Close()
+	if _, err = os.Stat(chunkFile); !os.IsNotExist(err) {
+		t.Fatalf("compacted chunk file should be removed when reader closed, err:%v", err)
+	}
+}
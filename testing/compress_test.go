@@ -0,0 +1,102 @@
+package testing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * compress testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test compress write and read
+func TestCompress(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+	codecs := []string{
+		define.CompressOfZstd,
+		define.CompressOfGzip,
+		define.CompressOfSnappy,
+	}
+	for _, codec := range codecs {
+		//write compressible data
+		data := bytes.Repeat([]byte(fmt.Sprintf(`{"codec":"%v","time":%v}`, codec, time.Now().UnixNano())), 500)
+		opt := lp.GenWriteOption()
+		opt.Compress = codec
+		shortUrl, err := lp.WriteDataWithOption(data, opt)
+		if err != nil {
+			t.Fatalf("%v write data failed, err:%v", codec, err)
+		}
+		fileInfo, _ := lp.Stat(shortUrl)
+		if fileInfo == nil || fileInfo.Size != int64(len(data)) || fileInfo.Blocks >= fileInfo.Size {
+			t.Fatalf("%v data not compressed, info:%v", codec, fileInfo)
+		}
+
+		//read whole and range data
+		readData, subErr := lp.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(readData, data) {
+			t.Fatalf("%v read data not matched, err:%v", codec, subErr)
+		}
+		rangeData, subErr := lp.ReadData(shortUrl, 100, 50)
+		if subErr != nil || !bytes.Equal(rangeData, data[100:150]) {
+			t.Fatalf("%v read range data not matched, err:%v", codec, subErr)
+		}
+
+		//read by reader with seek
+		reader, subErr := lp.Open(shortUrl)
+		if subErr != nil {
+			t.Fatalf("%v open reader failed, err:%v", codec, subErr)
+		}
+		reader.Seek(1000, io.SeekStart)
+		partData := make([]byte, 20)
+		io.ReadFull(reader, partData)
+		reader.Seek(10, io.SeekStart)
+		backData := make([]byte, 20)
+		io.ReadFull(reader, backData)
+		reader.Close()
+		if !bytes.Equal(partData, data[1000:1020]) || !bytes.Equal(backData, data[10:30]) {
+			t.Fatalf("%v seek read data not matched", codec)
+		}
+
+		//write compressible stream
+		streamUrl, subErr := lp.WriteFrom(bytes.NewReader(data[1:]), int64(len(data) - 1), opt)
+		if subErr != nil {
+			t.Fatalf("%v write stream failed, err:%v", codec, subErr)
+		}
+		readData, subErr = lp.ReadData(streamUrl)
+		if subErr != nil || !bytes.Equal(readData, data[1:]) {
+			t.Fatalf("%v read stream data not matched, err:%v", codec, subErr)
+		}
+	}
+}
+
+//test compress incompressible data
+func TestCompressRawKept(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+	data := make([]byte, 4096)
+	rand.Read(data)
+	opt := lp.GenWriteOption()
+	opt.Compress = define.CompressOfZstd
+	shortUrl, err := lp.WriteFrom(bytes.NewReader(data), int64(len(data)), opt)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	readData, subErr := lp.ReadData(shortUrl, 4000, 200)
+	if subErr != nil || !bytes.Equal(readData, data[4000:]) {
+		t.Fatalf("read data not matched, err:%v", subErr)
+	}
+
+	//invalid compress
+	opt.Compress = "unknown"
+	_, err = lp.WriteDataWithOption(data, opt)
+	if err == nil {
+		t.Fatalf("write data with unknown compress should be failed")
+	}
+}
@@ -0,0 +1,128 @@
+package testing
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/crypt"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * encrypt testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+const (
+	CryptMarker  = "pond-plaintext-marker;"
+)
+
+//test encrypt chunk data
+func TestEncrypt(t *testing.T) {
+	//init chunk with key provider
+	dataPath := t.TempDir()
+	os.MkdirAll(fmt.Sprintf("%v/%v", dataPath, define.SubDirOfFile), define.FilePerm)
+	keyRing, err := crypt.NewStaticKey(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("init static key failed, err:%v", err)
+	}
+	cfg := &conf.Config{
+		DataPath: dataPath,
+		ChunkBlockSize: define.DefaultChunkBlockSize,
+		KeyProvider: keyRing,
+	}
+	chunkObj := chunk.NewChunk(1, cfg)
+	defer chunkObj.Quit()
+
+	//write data more than one encrypt segment
+	data := bytes.Repeat([]byte(CryptMarker), 5000)
+	dataMd5 := fmt.Sprintf("%x", md5.Sum(data))
+	resp := chunkObj.WriteFile(dataMd5, data)
+	if resp.Err != nil {
+		t.Fatalf("write data failed, err:%v", resp.Err)
+	}
+	readData, err := chunkObj.ReadRange(resp.NewOffSet, 0, int64(len(data)))
+	if err != nil || !bytes.Equal(readData, data) {
+		t.Fatalf("read data not matched, err:%v", err)
+	}
+	readData, err = chunkObj.ReadRange(resp.NewOffSet, 70000, 100)
+	if err != nil || !bytes.Equal(readData, data[70000:70100]) {
+		t.Fatalf("read range data not matched, err:%v", err)
+	}
+
+	//rotate key, write compressed stream
+	err = keyRing.Rotate(2, bytes.Repeat([]byte("n"), 16))
+	if err != nil {
+		t.Fatalf("rotate key failed, err:%v", err)
+	}
+	streamResp := chunkObj.WriteCodecStream("", bytes.NewReader(data), int64(len(data)), define.CodecOfZstd)
+	if streamResp.Err != nil || streamResp.Md5 != dataMd5 {
+		t.Fatalf("write stream failed, err:%v", streamResp.Err)
+	}
+	reader, err := chunkObj.OpenReader(streamResp.NewOffSet)
+	if err != nil {
+		t.Fatalf("open reader failed, err:%v", err)
+	}
+	readData, err = io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(readData, data) {
+		t.Fatalf("read stream data not matched, err:%v", err)
+	}
+
+	//data of old key still readable
+	readData, err = chunkObj.ReadRange(resp.NewOffSet, 0, int64(len(data)))
+	if err != nil || !bytes.Equal(readData, data) {
+		t.Fatalf("read old key data not matched, err:%v", err)
+	}
+
+	//chunk file on disk should contain no plain text
+	dataFile := fmt.Sprintf("%v/%v/%v", dataPath, define.SubDirOfFile,
+		fmt.Sprintf(define.ChunkDataFilePara, 1))
+	fileData, err := os.ReadFile(dataFile)
+	if err != nil {
+		t.Fatalf("read chunk file failed, err:%v", err)
+	}
+	if bytes.Contains(fileData, []byte(CryptMarker)) {
+		t.Fatalf("chunk file contains plain text")
+	}
+
+	//tamper encrypted data
+	file, _ := os.OpenFile(dataFile, os.O_RDWR, define.FilePerm)
+	file.WriteAt([]byte{fileData[resp.NewOffSet + 100] ^ 0xff}, resp.NewOffSet + 100)
+	file.Close()
+	_, err = chunkObj.ReadRange(resp.NewOffSet, 0, 10)
+	if !errors.Is(err, define.ErrDataCorrupted) {
+		t.Fatalf("read tampered data should be failed, err:%v", err)
+	}
+}
+
+//test key file provider
+func TestKeyFile(t *testing.T) {
+	keyFile := fmt.Sprintf("%v/pond.key", t.TempDir())
+	keyOne := bytes.Repeat([]byte("a"), 32)
+	keyTwo := bytes.Repeat([]byte("b"), 32)
+	content := fmt.Sprintf("#pond keys\n1:%v\n2:%v\n", hex.EncodeToString(keyOne), hex.EncodeToString(keyTwo))
+	os.WriteFile(keyFile, []byte(content), define.FilePerm)
+
+	keyRing, err := crypt.NewKeyFile(keyFile)
+	if err != nil {
+		t.Fatalf("load key file failed, err:%v", err)
+	}
+	keyId, key, _ := keyRing.CurrentKey()
+	if keyId != 2 || !bytes.Equal(key, keyTwo) {
+		t.Fatalf("current key not matched, key id:%v", keyId)
+	}
+	key, _ = keyRing.GetKey(1)
+	if !bytes.Equal(key, keyOne) {
+		t.Fatalf("old key not matched")
+	}
+}
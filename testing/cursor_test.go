@@ -0,0 +1,90 @@
+package testing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * cursor list testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+const (
+	CursorFiles    = 5
+	CursorPageSize = 2
+)
+
+//test list files by cursor with search and bolt meta store
+func TestGetFilesByCursor(t *testing.T) {
+	//search meta store
+	lp := OpenLocalPond(t, t.TempDir())
+	writeCursorFiles(t, lp)
+	checkCursorFiles(t, lp)
+
+	//bolt meta store
+	p := pond.NewPond()
+	cfg := p.GenConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.MetaStore = define.MetaStoreOfBolt
+	err := p.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+	defer p.Quit()
+	writeCursorFiles(t, p)
+	checkCursorFiles(t, p)
+}
+
+//write files for cursor list
+func writeCursorFiles(t *testing.T, p *pond.Pond) {
+	for i := 0; i < CursorFiles; i++ {
+		data := []byte(fmt.Sprintf("cursor-%v-%v", i, time.Now().UnixNano()))
+		_, err := p.WriteData(data)
+		if err != nil {
+			t.Fatalf("write data failed, err:%v", err)
+		}
+	}
+}
+
+//page all files by cursor, check no duplicate and order
+func checkCursorFiles(t *testing.T, p *pond.Pond) {
+	var (
+		cursor string
+		lastCreateAt int64
+		total int64
+	)
+	shortUrls := map[string]bool{}
+	for {
+		pageTotal, filesInfo, nextCursor, err := p.GetFilesByCursor(cursor, CursorPageSize)
+		if err != nil {
+			t.Fatalf("get files by cursor failed, err:%v", err)
+		}
+		if cursor == "" {
+			//total counted by the first page
+			total = pageTotal
+		}
+		for _, fileInfo := range filesInfo {
+			if shortUrls[fileInfo.ShortUrl] {
+				t.Fatalf("duplicate file %v in cursor list", fileInfo.ShortUrl)
+			}
+			if lastCreateAt > 0 && fileInfo.CreateAt > lastCreateAt {
+				t.Fatalf("cursor list not ordered by create time")
+			}
+			shortUrls[fileInfo.ShortUrl] = true
+			lastCreateAt = fileInfo.CreateAt
+		}
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+	if total < CursorFiles || int64(len(shortUrls)) != total {
+		t.Fatalf("cursor list not matched, total:%v, listed:%v", total, len(shortUrls))
+	}
+}
@@ -0,0 +1,94 @@
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * file expire testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test expired file not readable and swept
+func TestExpire(t *testing.T) {
+	checkExpire(t, OpenLocalPond(t, t.TempDir()))
+
+	//bolt store swept by expire index
+	p := OpenBoltPond(t, t.TempDir(), nil)
+	checkExpire(t, p)
+	checkExpireOverwrite(t, p)
+}
+
+//check expired file of pond
+func checkExpire(t *testing.T, lp *pond.Pond) {
+	//write expired and live data
+	opt := lp.GenWriteOption()
+	opt.ExpireAt = time.Now().Unix() - 1
+	expiredUrl, err := lp.WriteDataWithOption([]byte(fmt.Sprintf("expired-%v", time.Now().UnixNano())), opt)
+	if err != nil {
+		t.Fatalf("write expired data failed, err:%v", err)
+	}
+	opt = lp.GenWriteOption()
+	opt.TTL = define.SecondsOfHour
+	liveUrl, err := lp.WriteDataWithOption([]byte(fmt.Sprintf("live-%v", time.Now().UnixNano())), opt)
+	if err != nil {
+		t.Fatalf("write live data failed, err:%v", err)
+	}
+
+	//expired data not found before swept
+	_, err = lp.ReadData(expiredUrl)
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("read expired data should be not found, err:%v", err)
+	}
+	exists, _ := lp.Exists(expiredUrl)
+	if exists {
+		t.Fatalf("expired data should not exists")
+	}
+
+	//sweep expired data
+	deleted, err := lp.SweepExpired()
+	if err != nil || deleted <= 0 {
+		t.Fatalf("sweep expired failed, deleted:%v, err:%v", deleted, err)
+	}
+	_, err = lp.Stat(expiredUrl)
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("expired data not swept, err:%v", err)
+	}
+	_, err = lp.ReadData(liveUrl)
+	if err != nil {
+		t.Fatalf("read live data failed, err:%v", err)
+	}
+}
+
+//check expire index changed by overwrite
+func checkExpireOverwrite(t *testing.T, lp *pond.Pond) {
+	opt := lp.GenWriteOption()
+	opt.TTL = define.SecondsOfHour
+	liveUrl, err := lp.WriteDataWithOption([]byte(fmt.Sprintf("live-%v", time.Now().UnixNano())), opt)
+	if err != nil {
+		t.Fatalf("write live data failed, err:%v", err)
+	}
+
+	//expire time changed by overwrite
+	opt = lp.GenWriteOption()
+	opt.ExpireAt = time.Now().Unix() - 1
+	_, err = lp.WriteDataWithOption([]byte(fmt.Sprintf("overwrite-%v", time.Now().UnixNano())), opt, liveUrl)
+	if err != nil {
+		t.Fatalf("overwrite live data failed, err:%v", err)
+	}
+	deleted, err := lp.SweepExpired()
+	if err != nil || deleted <= 0 {
+		t.Fatalf("sweep overwritten data failed, deleted:%v, err:%v", deleted, err)
+	}
+	_, err = lp.Stat(liveUrl)
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("overwritten expired data not swept, err:%v", err)
+	}
+}
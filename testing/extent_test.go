@@ -0,0 +1,188 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/storage"
+)
+
+/*
+ * extent allocator testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test extent allocate, split and coalesce
+func TestExtent(t *testing.T) {
+	cfg := &conf.Config{
+		DataPath: t.TempDir(),
+	}
+	extent := storage.NewExtent()
+	if _, err := extent.SetConfig(cfg); err != nil {
+		t.Fatalf("set extent config failed, err:%v", err)
+	}
+
+	//free adjacent space, should be coalesced
+	extent.Free(1, 0, 1000, "md5-a")
+	extent.Free(1, 2000, 500, "md5-c")
+	extent.Free(1, 1000, 1000, "md5-b")
+	extent.Free(2, 0, 600, "")
+	extents := extent.GetExtents(1)
+	if len(extents) != 1 || extents[0].Size != 2500 {
+		t.Fatalf("free space not coalesced, extents:%v", len(extents))
+	}
+	if err := extent.Free(1, 100, 10, ""); err == nil {
+		t.Fatalf("double free should be failed")
+	}
+
+	//best-fit allocate and split
+	chunkId, offset, dropped, _ := extent.Alloc(500, nil)
+	if chunkId != 2 || offset != 0 || len(dropped) != 0 {
+		t.Fatalf("best-fit not matched, chunk:%v, offset:%v", chunkId, offset)
+	}
+	chunkId, offset, dropped, _ = extent.Alloc(800, nil)
+	if chunkId != 1 || offset != 0 || len(dropped) != 1 || dropped[0] != "md5-a" {
+		t.Fatalf("split allocate not matched, chunk:%v, offset:%v", chunkId, offset)
+	}
+
+	//removed data take back only when still kept
+	if extent.Take(1, 0, 1000, "md5-a") {
+		t.Fatalf("take back overwritten data should be failed")
+	}
+	if !extent.Take(1, 1000, 1000, "md5-b") {
+		t.Fatalf("take back kept data failed")
+	}
+
+	//reload from saved file
+	if err := extent.Save(); err != nil {
+		t.Fatalf("save extent failed, err:%v", err)
+	}
+	reloaded := storage.NewExtent()
+	loaded, err := reloaded.SetConfig(cfg)
+	if err != nil || !loaded {
+		t.Fatalf("reload extent failed, err:%v", err)
+	}
+	freeSize := reloaded.GetFreeSize()
+	if freeSize[1] != 200 + 500 || freeSize[2] != 100 {
+		t.Fatalf("reloaded free size not matched, size:%v", freeSize)
+	}
+
+	//occupy space across used and free space
+	dropped, err = reloaded.Occupy(1, 900, 1200)
+	if err != nil || len(dropped) != 1 || dropped[0] != "md5-c" {
+		t.Fatalf("occupy space not matched, dropped:%v, err:%v", dropped, err)
+	}
+	if freeSize = reloaded.GetFreeSize(); freeSize[1] != 100 + 400 {
+		t.Fatalf("occupied free size not matched, size:%v", freeSize)
+	}
+}
+
+//test big removed space re-used by smaller data
+func TestExtentReuse(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+
+	//write big data, then delete it
+	bigData := bytes.Repeat([]byte(fmt.Sprintf("big-%v;", time.Now().UnixNano())), 10000)
+	bigUrl, err := lp.WriteData(bigData)
+	if err != nil {
+		t.Fatalf("write big data failed, err:%v", err)
+	}
+	bigInfo, _ := lp.Stat(bigUrl)
+	err = lp.DelData(bigUrl)
+	if err != nil {
+		t.Fatalf("delete big data failed, err:%v", err)
+	}
+
+	//smaller data should use removed space
+	for i := 0; i < 3; i++ {
+		data := bytes.Repeat([]byte(fmt.Sprintf("small-%v-%v;", i, time.Now().UnixNano())), 2000)
+		shortUrl, subErr := lp.WriteData(data)
+		if subErr != nil {
+			t.Fatalf("write small data failed, err:%v", subErr)
+		}
+		fileInfo, _ := lp.Stat(shortUrl)
+		if fileInfo.ChunkFileId != bigInfo.ChunkFileId ||
+			fileInfo.Offset < bigInfo.Offset ||
+			fileInfo.Offset >= bigInfo.Offset + bigInfo.Blocks {
+			t.Fatalf("removed space not re-used, offset:%v", fileInfo.Offset)
+		}
+		readData, subErr := lp.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(readData, data) {
+			t.Fatalf("read small data not matched, err:%v", subErr)
+		}
+	}
+
+	//big data can't be taken back any more
+	newUrl, err := lp.WriteData(bigData)
+	if err != nil {
+		t.Fatalf("write big data again failed, err:%v", err)
+	}
+	readData, err := lp.ReadData(newUrl)
+	if err != nil || !bytes.Equal(readData, bigData) {
+		t.Fatalf("read big data not matched, err:%v", err)
+	}
+}
+
+//test compact walk after removed space split
+//split remainder without record header should be skipped
+func TestExtentSplitCompact(t *testing.T) {
+	bp := OpenBoltPond(t, t.TempDir(), func(cfg *conf.Config) {
+		cfg.ChunkBlockSize = 128
+		cfg.MinChunkFiles = 1
+	})
+
+	//write big and small data, then delete big data
+	genData := func(tag string, size int) []byte {
+		return bytes.Repeat([]byte(tag), size / len(tag))
+	}
+	bigUrl, err := bp.WriteData(genData("a", 600))
+	if err != nil {
+		t.Fatalf("write big data failed, err:%v", err)
+	}
+	liveData := map[string][]byte{}
+	for _, tag := range []string{"b", "c"} {
+		data := genData(tag, 10)
+		shortUrl, subErr := bp.WriteData(data)
+		if subErr != nil {
+			t.Fatalf("write small data failed, err:%v", subErr)
+		}
+		liveData[shortUrl] = data
+	}
+	bigInfo, _ := bp.Stat(bigUrl)
+	if err = bp.DelData(bigUrl); err != nil {
+		t.Fatalf("delete big data failed, err:%v", err)
+	}
+
+	//removed space split by two data
+	for _, tag := range []string{"d", "e"} {
+		data := genData(tag, 200)
+		shortUrl, subErr := bp.WriteData(data)
+		if subErr != nil {
+			t.Fatalf("write split data failed, err:%v", subErr)
+		}
+		fileInfo, _ := bp.Stat(shortUrl)
+		if fileInfo.ChunkFileId != bigInfo.ChunkFileId || fileInfo.Offset >= bigInfo.Blocks {
+			t.Fatalf("removed space not split, info:%+v", fileInfo)
+		}
+		liveData[shortUrl] = data
+	}
+
+	//all live data copied
+	results, err := bp.Compact(bigInfo.ChunkFileId)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("compact chunk failed, err:%v", err)
+	}
+	if results[0].Objects != int64(len(liveData)) {
+		t.Fatalf("compact objects not matched, result:%+v", results[0])
+	}
+	for shortUrl, data := range liveData {
+		readData, subErr := bp.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(readData, data) {
+			t.Fatalf("read data %v not matched, err:%v", shortUrl, subErr)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package testing
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"testing"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+)
+
+/*
+ * packet header testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//gen one record with assigned packet
+func genRecord(pack face.IPacket, data []byte) []byte {
+	msg := face.NewMessage()
+	msg.SetMd5(fmt.Sprintf("%x", md5.Sum(data)))
+	msg.SetBlocks(define.DefaultChunkBlockSize)
+	msg.SetLen(int64(len(data)))
+	header, _ := pack.Pack(msg)
+	record := make([]byte, int64(len(header)) + define.DefaultChunkBlockSize)
+	copy(record, header)
+	copy(record[len(header):], data)
+	return record
+}
+
+//test packet v2 pack and unpack
+func TestPacketV2(t *testing.T) {
+	data := []byte("packet-v2-data")
+	pack := face.NewPacketV2()
+	msg := face.NewMessage()
+	msg.SetMd5(fmt.Sprintf("%x", md5.Sum(data)))
+	msg.SetBlocks(define.DefaultChunkBlockSize)
+	msg.SetLen(int64(len(data)))
+	msg.SetCrc(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	header, err := pack.Pack(msg)
+	if err != nil {
+		t.Fatalf("pack header failed, err:%v", err)
+	}
+	if int64(len(header)) != face.PacketHeadSize {
+		t.Fatalf("header size not matched, size:%v", len(header))
+	}
+
+	//unpack by detected packet
+	newMsg, err := face.DetectPacket(header).UnPack(header)
+	if err != nil {
+		t.Fatalf("unpack header failed, err:%v", err)
+	}
+	if newMsg.GetVersion() != face.PacketVersionTwo || newMsg.GetMd5() != msg.GetMd5() ||
+		newMsg.GetLen() != msg.GetLen() || newMsg.GetCrc() != msg.GetCrc() {
+		t.Fatalf("unpacked header not matched")
+	}
+}
+
+//test upgrade legacy chunk data file
+func TestUpgradeDataFile(t *testing.T) {
+	//gen chunk file with v1 and v2 records
+	filePath := fmt.Sprintf("%v/upgrade-chunk.data", t.TempDir())
+	dataOne := []byte("legacy-record-data")
+	dataTwo := []byte("latest-record-data")
+	fileData := genRecord(face.NewPacket(), dataOne)
+	fileData = append(fileData, genRecord(face.NewPacketV2(), dataTwo)...)
+	fileData = append(fileData, genRecord(face.NewPacket(), dataOne)...)
+	err := os.WriteFile(filePath, fileData, define.FilePerm)
+	if err != nil {
+		t.Fatalf("write chunk file failed, err:%v", err)
+	}
+
+	//upgrade file
+	result, err := chunk.UpgradeDataFile(filePath, define.DefaultChunkBlockSize)
+	if err != nil {
+		t.Fatalf("upgrade chunk file failed, err:%v", err)
+	}
+	if result.Upgraded != 2 || result.Walk.Records != 3 || result.Walk.TornTail {
+		t.Fatalf("upgrade result not matched, upgraded:%v, records:%v",
+			result.Upgraded, result.Walk.Records)
+	}
+
+	//walk again, all headers should be the latest
+	file, _ := os.Open(filePath)
+	defer file.Close()
+	crcOne := crc32.Checksum(dataOne, crc32.MakeTable(crc32.Castagnoli))
+	walkResult, err := chunk.WalkDataFile(file, define.DefaultChunkBlockSize,
+		func(offset int64, msg face.IMessage) error {
+			if msg.GetVersion() != face.PacketVersionLatest {
+				return fmt.Errorf("header of offset %v not upgraded", offset)
+			}
+			if msg.GetLen() == int64(len(dataOne)) && msg.GetCrc() != crcOne &&
+				msg.GetMd5() == fmt.Sprintf("%x", md5.Sum(dataOne)) {
+				return fmt.Errorf("crc of offset %v not matched", offset)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("walk chunk file failed, err:%v", err)
+	}
+	if walkResult.Versions[face.PacketVersionLatest] != 3 {
+		t.Fatalf("latest headers not matched, versions:%v", walkResult.Versions)
+	}
+}
@@ -0,0 +1,113 @@
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * file lifecycle testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+const (
+	LifecycleDeleteType  = "application/x-lifecycle-delete"
+	LifecycleArchiveType = "application/x-lifecycle-archive"
+)
+
+//test lifecycle rules delete and archive files
+func TestLifecycle(t *testing.T) {
+	p := pond.NewPond()
+	cfg := p.GenConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.MetaStore = define.MetaStoreOfBolt
+	cfg.CheckSame = true
+	cfg.LifecycleRules = []*conf.LifecycleRule{
+		{
+			Name: "delete-old",
+			Action: define.LifecycleActionOfDelete,
+			ContentType: LifecycleDeleteType,
+			AgeSeconds: 1,
+		},
+		{
+			Name: "archive-cold",
+			Action: define.LifecycleActionOfArchive,
+			ContentType: LifecycleArchiveType,
+			IdleSeconds: 1,
+		},
+	}
+	err := p.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+	defer p.Quit()
+
+	//write files of rules
+	writeFile := func(data []byte, contentType string) string {
+		opt := p.GenWriteOption()
+		opt.ContentType = contentType
+		shortUrl, subErr := p.WriteDataWithOption(data, opt)
+		if subErr != nil {
+			t.Fatalf("write data failed, err:%v", subErr)
+		}
+		return shortUrl
+	}
+	deleteUrl := writeFile([]byte(fmt.Sprintf("delete-%v", time.Now().UnixNano())), LifecycleDeleteType)
+	archiveData := []byte(fmt.Sprintf("archive-%v", time.Now().UnixNano()))
+	archiveUrl := writeFile(archiveData, LifecycleArchiveType)
+	sharedUrl := writeFile(archiveData, LifecycleArchiveType)
+	archiveInfo, _ := p.Stat(archiveUrl)
+	time.Sleep(time.Second * 2)
+
+	//run lifecycle rules
+	report, err := p.RunLifecycle()
+	if err != nil {
+		t.Fatalf("run lifecycle failed, err:%v", err)
+	}
+	//shared data only moved once
+	if report.Deleted != 1 || report.Archived != 1 || report.Failed != 0 || len(report.Actions) != 3 {
+		t.Fatalf("lifecycle report not matched, report:%+v", report)
+	}
+	if p.GetLifecycleReport() != report {
+		t.Fatalf("latest lifecycle report not matched")
+	}
+
+	//check deleted and archived file
+	_, err = p.ReadData(deleteUrl)
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("deleted file should be not found, err:%v", err)
+	}
+	readData, err := p.ReadData(archiveUrl)
+	if err != nil || !bytes.Equal(readData, archiveData) {
+		t.Fatalf("read archived data not matched, err:%v", err)
+	}
+	newInfo, _ := p.Stat(archiveUrl)
+	if newInfo == nil || newInfo.ChunkFileId == archiveInfo.ChunkFileId {
+		t.Fatalf("archived data not moved")
+	}
+	readData, err = p.ReadData(sharedUrl)
+	if err != nil || !bytes.Equal(readData, archiveData) {
+		t.Fatalf("read shared archived data not matched, err:%v", err)
+	}
+
+	//new data not written into archive chunk
+	newUrl := writeFile([]byte(fmt.Sprintf("new-%v", time.Now().UnixNano())), "")
+	newDataInfo, _ := p.Stat(newUrl)
+	if newDataInfo == nil || newDataInfo.ChunkFileId == newInfo.ChunkFileId {
+		t.Fatalf("new data written into archive chunk")
+	}
+
+	//archived file not matched again
+	report, err = p.RunLifecycle()
+	if err != nil || report.Matched != 0 {
+		t.Fatalf("archived file matched again, err:%v", err)
+	}
+}
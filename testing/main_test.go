@@ -0,0 +1,25 @@
+package testing
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+ * testing main
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//run all tests
+//data dir of single pond instance removed when done
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if p != nil {
+		p.Quit()
+	}
+	if dataDir != "" {
+		os.RemoveAll(dataDir)
+	}
+	os.Exit(code)
+}
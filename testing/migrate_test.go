@@ -0,0 +1,48 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * meta data migrate testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test migrate meta data of running pond
+func TestMigrateMeta(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+
+	//write and delete data
+	shortUrl, err := lp.WriteData([]byte("migrate testing data"))
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	removedUrl, err := lp.WriteData([]byte("migrate removed testing data"))
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	lp.DelData(removedUrl)
+
+	//migrate into bolt store and verify
+	opt := &conf.MigrateOption{
+		PageSize: 1000,
+		Verify: true,
+	}
+	result, err := lp.MigrateMeta(define.MetaStoreOfBolt, nil, opt)
+	if err != nil {
+		t.Fatalf("migrate meta failed, err:%v", err)
+	}
+	if result.Infos <= 0 || result.Removed <= 0 || result.Mismatched > 0 {
+		t.Fatalf("migrate result not matched, result:%+v", result)
+	}
+
+	//running pond still work
+	if _, err = lp.ReadData(shortUrl); err != nil {
+		t.Fatalf("read data after migrate failed, err:%v", err)
+	}
+}
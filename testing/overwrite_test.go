@@ -0,0 +1,160 @@
+package testing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * overwrite data testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//open bolt pond for overwrite
+func openOverwritePond(
+		t *testing.T,
+		dataPath string,
+		checkSame bool,
+	) *pond.Pond {
+	return OpenBoltPond(t, dataPath, func(cfg *conf.Config) {
+		cfg.CheckSame = checkSame
+	})
+}
+
+//test overwrite in place and relocated
+func TestOverwrite(t *testing.T) {
+	dataPath := t.TempDir()
+	p := openOverwritePond(t, dataPath, false)
+
+	//check file data
+	checkData := func(shortUrl string, expect []byte) {
+		data, subErr := p.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(data, expect) {
+			t.Fatalf("read data not matched, data:%s, err:%v", data, subErr)
+		}
+	}
+
+	//write data without fixed block size
+	oldData := []byte("overwrite data")
+	shortUrl, err := p.WriteData(oldData)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	_, err = p.WriteData([]byte("next data"))
+	if err != nil {
+		t.Fatalf("write next data failed, err:%v", err)
+	}
+	oldInfo, _ := p.Stat(shortUrl)
+
+	//smaller data overwritten in place
+	smallData := []byte("small")
+	_, err = p.WriteData(smallData, shortUrl)
+	if err != nil {
+		t.Fatalf("overwrite small data failed, err:%v", err)
+	}
+	checkData(shortUrl, smallData)
+	newInfo, _ := p.Stat(shortUrl)
+	if newInfo.ChunkFileId != oldInfo.ChunkFileId || newInfo.Offset != oldInfo.Offset {
+		t.Fatalf("small data should be written in place, old:%+v, new:%+v", oldInfo, newInfo)
+	}
+
+	//grown data relocated with same short url
+	bigData := bytes.Repeat([]byte("grown data"), 100)
+	_, err = p.WriteData(bigData, shortUrl)
+	if err != nil {
+		t.Fatalf("overwrite grown data failed, err:%v", err)
+	}
+	checkData(shortUrl, bigData)
+	newInfo, _ = p.Stat(shortUrl)
+	if newInfo.Offset == oldInfo.Offset || newInfo.Size != int64(len(bigData)) {
+		t.Fatalf("grown data should be relocated, old:%+v, new:%+v", oldInfo, newInfo)
+	}
+
+	//old space re-used by new data
+	reuseUrl, err := p.WriteData(oldData)
+	if err != nil {
+		t.Fatalf("write reuse data failed, err:%v", err)
+	}
+	reuseInfo, _ := p.Stat(reuseUrl)
+	if reuseInfo.ChunkFileId != oldInfo.ChunkFileId || reuseInfo.Offset != oldInfo.Offset {
+		t.Fatalf("old space should be re-used, old:%+v, reuse:%+v", oldInfo, reuseInfo)
+	}
+
+	//reopen and read again
+	p.Quit()
+	p = openOverwritePond(t, dataPath, false)
+	checkData(shortUrl, bigData)
+	checkData(reuseUrl, oldData)
+
+	//overwrite not exists file
+	_, err = p.WriteData(bigData, "not-exists")
+	if err != define.ErrFileNotFound {
+		t.Fatalf("overwrite not exists file should failed, err:%v", err)
+	}
+}
+
+//test overwrite shared data by copy on write
+func TestOverwriteShared(t *testing.T) {
+	p := openOverwritePond(t, t.TempDir(), true)
+
+	//check file data
+	checkData := func(shortUrl string, expect []byte) {
+		data, subErr := p.ReadData(shortUrl)
+		if subErr != nil || !bytes.Equal(data, expect) {
+			t.Fatalf("read data not matched, data:%s, err:%v", data, subErr)
+		}
+	}
+
+	//same data shared by two files
+	sameData := []byte("shared data")
+	firstUrl, err := p.WriteData(sameData)
+	if err != nil {
+		t.Fatalf("write first data failed, err:%v", err)
+	}
+	secondUrl, err := p.WriteData(sameData)
+	if err != nil {
+		t.Fatalf("write second data failed, err:%v", err)
+	}
+	firstInfo, _ := p.Stat(firstUrl)
+	secondInfo, _ := p.Stat(secondUrl)
+	if firstInfo.Md5 != secondInfo.Md5 {
+		t.Fatalf("same data should be shared, first:%+v, second:%+v", firstInfo, secondInfo)
+	}
+
+	//overwrite one file, other file not changed
+	newData := []byte("new data")
+	_, err = p.WriteData(newData, firstUrl)
+	if err != nil {
+		t.Fatalf("overwrite shared data failed, err:%v", err)
+	}
+	checkData(firstUrl, newData)
+	checkData(secondUrl, sameData)
+	firstInfo, _ = p.Stat(firstUrl)
+	if firstInfo.Md5 == secondInfo.Md5 {
+		t.Fatalf("overwritten file should be re-pointed, info:%+v", firstInfo)
+	}
+
+	//overwrite other file with same new data, shared again
+	_, err = p.WriteData(newData, secondUrl)
+	if err != nil {
+		t.Fatalf("overwrite second data failed, err:%v", err)
+	}
+	checkData(secondUrl, newData)
+	secondInfo, _ = p.Stat(secondUrl)
+	if firstInfo.Md5 != secondInfo.Md5 {
+		t.Fatalf("same new data should be shared, first:%+v, second:%+v", firstInfo, secondInfo)
+	}
+
+	//old data released, write again as new file
+	thirdUrl, err := p.WriteData(sameData)
+	if err != nil {
+		t.Fatalf("write old data again failed, err:%v", err)
+	}
+	checkData(thirdUrl, sameData)
+	checkData(firstUrl, newData)
+}
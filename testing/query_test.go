@@ -0,0 +1,189 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * file query testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+const (
+	QueryFiles   = 4
+)
+
+//test list files by query with search and bolt meta store
+func TestListFiles(t *testing.T) {
+	//search meta store
+	checkListFiles(t, OpenLocalPond(t, t.TempDir()))
+
+	//bolt meta store, query by scan
+	p := pond.NewPond()
+	cfg := p.GenConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.MetaStore = define.MetaStoreOfBolt
+	err := p.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+	defer p.Quit()
+	checkListFiles(t, p)
+}
+
+//write files with option and check query result
+func checkListFiles(t *testing.T, p *pond.Pond) {
+	now := time.Now().UnixNano()
+	contentType := fmt.Sprintf("application/x-pond-query-%v", now)
+	namePrefix := fmt.Sprintf("query-%v-", now)
+	shortUrls := make([]string, 0)
+	for i := 0; i < QueryFiles; i++ {
+		opt := p.GenWriteOption()
+		opt.Name = fmt.Sprintf("%v%v.txt", namePrefix, i)
+		opt.ContentType = contentType
+		data := bytes.Repeat([]byte(fmt.Sprintf("query-%v;", now)), i + 1)
+		shortUrl, err := p.WriteDataWithOption(data, opt)
+		if err != nil {
+			t.Fatalf("write data failed, err:%v", err)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+	firstInfo, _ := p.Stat(shortUrls[0])
+
+	//filter by content type, order by size asc
+	query := p.GenFileQuery()
+	query.ContentType = contentType
+	query.OrderBy = define.FileQueryOrderBySize
+	query.Asc = true
+	query.PageSize = 2
+	query.Page = 2
+	total, filesInfo, err := p.ListFiles(query)
+	if err != nil || total != QueryFiles || len(filesInfo) != 2 {
+		t.Fatalf("list files by content type not matched, total:%v, err:%v", total, err)
+	}
+	if filesInfo[0].ShortUrl != shortUrls[2] || filesInfo[1].ShortUrl != shortUrls[3] {
+		t.Fatalf("list files order by size not matched")
+	}
+
+	//filter by name prefix and size range
+	query = p.GenFileQuery()
+	query.NamePrefix = namePrefix
+	query.SizeMin = firstInfo.Size + 1
+	total, filesInfo, err = p.ListFiles(query)
+	if err != nil || total != QueryFiles - 1 || len(filesInfo) != QueryFiles - 1 {
+		t.Fatalf("list files by name prefix not matched, total:%v, err:%v", total, err)
+	}
+
+	//filter by create time and chunk id
+	query = p.GenFileQuery()
+	query.CreateAtMin = firstInfo.CreateAt
+	query.ChunkFileId = firstInfo.ChunkFileId
+	query.PageSize = define.DefaultPageSizeMax
+	total, filesInfo, err = p.ListFiles(query)
+	if err != nil || total < QueryFiles {
+		t.Fatalf("list files by create time not matched, total:%v, err:%v", total, err)
+	}
+	for _, fileInfo := range filesInfo {
+		if fileInfo.CreateAt < firstInfo.CreateAt || fileInfo.ChunkFileId != firstInfo.ChunkFileId {
+			t.Fatalf("list files by create time got unmatched file %v", fileInfo.ShortUrl)
+		}
+	}
+
+	//removed file data
+	err = p.DelData(shortUrls[0])
+	if err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+	query = p.GenFileQuery()
+	query.Removed = true
+	query.SizeMin = firstInfo.Size
+	query.SizeMax = firstInfo.Size
+	query.PageSize = define.DefaultPageSizeMax
+	_, filesInfo, err = p.ListFiles(query)
+	if err != nil {
+		t.Fatalf("list removed files failed, err:%v", err)
+	}
+	removedFound := false
+	for _, fileInfo := range filesInfo {
+		if fileInfo.Md5 == firstInfo.Md5 {
+			removedFound = true
+		}
+	}
+	if !removedFound {
+		t.Fatalf("removed file data not listed")
+	}
+}
+
+//test list files by create time range with redis meta store, paged by sorted set
+func TestListFilesByRange(t *testing.T) {
+	p := GetPond()
+	now := time.Now().UnixNano()
+	shortUrls := make([]string, 0)
+	for i := 0; i < QueryFiles; i++ {
+		data := bytes.Repeat([]byte(fmt.Sprintf("range-%v-%v;", now, i)), i + 1)
+		shortUrl, err := p.WriteData(data)
+		if err != nil {
+			t.Fatalf("write data failed, err:%v", err)
+		}
+		shortUrls = append(shortUrls, shortUrl)
+	}
+	firstInfo, _ := p.Stat(shortUrls[0])
+	lastInfo, _ := p.Stat(shortUrls[QueryFiles - 1])
+	if firstInfo == nil || lastInfo == nil {
+		t.Fatalf("stat written files failed")
+	}
+
+	//walk all pages of create time range
+	query := p.GenFileQuery()
+	query.CreateAtMin = firstInfo.CreateAt
+	query.CreateAtMax = lastInfo.CreateAt
+	query.Asc = true
+	query.PageSize = 2
+	listed := map[string]bool{}
+	lastCreateAt := int64(0)
+	for query.Page = 1; ; query.Page++ {
+		total, filesInfo, err := p.ListFiles(query)
+		if err != nil || total < QueryFiles {
+			t.Fatalf("list files by create time range failed, total:%v, err:%v", total, err)
+		}
+		for _, fileInfo := range filesInfo {
+			if fileInfo.CreateAt < lastCreateAt || fileInfo.CreateAt > lastInfo.CreateAt {
+				t.Fatalf("list files by create time range got unmatched file %v", fileInfo.ShortUrl)
+			}
+			lastCreateAt = fileInfo.CreateAt
+			listed[fileInfo.ShortUrl] = true
+		}
+		if int64(query.Page * query.PageSize) >= total {
+			if int64(len(listed)) != total {
+				t.Fatalf("list files by create time range paged %v of %v", len(listed), total)
+			}
+			break
+		}
+	}
+	for _, shortUrl := range shortUrls {
+		if !listed[shortUrl] {
+			t.Fatalf("file %v not listed by create time range", shortUrl)
+		}
+	}
+
+	//order by size in size range
+	query = p.GenFileQuery()
+	query.SizeMin = firstInfo.Size
+	query.OrderBy = define.FileQueryOrderBySize
+	query.Asc = true
+	query.PageSize = 2
+	total, filesInfo, err := p.ListFiles(query)
+	if err != nil || total < QueryFiles || len(filesInfo) != 2 {
+		t.Fatalf("list files by size range failed, total:%v, err:%v", total, err)
+	}
+	if filesInfo[0].Size < firstInfo.Size || filesInfo[0].Size > filesInfo[1].Size {
+		t.Fatalf("list files by size range order not matched")
+	}
+}
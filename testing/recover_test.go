@@ -0,0 +1,109 @@
+package testing
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/chunk"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * recover testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+const (
+	RecoverChunkId = 999
+)
+
+//test recover lost meta data from chunk file
+func TestRecover(t *testing.T) {
+	dataPath := t.TempDir()
+	lp := OpenLocalPond(t, dataPath)
+
+	//write data with meta data
+	liveData := []byte(fmt.Sprintf("recover-live-%v", time.Now().UnixNano()))
+	liveUrl, err := lp.WriteData(liveData)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	liveInfo, _ := lp.Stat(liveUrl)
+
+	//removed space split by smaller data
+	bigUrl, err := lp.WriteData(bytes.Repeat([]byte("recover-big;"), 1000))
+	if err != nil {
+		t.Fatalf("write big data failed, err:%v", err)
+	}
+	if _, err = lp.WriteData([]byte(fmt.Sprintf("recover-next-%v", time.Now().UnixNano()))); err != nil {
+		t.Fatalf("write next data failed, err:%v", err)
+	}
+	if err = lp.DelData(bigUrl); err != nil {
+		t.Fatalf("delete big data failed, err:%v", err)
+	}
+	if _, err = lp.WriteData([]byte(fmt.Sprintf("recover-split-%v", time.Now().UnixNano()))); err != nil {
+		t.Fatalf("write split data failed, err:%v", err)
+	}
+
+	//write chunk file without meta data
+	cfg := &conf.Config{
+		DataPath: dataPath,
+		ChunkBlockSize: define.DefaultChunkBlockSize,
+	}
+	chunkObj := chunk.NewChunk(RecoverChunkId, cfg)
+	lostData := bytes.Repeat([]byte(fmt.Sprintf("recover-lost-%v;", time.Now().UnixNano())), 100)
+	lostMd5 := fmt.Sprintf("%x", md5.Sum(lostData))
+	resp := chunkObj.WriteFile(lostMd5, lostData)
+	chunkObj.Quit()
+	if resp.Err != nil {
+		t.Fatalf("write chunk data failed, err:%v", resp.Err)
+	}
+
+	//check only
+	result, err := lp.Recover()
+	if err != nil {
+		t.Fatalf("recover check failed, err:%v", err)
+	}
+	var liveFound, lostFound bool
+	for _, chunkResult := range result.Chunks {
+		if chunkResult.ChunkId == liveInfo.ChunkFileId {
+			//all records found, split remainder skipped
+			liveFound = chunkResult.Live == 3 && chunkResult.Walk.SkipBytes == 0
+		}
+		for _, fileBase := range chunkResult.Orphans {
+			if fileBase.Md5 == liveInfo.Md5 {
+				t.Fatalf("live data reported as orphan")
+			}
+			if fileBase.Md5 == lostMd5 && fileBase.Size == int64(len(lostData)) {
+				lostFound = true
+			}
+		}
+	}
+	if !liveFound || !lostFound {
+		t.Fatalf("recover result not matched, live:%v, lost:%v", liveFound, lostFound)
+	}
+
+	//repopulate lost data
+	result, err = lp.Recover(&conf.RecoverOption{Repopulate: true})
+	if err != nil {
+		t.Fatalf("recover repopulate failed, err:%v", err)
+	}
+	shortUrl := ""
+	for _, chunkResult := range result.Chunks {
+		if chunkResult.ChunkId == RecoverChunkId {
+			shortUrl = chunkResult.Recovered[lostMd5]
+		}
+	}
+	if shortUrl == "" {
+		t.Fatalf("lost data not repopulated")
+	}
+	readData, err := lp.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(readData, lostData) {
+		t.Fatalf("read recovered data not matched, err:%v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/server"
+)
+
+/*
+ * http server testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test http server api
+func TestServer(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+	ts := httptest.NewServer(server.NewServer(lp))
+	defer ts.Close()
+
+	//upload new data
+	data := []byte(fmt.Sprintf("server-data-%v", time.Now().UnixNano()))
+	resp, err := http.Post(ts.URL+"/", "text/plain", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("upload failed, err:%v", err)
+	}
+	respData, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload failed, status:%v, resp:%v", resp.StatusCode, string(respData))
+	}
+	writeResp := json.NewWriteRespJson()
+	writeResp.Decode(respData, writeResp)
+	shortUrl := writeResp.ShortUrl
+	if shortUrl == "" {
+		t.Fatalf("upload failed, no short url")
+	}
+
+	//get range data
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/"+shortUrl, nil)
+	req.Header.Set("Range", "bytes=0-5")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get range failed, err:%v", err)
+	}
+	respData, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(respData, data[:6]) {
+		t.Fatalf("get range failed, status:%v, data:%v", resp.StatusCode, string(respData))
+	}
+	if resp.Header.Get("Content-Type") != "text/plain" || resp.Header.Get("ETag") == "" {
+		t.Fatalf("get range failed, invalid header:%v", resp.Header)
+	}
+
+	//head request
+	resp, err = http.Head(ts.URL + "/" + shortUrl)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.ContentLength != int64(len(data)) {
+		t.Fatalf("head failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+
+	//conditional get
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/"+shortUrl, nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional get failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+
+	//overwrite data
+	newData := []byte(fmt.Sprintf("server-new-%v", time.Now().UnixNano()))
+	req, _ = http.NewRequest(http.MethodPut, ts.URL+"/"+shortUrl, bytes.NewReader(newData))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("overwrite failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+	resp, _ = http.Get(ts.URL + "/" + shortUrl)
+	respData, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(respData, newData) {
+		t.Fatalf("overwrite data not matched, data:%v", string(respData))
+	}
+
+	//files list
+	resp, err = http.Get(ts.URL + "/files?page=1")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("files list failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+
+	//delete data
+	req, _ = http.NewRequest(http.MethodDelete, ts.URL+"/"+shortUrl, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+	resp, _ = http.Get(ts.URL + "/" + shortUrl)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted data still found, status:%v", resp.StatusCode)
+	}
+}
+
+//test conditional get after data written in place
+func TestServerConditional(t *testing.T) {
+	p := OpenBoltPond(t, t.TempDir(), func(cfg *conf.Config) {
+		cfg.CheckSame = false
+	})
+	ts := httptest.NewServer(server.NewServer(p))
+	defer ts.Close()
+
+	//conditional get with old etag and modify time
+	checkModified := func(shortUrl string, etag, modTime string, expect []byte) (string, string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/"+shortUrl, nil)
+		req.Header.Set("If-None-Match", etag)
+		resp, subErr := http.DefaultClient.Do(req)
+		if subErr != nil {
+			t.Fatalf("conditional get failed, err:%v", subErr)
+		}
+		respData, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(respData, expect) {
+			t.Fatalf("written data should be modified, status:%v, data:%v", resp.StatusCode, string(respData))
+		}
+		newETag := resp.Header.Get("ETag")
+		newModTime := resp.Header.Get("Last-Modified")
+		if newETag == etag {
+			t.Fatalf("etag should be changed, etag:%v", etag)
+		}
+		oldTime, _ := http.ParseTime(modTime)
+		newTime, _ := http.ParseTime(newModTime)
+		if newTime.Before(oldTime) {
+			t.Fatalf("modify time should not be earlier, old:%v, new:%v", modTime, newModTime)
+		}
+		return newETag, newModTime
+	}
+
+	//write data and get etag
+	data := []byte("server conditional data")
+	shortUrl, err := p.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	resp, err := http.Get(ts.URL + "/" + shortUrl)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get data failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+	etag, modTime := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+
+	//same size data overwritten in place
+	time.Sleep(time.Second)
+	newData := []byte("server overwrite data!!")
+	_, err = p.WriteData(newData, shortUrl)
+	if err != nil {
+		t.Fatalf("overwrite data failed, err:%v", err)
+	}
+	etag, newModTime := checkModified(shortUrl, etag, modTime, newData)
+	if newModTime == modTime {
+		t.Fatalf("modify time should be changed, time:%v", modTime)
+	}
+
+	//data appended in place
+	appendData := []byte(" appended")
+	_, err = p.Append(shortUrl, appendData)
+	if err != nil {
+		t.Fatalf("append data failed, err:%v", err)
+	}
+	etag, _ = checkModified(shortUrl, etag, newModTime, append(newData, appendData...))
+
+	//not modified with latest etag
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/"+shortUrl, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional get failed, resp:%v, err:%v", resp, err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,74 @@
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * stat testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test write with option and stat api
+func TestStat(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+
+	//write data with option
+	data := []byte(fmt.Sprintf("stat-data-%v", time.Now().UnixNano()))
+	opt := lp.GenWriteOption()
+	opt.Name = "stat.txt"
+	opt.ContentType = "text/plain"
+	opt.Metadata["owner"] = "tester"
+	opt.TTL = 3600
+	shortUrl, err := lp.WriteDataWithOption(data, opt)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+
+	//stat file info
+	fileInfo, subErr := lp.Stat(shortUrl)
+	if subErr != nil || fileInfo == nil {
+		t.Fatalf("stat failed, err:%v", subErr)
+	}
+	if fileInfo.Name != opt.Name ||
+		fileInfo.ContentType != opt.ContentType ||
+		fileInfo.Metadata["owner"] != "tester" ||
+		fileInfo.ExpireAt <= time.Now().Unix() ||
+		fileInfo.Size != int64(len(data)) {
+		t.Fatalf("stat file info not matched, info:%+v", fileInfo)
+	}
+}
+
+//test exists api
+func TestExists(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+
+	//write data
+	data := []byte(fmt.Sprintf("exists-data-%v", time.Now().UnixNano()))
+	shortUrl, err := lp.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+
+	//check exists
+	isExists, subErr := lp.Exists(shortUrl)
+	if subErr != nil || !isExists {
+		t.Fatalf("file should be exists, err:%v", subErr)
+	}
+
+	//check not exists
+	isExists, _ = lp.Exists(shortUrl + "-none")
+	if isExists {
+		t.Fatalf("file should not be exists")
+	}
+	_, err = lp.Stat(shortUrl + "-none")
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("stat not exists file should return not found, err:%v", err)
+	}
+}
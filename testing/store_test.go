@@ -0,0 +1,140 @@
+package testing
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/face"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * meta store testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+const (
+	StoreName    = "memory"
+)
+
+//memory meta store for testing
+type memoryStore struct {
+	infos map[string]*json.FileInfoJson
+	bases map[string]*json.FileBaseJson
+	sync.RWMutex
+}
+
+func (f *memoryStore) GetInfo(shortUrl string) (*json.FileInfoJson, error) {
+	f.RLock()
+	defer f.RUnlock()
+	return f.infos[shortUrl], nil
+}
+func (f *memoryStore) PutInfo(obj *json.FileInfoJson) error {
+	f.Lock()
+	defer f.Unlock()
+	f.infos[obj.ShortUrl] = obj
+	return nil
+}
+func (f *memoryStore) DelInfo(shortUrl string) error {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.infos, shortUrl)
+	return nil
+}
+func (f *memoryStore) IsInfoExists(shortUrl string) (bool, error) {
+	f.RLock()
+	defer f.RUnlock()
+	_, ok := f.infos[shortUrl]
+	return ok, nil
+}
+func (f *memoryStore) ListInfoByTime(page, pageSize int) (int64, []*json.FileInfoJson, error) {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]*json.FileInfoJson, 0)
+	for _, v := range f.infos {
+		result = append(result, v)
+	}
+	return int64(len(result)), result, nil
+}
+func (f *memoryStore) ListInfoByCursor(createAt int64, shortUrl string, pageSize int) (int64, []*json.FileInfoJson, error) {
+	return f.ListInfoByTime(1, pageSize)
+}
+func (f *memoryStore) GetBase(md5 string) (*json.FileBaseJson, error) {
+	f.RLock()
+	defer f.RUnlock()
+	return f.bases[md5], nil
+}
+func (f *memoryStore) PutBase(obj *json.FileBaseJson) error {
+	f.Lock()
+	defer f.Unlock()
+	f.bases[obj.Md5] = obj
+	return nil
+}
+func (f *memoryStore) DelBase(md5 string) error {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.bases, md5)
+	return nil
+}
+func (f *memoryStore) ListRemovedBase(page, pageSize int) (int64, []*json.FileBaseJson, error) {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]*json.FileBaseJson, 0)
+	for _, v := range f.bases {
+		if v.Removed {
+			result = append(result, v)
+		}
+	}
+	return int64(len(result)), result, nil
+}
+func (f *memoryStore) Quit() {
+}
+
+//test custom registered meta store
+func TestMetaStore(t *testing.T) {
+	store := &memoryStore{
+		infos: map[string]*json.FileInfoJson{},
+		bases: map[string]*json.FileBaseJson{},
+	}
+	p := pond.NewPond()
+	defer p.Quit()
+	err := p.RegisterMetaStore(StoreName, func(cfg *conf.Config, redisCfg *conf.RedisConfig) (face.IMetaStore, error) {
+		return store, nil
+	})
+	if err != nil {
+		t.Fatalf("register meta store failed, err:%v", err)
+	}
+	cfg := p.GenConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.MetaStore = StoreName
+	err = p.SetConfig(cfg)
+	if err != nil {
+		t.Fatalf("set config failed, err:%v", err)
+	}
+
+	//write, read and delete by custom store
+	data := []byte("meta store testing data")
+	shortUrl, err := p.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	if exists, _ := store.IsInfoExists(shortUrl); !exists {
+		t.Fatalf("file info not saved into custom store")
+	}
+	readData, err := p.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(readData, data) {
+		t.Fatalf("read data not matched, err:%v", err)
+	}
+	err = p.DelData(shortUrl)
+	if err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+	total, _, _ := store.ListRemovedBase(1, 10)
+	if total != 1 {
+		t.Fatalf("removed file base not matched, total:%v", total)
+	}
+}
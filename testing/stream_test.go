@@ -0,0 +1,85 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+/*
+ * stream testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test stream write api
+func TestWriteFrom(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+
+	//format data, larger than stream buff size
+	data := bytes.Repeat([]byte(fmt.Sprintf("stream-%v;", time.Now().UnixNano())), 10000)
+	opt := lp.GenWriteOption()
+	opt.ContentType = "text/plain"
+	shortUrl, err := lp.WriteFrom(bytes.NewReader(data), int64(len(data)), opt)
+	if err != nil {
+		t.Fatalf("write from stream failed, err:%v", err)
+	}
+
+	//read and check data
+	readData, subErr := lp.ReadData(shortUrl)
+	if subErr != nil {
+		t.Fatalf("read data failed, err:%v", subErr)
+	}
+	if !bytes.Equal(readData, data) {
+		t.Fatalf("read data not matched, size:%v, expect:%v", len(readData), len(data))
+	}
+
+	//write same data, should share file base
+	sameShortUrl, subErrTwo := lp.WriteFrom(bytes.NewReader(data), int64(len(data)))
+	if subErrTwo != nil || sameShortUrl == shortUrl {
+		t.Fatalf("write same data failed, shortUrl:%v, err:%v", sameShortUrl, subErrTwo)
+	}
+
+	//write short stream
+	_, err = lp.WriteFrom(bytes.NewReader(data[:10]), int64(len(data)))
+	if err == nil {
+		t.Fatalf("write short stream should be failed")
+	}
+}
+
+//test open reader api
+func TestOpen(t *testing.T) {
+	lp := OpenLocalPond(t, t.TempDir())
+
+	//write data
+	data := []byte(fmt.Sprintf("open-reader-%v", time.Now().UnixNano()))
+	shortUrl, err := lp.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+
+	//open reader
+	reader, subErr := lp.Open(shortUrl)
+	if subErr != nil {
+		t.Fatalf("open reader failed, err:%v", subErr)
+	}
+	defer reader.Close()
+
+	//seek and read part data
+	_, err = reader.Seek(5, io.SeekStart)
+	if err != nil {
+		t.Fatalf("seek failed, err:%v", err)
+	}
+	partData, _ := io.ReadAll(reader)
+	if !bytes.Equal(partData, data[5:]) {
+		t.Fatalf("read part data not matched, data:%v", string(partData))
+	}
+
+	//seek end
+	size, _ := reader.Seek(0, io.SeekEnd)
+	if size != int64(len(data)) {
+		t.Fatalf("reader size not matched, size:%v", size)
+	}
+}
@@ -0,0 +1,139 @@
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * multipart upload testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//open bolt pond for upload
+func openUploadPond(t *testing.T, dataPath string) *pond.Pond {
+	return OpenBoltPond(t, dataPath, func(cfg *conf.Config) {
+		cfg.CheckSame = true
+	})
+}
+
+//test multipart upload complete, read and abort
+func TestUpload(t *testing.T) {
+	dataPath := t.TempDir()
+	p := openUploadPond(t, dataPath)
+
+	//init session and upload parts out of order
+	opt := p.GenWriteOption()
+	opt.Name = "upload.txt"
+	uploadId, err := p.InitUpload(opt)
+	if err != nil {
+		t.Fatalf("init upload failed, err:%v", err)
+	}
+	now := time.Now().UnixNano()
+	partOne := []byte(fmt.Sprintf("part-one-%v|", now))
+	partTwo := []byte(fmt.Sprintf("part-two-%v|", now))
+	partThree := []byte(fmt.Sprintf("part-three-%v", now))
+	for number, data := range map[int][]byte{3: partThree, 1: partOne, 2: []byte("old part two")} {
+		err = p.UploadPart(uploadId, number, data)
+		if err != nil {
+			t.Fatalf("upload part %v failed, err:%v", number, err)
+		}
+	}
+	err = p.UploadPart(uploadId, 2, partTwo)
+	if err != nil {
+		t.Fatalf("replace part failed, err:%v", err)
+	}
+
+	//complete and read whole data
+	shortUrl, err := p.CompleteUpload(uploadId)
+	if err != nil || shortUrl != uploadId {
+		t.Fatalf("complete upload failed, shortUrl:%v, err:%v", shortUrl, err)
+	}
+	expect := bytes.Join([][]byte{partOne, partTwo, partThree}, nil)
+	data, err := p.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(data, expect) {
+		t.Fatalf("read data not matched, data:%s, err:%v", data, err)
+	}
+	fileInfo, err := p.Stat(shortUrl)
+	if err != nil || fileInfo.Parts != 3 || fileInfo.Size != int64(len(expect)) || fileInfo.Name != opt.Name {
+		t.Fatalf("file info not matched, info:%+v, err:%v", fileInfo, err)
+	}
+
+	//read range cross parts
+	offset := int64(len(partOne) - 3)
+	data, err = p.ReadData(shortUrl, offset, 10)
+	if err != nil || !bytes.Equal(data, expect[offset:offset + 10]) {
+		t.Fatalf("read range not matched, data:%s, err:%v", data, err)
+	}
+
+	//read by reader with seek
+	reader, err := p.Open(shortUrl)
+	if err != nil {
+		t.Fatalf("open data failed, err:%v", err)
+	}
+	data, err = io.ReadAll(reader)
+	if err != nil || !bytes.Equal(data, expect) {
+		t.Fatalf("reader data not matched, data:%s, err:%v", data, err)
+	}
+	reader.Seek(offset, io.SeekStart)
+	data, err = io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(data, expect[offset:]) {
+		t.Fatalf("seek reader data not matched, data:%s, err:%v", data, err)
+	}
+
+	//completed session removed
+	err = p.UploadPart(uploadId, 4, partOne)
+	if !errors.Is(err, define.ErrUploadNotFound) {
+		t.Fatalf("upload part of completed session should failed, err:%v", err)
+	}
+
+	//missed part and abort
+	abortId, err := p.InitUpload()
+	if err != nil {
+		t.Fatalf("init upload failed, err:%v", err)
+	}
+	err = p.UploadPart(abortId, 2, partTwo)
+	if err != nil {
+		t.Fatalf("upload part failed, err:%v", err)
+	}
+	_, err = p.CompleteUpload(abortId)
+	if err == nil {
+		t.Fatalf("complete upload with missed part should failed")
+	}
+	err = p.AbortUpload(abortId)
+	if err != nil {
+		t.Fatalf("abort upload failed, err:%v", err)
+	}
+	_, err = p.CompleteUpload(abortId)
+	if !errors.Is(err, define.ErrUploadNotFound) {
+		t.Fatalf("complete aborted upload should failed, err:%v", err)
+	}
+
+	//reopen and read again
+	p.Quit()
+	p = openUploadPond(t, dataPath)
+	data, err = p.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(data, expect) {
+		t.Fatalf("read data after reopen not matched, data:%s, err:%v", data, err)
+	}
+
+	//delete file
+	err = p.DelData(shortUrl)
+	if err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+	_, err = p.ReadData(shortUrl)
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("read deleted data should failed, err:%v", err)
+	}
+}
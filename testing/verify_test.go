@@ -0,0 +1,65 @@
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/face"
+)
+
+/*
+ * verify on read testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test verify on read
+func TestVerifyOnRead(t *testing.T) {
+	dataPath := t.TempDir()
+	lp := OpenLocalPond(t, dataPath)
+
+	//write data
+	data := []byte(fmt.Sprintf("verify-data-%v", time.Now().UnixNano()))
+	shortUrl, err := lp.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	_, err = lp.ReadData(shortUrl)
+	if err != nil {
+		t.Fatalf("read data failed, err:%v", err)
+	}
+
+	//corrupt chunk data on disk
+	fileInfo, _ := lp.Stat(shortUrl)
+	chunkFile := fmt.Sprintf("%v/%v/%v", dataPath, define.SubDirOfFile,
+		fmt.Sprintf(define.ChunkDataFilePara, fileInfo.ChunkFileId))
+	file, subErr := os.OpenFile(chunkFile, os.O_RDWR, define.FilePerm)
+	if subErr != nil {
+		t.Fatalf("open chunk file failed, err:%v", subErr)
+	}
+	_, err = file.WriteAt([]byte("X"), fileInfo.Offset + face.PacketHeadSize)
+	file.Close()
+	if err != nil {
+		t.Fatalf("corrupt chunk file failed, err:%v", err)
+	}
+
+	//read corrupted data
+	_, err = lp.ReadData(shortUrl)
+	if !errors.Is(err, define.ErrDataCorrupted) {
+		t.Fatalf("read corrupted data should be failed, err:%v", err)
+	}
+
+	//read corrupted data by reader
+	reader, _ := lp.Open(shortUrl)
+	_, err = io.ReadAll(reader)
+	reader.Close()
+	corruptedErr := &define.DataCorruptedError{}
+	if !errors.As(err, &corruptedErr) || corruptedErr.ShortUrl != shortUrl {
+		t.Fatalf("read corrupted data by reader should be failed, err:%v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/andyzhou/pond"
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+)
+
+/*
+ * file versioning testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//open bolt pond with versioning
+func openVersionPond(t *testing.T, dataPath string) *pond.Pond {
+	return OpenBoltPond(t, dataPath, func(cfg *conf.Config) {
+		cfg.Versioning = true
+	})
+}
+
+//test versions of overwrite, delete, restore and purge
+func TestVersion(t *testing.T) {
+	dataPath := t.TempDir()
+	p := openVersionPond(t, dataPath)
+
+	//check versions and latest
+	checkVersions := func(shortUrl string, count int, latestId string, isMarker bool) {
+		versions, subErr := p.ListVersions(shortUrl)
+		if subErr != nil || len(versions) != count {
+			t.Fatalf("versions not matched, count:%v, err:%v", len(versions), subErr)
+		}
+		latest := versions[0]
+		if !latest.IsLatest || latest.VersionId != latestId || latest.DeleteMarker != isMarker {
+			t.Fatalf("latest version not matched, version:%+v", latest)
+		}
+	}
+	checkVersion := func(shortUrl, versionId string, expect []byte) {
+		data, subErr := p.ReadVersion(shortUrl, versionId)
+		if subErr != nil || !bytes.Equal(data, expect) {
+			t.Fatalf("version %v data not matched, data:%s, err:%v", versionId, data, subErr)
+		}
+	}
+
+	//write and overwrite data
+	dataOne := []byte("version one")
+	dataTwo := bytes.Repeat([]byte("version two"), 50)
+	dataThree := []byte("version three")
+	shortUrl, err := p.WriteData(dataOne)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	checkVersions(shortUrl, 1, "1", false)
+	for _, data := range [][]byte{dataTwo, dataThree} {
+		_, err = p.WriteData(data, shortUrl)
+		if err != nil {
+			t.Fatalf("overwrite data failed, err:%v", err)
+		}
+	}
+	checkVersions(shortUrl, 3, "3", false)
+	checkVersion(shortUrl, "1", dataOne)
+	checkVersion(shortUrl, "2", dataTwo)
+	checkVersion(shortUrl, "3", dataThree)
+
+	//delete leave delete marker
+	err = p.DelData(shortUrl)
+	if err != nil {
+		t.Fatalf("delete data failed, err:%v", err)
+	}
+	_, err = p.ReadData(shortUrl)
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("read deleted data should failed, err:%v", err)
+	}
+	checkVersions(shortUrl, 4, "4", true)
+	_, err = p.ReadVersion(shortUrl, "4")
+	if !errors.Is(err, define.ErrFileNotFound) {
+		t.Fatalf("read delete marker should failed, err:%v", err)
+	}
+	_, err = p.ReadVersion(shortUrl, "9")
+	if !errors.Is(err, define.ErrVersionNotFound) {
+		t.Fatalf("read not exists version should failed, err:%v", err)
+	}
+
+	//restore deleted file
+	err = p.RestoreVersion(shortUrl, "1")
+	if err != nil {
+		t.Fatalf("restore version failed, err:%v", err)
+	}
+	data, err := p.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(data, dataOne) {
+		t.Fatalf("restored data not matched, data:%s, err:%v", data, err)
+	}
+	checkVersions(shortUrl, 5, "5", false)
+
+	//reopen and read old version
+	p.Quit()
+	p = openVersionPond(t, dataPath)
+	checkVersion(shortUrl, "2", dataTwo)
+
+	//purge noncurrent versions
+	purged, err := p.PurgeVersions(shortUrl)
+	if err != nil || purged != 4 {
+		t.Fatalf("purge versions failed, purged:%v, err:%v", purged, err)
+	}
+	checkVersions(shortUrl, 1, "5", false)
+	_, err = p.ReadVersion(shortUrl, "2")
+	if !errors.Is(err, define.ErrVersionNotFound) {
+		t.Fatalf("read purged version should failed, err:%v", err)
+	}
+	data, err = p.ReadData(shortUrl)
+	if err != nil || !bytes.Equal(data, dataOne) {
+		t.Fatalf("current data not matched after purge, data:%s, err:%v", data, err)
+	}
+}
@@ -0,0 +1,130 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/andyzhou/pond/conf"
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+	"github.com/andyzhou/pond/storage"
+)
+
+/*
+ * wal testing code
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ */
+
+//test wal append, replay with torn tail and checkpoint
+func TestWal(t *testing.T) {
+	dataPath := t.TempDir()
+	cfg := &conf.Config{
+		DataPath: dataPath,
+	}
+	wal := storage.NewWal()
+	if err := wal.SetConfig(cfg); err != nil {
+		t.Fatalf("set wal config failed, err:%v", err)
+	}
+
+	//append entries
+	for i := 0; i < 3; i++ {
+		fileBase := json.NewFileBaseJson()
+		fileBase.Md5 = fmt.Sprintf("md5-%v", i)
+		fileInfo := json.NewFileInfoJson()
+		fileInfo.ShortUrl = fmt.Sprintf("url-%v", i)
+		err := wal.Append(define.WalOpOfWrite, fileInfo.ShortUrl, fileBase, fileInfo)
+		if err != nil {
+			t.Fatalf("append wal failed, err:%v", err)
+		}
+	}
+	wal.Quit()
+
+	//append torn tail
+	walFile := fmt.Sprintf("%v/%v/%v", dataPath, define.SubDirOfFile, define.ChunksWalFile)
+	file, _ := os.OpenFile(walFile, os.O_WRONLY|os.O_APPEND, define.FilePerm)
+	file.Write([]byte{0, 0, 1, 0, 1, 2})
+	file.Close()
+
+	//replay in order
+	reopened := storage.NewWal()
+	if err := reopened.SetConfig(cfg); err != nil {
+		t.Fatalf("reopen wal failed, err:%v", err)
+	}
+	defer reopened.Quit()
+	urls := make([]string, 0)
+	replayed, err := reopened.Replay(func(entry *json.WalEntryJson) error {
+		urls = append(urls, entry.ShortUrl)
+		return nil
+	})
+	if err != nil || replayed != 3 || urls[0] != "url-0" || urls[2] != "url-2" {
+		t.Fatalf("replay wal not matched, replayed:%v, err:%v", replayed, err)
+	}
+	info, _ := os.Stat(walFile)
+	if info.Size() != reopened.GetSize() {
+		t.Fatalf("torn tail not dropped, size:%v", info.Size())
+	}
+
+	//checkpoint
+	if err = reopened.Checkpoint(); err != nil || reopened.GetSize() != 0 {
+		t.Fatalf("checkpoint wal failed, err:%v", err)
+	}
+}
+
+//test replay delete broken before space freed
+func TestWalReplayDelete(t *testing.T) {
+	dataPath := t.TempDir()
+	p := OpenBoltPond(t, dataPath, nil)
+
+	//write data
+	data := []byte("wal replay data")
+	shortUrl, err := p.WriteData(data)
+	if err != nil {
+		t.Fatalf("write data failed, err:%v", err)
+	}
+	nextData := []byte("wal replay next data")
+	nextUrl, err := p.WriteData(nextData)
+	if err != nil {
+		t.Fatalf("write next data failed, err:%v", err)
+	}
+	fileInfo, _ := p.Stat(shortUrl)
+	p.Quit()
+
+	//delete entry appended, space not freed
+	wal := storage.NewWal()
+	if err = wal.SetConfig(&conf.Config{DataPath: dataPath}); err != nil {
+		t.Fatalf("set wal config failed, err:%v", err)
+	}
+	fileBase := json.NewFileBaseJson()
+	fileBase.Md5 = fileInfo.Md5
+	fileBase.Size = fileInfo.Size
+	fileBase.ChunkFileId = fileInfo.ChunkFileId
+	fileBase.Offset = fileInfo.Offset
+	fileBase.Blocks = fileInfo.Blocks
+	fileBase.Removed = true
+	err = wal.Append(define.WalOpOfDelete, shortUrl, fileBase, nil)
+	wal.Quit()
+	if err != nil {
+		t.Fatalf("append wal failed, err:%v", err)
+	}
+
+	//replayed delete, space freed and re-used
+	p = OpenBoltPond(t, dataPath, nil)
+	if _, err = p.Stat(shortUrl); err == nil {
+		t.Fatalf("deleted file should not found")
+	}
+	reuseUrl, err := p.WriteData([]byte("wal reuse data!"))
+	if err != nil {
+		t.Fatalf("write reuse data failed, err:%v", err)
+	}
+	reuseInfo, _ := p.Stat(reuseUrl)
+	if reuseInfo.ChunkFileId != fileInfo.ChunkFileId || reuseInfo.Offset != fileInfo.Offset {
+		t.Fatalf("deleted space should be re-used, old:%+v, reuse:%+v", fileInfo, reuseInfo)
+	}
+	readData, err := p.ReadData(nextUrl)
+	if err != nil || !bytes.Equal(readData, nextData) {
+		t.Fatalf("read next data not matched, data:%s, err:%v", readData, err)
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/andyzhou/pond/define"
+	"github.com/andyzhou/pond/json"
+)
+
+/*
+ * file query utils
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * - exact match, sort and page file info by query
+ * - used by meta store without native query
+ */
+
+//check file info matched query or not
+func (f *Utils) IsFileMatched(
+		query *json.FileQueryJson,
+		fileInfo *json.FileInfoJson,
+	) bool {
+	//check
+	if query == nil || fileInfo == nil {
+		return false
+	}
+	if query.CreateAtMin > 0 && fileInfo.CreateAt < query.CreateAtMin {
+		return false
+	}
+	if query.CreateAtMax > 0 && fileInfo.CreateAt > query.CreateAtMax {
+		return false
+	}
+	if query.SizeMin > 0 && fileInfo.Size < query.SizeMin {
+		return false
+	}
+	if query.SizeMax > 0 && fileInfo.Size > query.SizeMax {
+		return false
+	}
+	if query.ContentType != "" && fileInfo.ContentType != query.ContentType {
+		return false
+	}
+	if query.NamePrefix != "" && !strings.HasPrefix(fileInfo.Name, query.NamePrefix) {
+		return false
+	}
+	if query.ChunkFileId > 0 && fileInfo.ChunkFileId != query.ChunkFileId {
+		return false
+	}
+	if query.ExpireAtMax > 0 && (fileInfo.ExpireAt <= 0 || fileInfo.ExpireAt > query.ExpireAtMax) {
+		return false
+	}
+	return true
+}
+
+//sort file info by query order
+//same value sorted by short url
+func (f *Utils) SortFiles(
+		query *json.FileQueryJson,
+		filesInfo []*json.FileInfoJson,
+	) {
+	//check
+	if query == nil || len(filesInfo) <= 1 {
+		return
+	}
+	sort.SliceStable(filesInfo, func(i, j int) bool {
+		a, b := filesInfo[i], filesInfo[j]
+		if query.Asc {
+			a, b = b, a
+		}
+		switch query.OrderBy {
+		case define.FileQueryOrderBySize:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case define.FileQueryOrderByName:
+			if a.Name != b.Name {
+				return a.Name > b.Name
+			}
+		default:
+			if a.CreateAt != b.CreateAt {
+				return a.CreateAt > b.CreateAt
+			}
+		}
+		return a.ShortUrl > b.ShortUrl
+	})
+}
+
+//get one page of sorted file info by query
+func (f *Utils) PageFiles(
+		query *json.FileQueryJson,
+		filesInfo []*json.FileInfoJson,
+	) []*json.FileInfoJson {
+	//check
+	if query == nil {
+		return nil
+	}
+	page, pageSize := query.Page, query.PageSize
+	if page <= 0 {
+		page = define.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = define.DefaultPageSize
+	}
+	start := (page - 1) * pageSize
+	if start >= len(filesInfo) {
+		return []*json.FileInfoJson{}
+	}
+	end := start + pageSize
+	if end > len(filesInfo) {
+		end = len(filesInfo)
+	}
+	return filesInfo[start:end]
+}
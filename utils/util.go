@@ -4,6 +4,8 @@ import (
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"os"
 
 	"github.com/andyzhou/pond/define"
@@ -32,6 +34,24 @@ func (f *Utils) Md5Sum(data []byte) (string, error) {
 	return val, nil
 }
 
+//crc32 sum binary
+//use castagnoli table, as crc32c
+func (f *Utils) Crc32Sum(data []byte) uint32 {
+	return crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+}
+
+//update crc32 with appended data
+//use castagnoli table, as crc32c
+func (f *Utils) Crc32Update(crc uint32, data []byte) uint32 {
+	return crc32.Update(crc, crc32.MakeTable(crc32.Castagnoli), data)
+}
+
+//new crc32 hash
+//use castagnoli table, as crc32c
+func (f *Utils) NewCrc32Hash() hash.Hash32 {
+	return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+}
+
 //check file exists or not
 func (f *Utils) CheckFile(filePath string) error {
 	//check
@@ -52,7 +72,7 @@ func (f *Utils) CheckDir(dir string) error {
 	_, err := os.Stat(dir)
 	if err != nil {
 		//dir not exist
-		err = os.Mkdir(dir, define.FilePerm)
+		err = os.MkdirAll(dir, define.FilePerm)
 		if err != nil {
 			return err
 		}